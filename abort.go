@@ -0,0 +1,47 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+)
+
+var errCallAborted = errors.New("call aborted")
+
+// callContext returns the context in-flight calls are bound to. It is
+// cancelled by AbortAll, as well as when the VU context is done.
+func (c *Client) callContext() context.Context {
+	c.abortMu.Lock()
+	defer c.abortMu.Unlock()
+
+	if c.abortCtx == nil {
+		c.abortCtx, c.abort = context.WithCancelCause(c.ctx)
+	}
+	return c.abortCtx
+}
+
+// AbortAll cancels every call in flight on the client. Calls made afterwards
+// are not affected.
+func (c *Client) AbortAll() {
+	c.abortMu.Lock()
+	defer c.abortMu.Unlock()
+
+	if c.abort != nil {
+		c.abort(errCallAborted)
+	}
+	c.abortCtx, c.abort = nil, nil
+}
+
+// cancelledTag tells calls cancelled by AbortAll apart from those that ran out
+// of time, returning an empty string for any other outcome.
+func cancelledTag(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(context.Cause(ctx), errCallAborted):
+		return "abort"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return ""
+	}
+}
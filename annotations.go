@@ -0,0 +1,160 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ToolAnnotationFilter selects tools by their annotations. Unset fields match
+// any value. Hints a tool doesn't declare take their default value from the
+// MCP specification.
+type ToolAnnotationFilter struct {
+	ReadOnlyHint    *bool
+	DestructiveHint *bool
+	IdempotentHint  *bool
+	OpenWorldHint   *bool
+}
+
+// ExpectedToolAnnotations lists the annotations AssertToolAnnotations checks.
+// Unset fields are not checked. Hints are compared with their effective value,
+// the default of the MCP specification for hints a tool doesn't declare.
+type ExpectedToolAnnotations struct {
+	Title           *string
+	ReadOnlyHint    *bool
+	DestructiveHint *bool
+	IdempotentHint  *bool
+	OpenWorldHint   *bool
+}
+
+// toolHints holds the effective value of every annotation hint of a tool.
+type toolHints struct {
+	readOnly    bool
+	destructive bool
+	idempotent  bool
+	openWorld   bool
+}
+
+func effectiveHints(t *mcp.Tool) toolHints {
+	// Defaults from the specification.
+	hints := toolHints{destructive: true, openWorld: true}
+
+	a := t.Annotations
+	if a == nil {
+		return hints
+	}
+
+	hints.readOnly = a.ReadOnlyHint
+	hints.idempotent = a.IdempotentHint
+	if a.DestructiveHint != nil {
+		hints.destructive = *a.DestructiveHint
+	}
+	if a.OpenWorldHint != nil {
+		hints.openWorld = *a.OpenWorldHint
+	}
+	// destructiveHint is only meaningful for tools that aren't read-only.
+	if hints.readOnly {
+		hints.destructive = false
+	}
+
+	return hints
+}
+
+// matches reports whether t satisfies the filter. A nil filter matches every
+// tool.
+func (f *ToolAnnotationFilter) matches(t *mcp.Tool) bool {
+	if f == nil {
+		return true
+	}
+
+	hints := effectiveHints(t)
+	return matchesHint(f.ReadOnlyHint, hints.readOnly) &&
+		matchesHint(f.DestructiveHint, hints.destructive) &&
+		matchesHint(f.IdempotentHint, hints.idempotent) &&
+		matchesHint(f.OpenWorldHint, hints.openWorld)
+}
+
+func matchesHint(want *bool, got bool) bool {
+	return want == nil || *want == got
+}
+
+// ReadOnlyTools lists every tool annotated as read-only, which makes it safe
+// to exercise them without risking destructive side effects.
+func (c *Client) ReadOnlyTools() (*ListAllToolsResult, error) {
+	readOnly := true
+	return c.ListAllTools(ListAllToolsParams{
+		AnnotationFilter: &ToolAnnotationFilter{ReadOnlyHint: &readOnly},
+	})
+}
+
+// AssertToolAnnotations fails unless the named tool advertises the expected
+// annotations, listing every mismatch. It is a conformance check meant for
+// setup(): the tool is taken from the tool cache, or looked up without
+// recording request metrics.
+func (c *Client) AssertToolAnnotations(name string, expected ExpectedToolAnnotations) error {
+	t, err := c.lookupTool(name)
+	if err != nil {
+		return err
+	}
+
+	var title string
+	if t.Annotations != nil {
+		title = t.Annotations.Title
+	}
+	hints := effectiveHints(t)
+
+	var mismatches []string
+	if expected.Title != nil && *expected.Title != title {
+		mismatches = append(mismatches, fmt.Sprintf("title is %q, expected %q", title, *expected.Title))
+	}
+	for _, hint := range []struct {
+		name string
+		want *bool
+		got  bool
+	}{
+		{"readOnlyHint", expected.ReadOnlyHint, hints.readOnly},
+		{"destructiveHint", expected.DestructiveHint, hints.destructive},
+		{"idempotentHint", expected.IdempotentHint, hints.idempotent},
+		{"openWorldHint", expected.OpenWorldHint, hints.openWorld},
+	} {
+		if !matchesHint(hint.want, hint.got) {
+			mismatches = append(mismatches, fmt.Sprintf("%s is %t, expected %t", hint.name, hint.got, *hint.want))
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("tool %q has unexpected annotations: %s", name, strings.Join(mismatches, "; "))
+	}
+	return nil
+}
+
+// lookupTool returns the named tool from the tool cache, or else lists the
+// tools without going through call, so that no request metrics are recorded.
+func (c *Client) lookupTool(name string) (*mcp.Tool, error) {
+	if t, ok := c.tools.get(name); ok {
+		return t, nil
+	}
+
+	_, err := await(c, func() (struct{}, error) {
+		params := &mcp.ListToolsParams{}
+		for {
+			res, err := c.session.ListTools(c.callContext(), params)
+			if err != nil {
+				return struct{}{}, fmt.Errorf("failed to list tools: %w", err)
+			}
+			c.rememberTools(res.Tools)
+			if res.NextCursor == "" {
+				return struct{}{}, nil
+			}
+			params.Cursor = res.NextCursor
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := c.tools.get(name); ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("tool %q not found", name)
+}
@@ -0,0 +1,297 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+var errNoToken = errors.New("no OAuth2 token was issued to the client")
+
+const (
+	authSchemeNone                    = "none"
+	authSchemeBearer                  = "bearer"
+	authSchemeBasic                   = "basic"
+	authSchemeOAuth2ClientCredentials = "oauth2_client_credentials"
+	authSchemeAPIKey                  = "api_key"
+
+	defaultAPIKeyHeader = "X-API-Key"
+)
+
+// effectiveScheme returns the configured scheme. Configs without a scheme
+// are inferred from the credential that is set, for backward compatibility.
+func (a AuthConfig) effectiveScheme() string {
+	if a.Scheme != "" {
+		return a.Scheme
+	}
+	if a.BearerToken != "" {
+		return authSchemeBearer
+	}
+	if a.APIKey != "" {
+		return authSchemeAPIKey
+	}
+	return authSchemeNone
+}
+
+// validate reports every field missing for, or not belonging to, the
+// configured scheme.
+func (a AuthConfig) validate() []error {
+	var errs []error
+
+	scheme := a.effectiveScheme()
+	fields := map[string]bool{
+		"bearer_token":       a.BearerToken != "",
+		"username":           a.Username != "",
+		"password":           a.Password != "",
+		"client_id":          a.ClientID != "",
+		"client_secret":      a.ClientSecret != "",
+		"token_url":          a.TokenURL != "",
+		"scopes":             len(a.Scopes) > 0,
+		"endpoint_params":    len(a.EndpointParams) > 0,
+		"token_type":         a.TokenType != "",
+		"api_key":            a.APIKey != "",
+		"api_key_header":     a.APIKeyHeader != "",
+		"direct_auth_header": a.DirectAuthHeader,
+	}
+
+	var required, allowed []string
+	switch scheme {
+	case authSchemeNone:
+	case authSchemeBearer:
+		required = []string{"bearer_token"}
+		allowed = []string{"direct_auth_header"}
+	case authSchemeBasic:
+		required = []string{"username"}
+		allowed = []string{"password"}
+	case authSchemeOAuth2ClientCredentials:
+		required = []string{"client_id", "client_secret", "token_url"}
+		allowed = []string{"scopes", "endpoint_params", "token_type", "direct_auth_header"}
+	case authSchemeAPIKey:
+		required = []string{"api_key"}
+		allowed = []string{"api_key_header"}
+	default:
+		return []error{fmt.Errorf("unknown auth scheme %q", scheme)}
+	}
+
+	for _, field := range required {
+		if !fields[field] {
+			errs = append(errs, fmt.Errorf("auth.%s is required for the %s scheme", field, scheme))
+		}
+		delete(fields, field)
+	}
+	for _, field := range allowed {
+		delete(fields, field)
+	}
+	for _, field := range slices.Sorted(maps.Keys(fields)) {
+		if fields[field] {
+			errs = append(errs, fmt.Errorf("auth.%s is not supported by the %s scheme", field, scheme))
+		}
+	}
+
+	return errs
+}
+
+// withAuth returns an HTTP client authenticating its requests as configured
+// by auth, on top of httpClient. The scopes of the OAuth2 tokens issued are
+// recorded in scopes, if set.
+func withAuth(httpClient *http.Client, auth AuthConfig, scopes *grantedScopes) *http.Client {
+	// Explicitly creating a dummy context for the oauth2 library
+	// to pull the http.Client from
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
+
+	switch auth.effectiveScheme() {
+	case authSchemeBearer:
+		token := oauth2.Token{
+			AccessToken: auth.BearerToken,
+		}
+		tokenSource := oauth2.StaticTokenSource(&token)
+
+		return withTokenSource(ctx, httpClient, tokenSource, auth.DirectAuthHeader)
+	case authSchemeBasic:
+		return &http.Client{
+			Transport: &basicAuthRoundTripper{
+				next:     httpClient.Transport,
+				username: auth.Username,
+				password: auth.Password,
+			},
+		}
+	case authSchemeOAuth2ClientCredentials:
+		cc := clientcredentials.Config{
+			ClientID:     auth.ClientID,
+			ClientSecret: auth.ClientSecret,
+			TokenURL:     auth.TokenURL,
+			Scopes:       auth.Scopes,
+		}
+		if len(auth.EndpointParams) > 0 {
+			cc.EndpointParams = make(url.Values, len(auth.EndpointParams))
+			for key, value := range auth.EndpointParams {
+				cc.EndpointParams.Set(key, value)
+			}
+		}
+
+		tokenSource := cc.TokenSource(ctx)
+		if scopes != nil {
+			tokenSource = &scopeRecordingSource{next: tokenSource, requested: auth.Scopes, scopes: scopes}
+		}
+		if auth.TokenType != "" {
+			tokenSource = &tokenTypeSource{next: tokenSource, tokenType: auth.TokenType}
+		}
+
+		return withTokenSource(ctx, httpClient, tokenSource, auth.DirectAuthHeader)
+	case authSchemeAPIKey:
+		header := auth.APIKeyHeader
+		if header == "" {
+			header = defaultAPIKeyHeader
+		}
+
+		return &http.Client{
+			Transport: &headerRoundTripper{
+				next:   httpClient.Transport,
+				header: header,
+				value:  auth.APIKey,
+			},
+		}
+	default:
+		return httpClient
+	}
+}
+
+// withTokenSource returns an HTTP client authenticating its requests with the
+// tokens of source. Unless direct is set, it is the client of oauth2, which
+// layers its own transport over the one of httpClient.
+func withTokenSource(ctx context.Context, httpClient *http.Client, source oauth2.TokenSource, direct bool) *http.Client {
+	if !direct {
+		return oauth2.NewClient(ctx, source)
+	}
+	return &http.Client{
+		Transport: &tokenRoundTripper{
+			next:   httpClient.Transport,
+			source: oauth2.ReuseTokenSource(nil, source),
+		},
+	}
+}
+
+// tokenTypeSource overrides the type of the tokens of next, for servers that
+// expect another scheme in the Authorization header than the one the tokens
+// are issued with.
+type tokenTypeSource struct {
+	next      oauth2.TokenSource
+	tokenType string
+}
+
+func (s *tokenTypeSource) Token() (*oauth2.Token, error) {
+	token, err := s.next.Token()
+	if err != nil {
+		return nil, err
+	}
+	typed := *token
+	typed.TokenType = s.tokenType
+	return &typed, nil
+}
+
+// grantedScopes holds the scopes of the last OAuth2 token issued.
+type grantedScopes struct {
+	mu     sync.Mutex
+	issued bool
+	scopes []string
+}
+
+func (g *grantedScopes) get() ([]string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return slices.Clone(g.scopes), g.issued
+}
+
+// scopeRecordingSource records the scopes of the tokens of next, as listed in
+// the token response, or the requested scopes when the response has no scope
+// field, which servers leave out when they granted the requested scopes as is.
+type scopeRecordingSource struct {
+	next      oauth2.TokenSource
+	requested []string
+	scopes    *grantedScopes
+}
+
+func (s *scopeRecordingSource) Token() (*oauth2.Token, error) {
+	token, err := s.next.Token()
+	if err != nil {
+		return nil, err
+	}
+	// The scope parameter is a space-delimited list.
+	granted := s.requested
+	if scope, ok := token.Extra("scope").(string); ok {
+		granted = strings.Fields(scope)
+	}
+	s.scopes.mu.Lock()
+	defer s.scopes.mu.Unlock()
+	s.scopes.issued = true
+	s.scopes.scopes = granted
+	return token, nil
+}
+
+// GrantedScopes returns the scopes of the last OAuth2 token issued to the
+// client, as listed in the token response, or the requested ones when the
+// server left them out. It fails when no token was issued to the client, such as
+// for schemes other than oauth2_client_credentials, or when the client
+// reuses a pooled session it did not connect.
+func (c *Client) GrantedScopes() ([]string, error) {
+	scopes, issued := c.scopes.get()
+	if !issued {
+		return nil, errNoToken
+	}
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return scopes, nil
+}
+
+type basicAuthRoundTripper struct {
+	next               http.RoundTripper
+	username, password string
+}
+
+func (t *basicAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.username, t.password)
+	return t.next.RoundTrip(req)
+}
+
+// headerRoundTripper sets a fixed header on every request.
+type headerRoundTripper struct {
+	next          http.RoundTripper
+	header, value string
+}
+
+func (t *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(t.header, t.value)
+	return t.next.RoundTrip(req)
+}
+
+// tokenRoundTripper sets the Authorization header of every request from the
+// current token of source, leaving the transport below it as is.
+type tokenRoundTripper struct {
+	next   http.RoundTripper
+	source oauth2.TokenSource
+}
+
+func (t *tokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token()
+	if err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	token.SetAuthHeader(req)
+	return t.next.RoundTrip(req)
+}
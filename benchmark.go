@@ -0,0 +1,118 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type (
+	// BenchmarkReadsOptions configures BenchmarkReadsByType. Duration is how
+	// long to keep reading, such as 30s.
+	BenchmarkReadsOptions struct {
+		Duration string
+	}
+
+	// ReadTypeSummary sums up the reads of the resources of one MIME type
+	// made by BenchmarkReadsByType. ReadsPerSecond is the throughput of the
+	// time spent reading them, and the durations are those of the reads that
+	// succeeded.
+	ReadTypeSummary struct {
+		MIMEType       string `js:"mime_type"`
+		Resources      int
+		Reads          int
+		Errors         int
+		ReadsPerSecond float64
+		AvgMs          float64
+		MinMs          float64
+		MaxMs          float64
+		P95Ms          float64 `js:"p95_ms"`
+	}
+)
+
+// BenchmarkReadsByType lists every resource, groups them by MIME type and,
+// for opts.Duration, reads one resource of every type after the other,
+// cycling through the resources of each type. The request metrics of the
+// reads are tagged with mime_type, and the returned summaries are sorted by
+// it. Reads bypass the cache.
+func (c *Client) BenchmarkReadsByType(opts BenchmarkReadsOptions) ([]ReadTypeSummary, error) {
+	if opts.Duration == "" {
+		return nil, errors.New("duration is required")
+	}
+	duration, err := time.ParseDuration(opts.Duration)
+	if err != nil || duration <= 0 {
+		return nil, fmt.Errorf("invalid duration %q", opts.Duration)
+	}
+
+	all, err := c.ListAllResources(ListAllResourcesParams{})
+	if err != nil {
+		return nil, err
+	}
+	byType := make(map[string][]string)
+	for _, res := range all.Resources {
+		byType[res.MIMEType] = append(byType[res.MIMEType], res.URI)
+	}
+	types := slices.Sorted(maps.Keys(byType))
+
+	summaries := make([]ReadTypeSummary, len(types))
+	durations := make([][]time.Duration, len(types))
+	for i, mimeType := range types {
+		summaries[i] = ReadTypeSummary{MIMEType: mimeType, Resources: len(byType[mimeType])}
+	}
+
+	deadline := c.metrics.Now().Add(duration)
+	for round := 0; len(types) > 0 && c.metrics.Now().Before(deadline); round++ {
+		for i, mimeType := range types {
+			uris := byType[mimeType]
+			uri := uris[round%len(uris)]
+
+			start := c.metrics.Now()
+			_, err := callWithTags(c, ReadResourceMethod, map[string]string{"mime_type": mimeType}, func(ctx context.Context) (*mcp.ReadResourceResult, error) {
+				params := &mcp.ReadResourceParams{URI: uri}
+				recordParams(ctx, params)
+				return c.session.ReadResource(ctx, params)
+			})
+			summaries[i].Reads++
+			if err != nil {
+				summaries[i].Errors++
+				continue
+			}
+			durations[i] = append(durations[i], c.metrics.Since(start))
+		}
+	}
+
+	for i := range summaries {
+		summarizeReads(&summaries[i], durations[i])
+	}
+	return summaries, nil
+}
+
+// summarizeReads fills in the throughput and latencies of s from the
+// durations of its successful reads.
+func summarizeReads(s *ReadTypeSummary, durations []time.Duration) {
+	if len(durations) == 0 {
+		return
+	}
+	slices.Sort(durations)
+
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+
+	if total > 0 {
+		s.ReadsPerSecond = float64(len(durations)) / total.Seconds()
+	}
+	s.AvgMs = ms(total) / float64(len(durations))
+	s.MinMs = ms(durations[0])
+	s.MaxMs = ms(durations[len(durations)-1])
+	s.P95Ms = ms(durations[(len(durations)*95+99)/100-1])
+}
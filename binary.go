@@ -0,0 +1,53 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/sobek"
+)
+
+// binaryArgumentsMetaKey is the _meta key listing, as JSON pointers into the
+// tool arguments, every value that was sent as base64.
+const binaryArgumentsMetaKey = "xk6-mcp/base64Arguments"
+
+// encodeBinaryArguments replaces ArrayBuffer and Uint8Array values in args
+// with their standard base64 encoding. It returns the updated arguments, the
+// JSON pointers of the replaced values and the number of raw bytes encoded.
+func encodeBinaryArguments(args any) (any, []string, int) {
+	var (
+		pointers []string
+		size     int
+	)
+
+	var walk func(v any, pointer string) any
+	walk = func(v any, pointer string) any {
+		switch v := v.(type) {
+		case sobek.ArrayBuffer:
+			return walk(v.Bytes(), pointer)
+		case []byte:
+			pointers = append(pointers, pointer)
+			size += len(v)
+			return base64.StdEncoding.EncodeToString(v)
+		case map[string]any:
+			for k, item := range v {
+				v[k] = walk(item, pointer+"/"+escapePointerToken(k))
+			}
+			return v
+		case []any:
+			for i, item := range v {
+				v[i] = walk(item, pointer+"/"+strconv.Itoa(i))
+			}
+			return v
+		default:
+			return v
+		}
+	}
+
+	return walk(args, ""), pointers, size
+}
+
+func escapePointerToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
@@ -0,0 +1,131 @@
+package mcp
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+// The scopes of circuit breakers.
+const (
+	breakerScopeClient   = "client"
+	breakerScopeEndpoint = "endpoint"
+)
+
+// circuitState is reported as the value of the mcp_circuit_state gauge.
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+// circuitBreaker fails calls locally after threshold consecutive failures.
+// Once openDuration has elapsed, a single probe call is let through: its
+// success closes the breaker again, its failure re-opens it.
+type circuitBreaker struct {
+	mu           sync.Mutex
+	threshold    int
+	openDuration time.Duration
+	failures     int
+	state        circuitState
+	openedAt     time.Time
+}
+
+func newCircuitBreaker(threshold int, openDuration time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold:    threshold,
+		openDuration: openDuration,
+	}
+}
+
+// circuitBreaker returns the circuit breaker configured by cfg for a client of
+// baseURL. Breakers of endpoint scope are shared by every client of baseURL,
+// in every VU, and configured by the first of them.
+func (r *RootModule) circuitBreaker(baseURL string, cfg CircuitBreakerConfig) *circuitBreaker {
+	threshold, openDuration := cfg.FailureThreshold, durationOrZero(cfg.OpenDuration)
+	if cfg.Scope != breakerScopeEndpoint {
+		return newCircuitBreaker(threshold, openDuration)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.breakers == nil {
+		r.breakers = make(map[string]*circuitBreaker)
+	}
+	b, ok := r.breakers[baseURL]
+	if !ok {
+		b = newCircuitBreaker(threshold, openDuration)
+		r.breakers[baseURL] = b
+	}
+	return b
+}
+
+// allow reports whether a call may be sent to the server at now, along with
+// the state the call is let through in, which its outcome is recorded with.
+func (b *circuitBreaker) allow(now time.Time) (circuitState, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if now.Sub(b.openedAt) < b.openDuration {
+			return b.state, false
+		}
+		b.state = circuitHalfOpen
+		return circuitHalfOpen, true
+	case circuitHalfOpen:
+		// The probe call is still in flight.
+		return b.state, false
+	default:
+		return circuitClosed, true
+	}
+}
+
+// abandon gives back the admission of a call that was never sent, so that a
+// probe it was let through as can be made by the next call instead.
+func (b *circuitBreaker) abandon(admitted circuitState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if admitted == circuitHalfOpen && b.state == circuitHalfOpen {
+		b.state = circuitOpen
+	}
+}
+
+// record updates the breaker at now with the outcome of a call sent to the
+// server, let through in the admitted state, returning the resulting state
+// and whether it changed. Only the probe call decides whether the breaker
+// closes again: calls let through before it opened are ignored once it did.
+func (b *circuitBreaker) record(admitted circuitState, err error, now time.Time) (circuitState, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	prev := b.state
+	switch {
+	case admitted == circuitHalfOpen:
+		if err == nil {
+			b.failures = 0
+			b.state = circuitClosed
+		} else {
+			b.state = circuitOpen
+			b.openedAt = now
+		}
+	case b.state != circuitClosed:
+		// The call was let through before the breaker opened.
+	case err == nil:
+		b.failures = 0
+	default:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.state = circuitOpen
+			b.openedAt = now
+		}
+	}
+
+	return b.state, b.state != prev
+}
@@ -0,0 +1,84 @@
+package mcp
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// readCache holds resources/read results by URI. Entries expire after ttl,
+// or never when ttl is zero, and are dropped when the server reports the
+// resource as updated. Results are copied in and out, since scripts may
+// modify the ones they get.
+type readCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]readCacheEntry
+}
+
+type readCacheEntry struct {
+	result  *mcp.ReadResourceResult
+	expires time.Time
+}
+
+func newReadCache(ttl time.Duration) *readCache {
+	return &readCache{
+		ttl:     ttl,
+		entries: make(map[string]readCacheEntry),
+	}
+}
+
+func (rc *readCache) get(uri string) (*mcp.ReadResourceResult, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	entry, ok := rc.entries[uri]
+	if !ok {
+		return nil, false
+	}
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		delete(rc.entries, uri)
+		return nil, false
+	}
+
+	return cloneReadResult(entry.result), true
+}
+
+func (rc *readCache) put(uri string, result *mcp.ReadResourceResult) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	entry := readCacheEntry{result: cloneReadResult(result)}
+	if rc.ttl > 0 {
+		entry.expires = time.Now().Add(rc.ttl)
+	}
+	rc.entries[uri] = entry
+}
+
+func (rc *readCache) invalidate(uri string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	delete(rc.entries, uri)
+}
+
+// cloneReadResult returns a deep copy of result.
+func cloneReadResult(result *mcp.ReadResourceResult) *mcp.ReadResourceResult {
+	clone := &mcp.ReadResourceResult{
+		Meta:     maps.Clone(result.Meta),
+		Contents: make([]*mcp.ResourceContents, len(result.Contents)),
+	}
+	for i, content := range result.Contents {
+		if content == nil {
+			continue
+		}
+		c := *content
+		c.Blob = slices.Clone(content.Blob)
+		c.Meta = maps.Clone(content.Meta)
+		clone.Contents[i] = &c
+	}
+	return clone
+}
@@ -0,0 +1,213 @@
+package mcp
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// call runs fn as the given MCP method on behalf of a JS wrapper, applying the
+// client-side policies configured on c and recording the request metrics.
+func call[T any](c *Client, method string, fn func(context.Context) (T, error)) (T, error) {
+	return callWithTags(c, method, nil, fn)
+}
+
+// callWithTags works like call, adding tags to the request metrics.
+func callWithTags[T any](c *Client, method string, tags map[string]string, fn func(context.Context) (T, error)) (T, error) {
+	return callNamed(c, method, "", tags, fn)
+}
+
+// callNamed works like callWithTags for a call made for the named tool, whose
+// timeout may differ from the one of method.
+func callNamed[T any](c *Client, method, name string, tags map[string]string, fn func(context.Context) (T, error)) (T, error) {
+	c.notifications.iteration.Store(c.iteration())
+
+	res, err := await(c, func() (T, error) {
+		return invoke(c, method, name, tags, strictly(c, intercepting(c, fn)))
+	})
+	c.abortOnLatencyGuard(err)
+	return res, err
+}
+
+// invoke does the work of callNamed off the VU goroutine, so several calls
+// can be in flight at once inside a single await.
+func invoke[T any](c *Client, method, name string, tags map[string]string, fn func(context.Context) (T, error)) (T, error) {
+	var zero T
+
+	if c.released.Load() {
+		return zero, errClientReleased
+	}
+
+	var admitted circuitState
+	if c.breaker != nil {
+		var ok bool
+		if admitted, ok = c.breaker.allow(c.metrics.Now()); !ok {
+			c.metrics.PushCircuitRejected(c.ctx, method, tags)
+			c.stats.recordCall(errCircuitOpen)
+			return zero, errCircuitOpen
+		}
+	}
+
+	ctx := c.callContext()
+	if timeout := c.timeouts.get(method, name, c.toolTimeoutHint(name)); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var metadata map[string]string
+	if c.tracing {
+		tc := newTraceContext()
+		ctx = withTraceContext(ctx, tc)
+		metadata = map[string]string{traceIDMetadata: tc.traceID}
+	}
+	if c.emitDefaults != nil {
+		ctx = withEmitDefaults(ctx, *c.emitDefaults)
+	}
+	if c.correlation {
+		id := newCorrelationID()
+		ctx = withCorrelationID(ctx, id)
+		metadata = withTags(metadata, map[string]string{correlationIDMetadata: id})
+	}
+
+	start := c.metrics.Now()
+	var (
+		res T
+		err error
+	)
+	// Calls failed by an injected fault never reached the server, so they are
+	// kept out of the breaker and of the errors of the stats.
+	var faulted bool
+	if c.faults != nil {
+		var injected bool
+		if injected, err = c.faults.inject(ctx); injected {
+			tags = withTags(tags, map[string]string{"injected": "true"})
+		}
+		faulted = err != nil
+	}
+	var (
+		params   any
+		attempts *requestAttempts
+	)
+	if err == nil {
+		resultTags := make(map[string]string)
+		fnCtx := context.WithValue(ctx, resultTagsKey{}, resultTags)
+		if c.recorder != nil {
+			fnCtx = context.WithValue(fnCtx, callParamsKey{}, &params)
+		}
+		fnCtx, status := withHTTPStatus(fnCtx)
+		fnCtx, attempts = withRequestAttempts(fnCtx)
+		res, err = fn(fnCtx)
+		if len(resultTags) > 0 {
+			tags = withTags(tags, resultTags)
+		}
+		err = statusError(err, status)
+		if extra := statusTags(err); extra != nil {
+			tags = withTags(tags, extra)
+		}
+	}
+	if cancelled := cancelledTag(ctx, err); cancelled != "" {
+		tags = withTags(tags, map[string]string{"cancelled": cancelled})
+		if cancelled == "abort" {
+			err = errCallAborted
+		}
+	}
+	duration := c.metrics.Since(start)
+	requestDuration := duration
+	if c.retries > 0 && attempts != nil {
+		requestDuration, tags = c.pushTotalDuration(method, attempts, duration, tags)
+	}
+	c.metrics.PushWithMetadata(c.ctx, method, requestDuration, err, tags, metadata)
+	if c.emitChecks {
+		c.metrics.PushCheck(c.ctx, checkName(method, name), checkPassed(res, err))
+	}
+	if err == nil {
+		c.pushServerDuration(method, res)
+	}
+	if faulted {
+		c.stats.recordInjectedFault()
+	} else {
+		c.stats.recordCall(err)
+	}
+	if c.recorder != nil {
+		c.recorder.record(method, params, res, err, duration, start)
+	}
+
+	if c.breaker != nil && faulted {
+		c.breaker.abandon(admitted)
+	} else if c.breaker != nil {
+		if state, changed := c.breaker.record(admitted, err, c.metrics.Now()); changed {
+			c.metrics.PushCircuitState(c.ctx, float64(state), c.breakerEndpoint)
+		}
+	}
+
+	// A call breaching a latency guard went through, and is recorded as such
+	// before being failed.
+	if method == CallToolMethod {
+		if guardErr := c.guardLatency(name, duration); guardErr != nil && err == nil {
+			var zero T
+			return zero, guardErr
+		}
+	}
+
+	return res, err
+}
+
+// pushTotalDuration records the duration of a call made by a client retrying
+// rate-limited requests, retries and their delays included, tagged with the
+// number of attempts. It returns the duration of the last attempt, which is
+// the request duration of the call, and the tags with attempts.
+func (c *Client) pushTotalDuration(method string, attempts *requestAttempts, duration time.Duration, tags map[string]string) (time.Duration, map[string]string) {
+	count, last := attempts.get()
+	if count == 0 {
+		// No HTTP request was made, as when a fault was injected.
+		return duration, tags
+	}
+	tags = withTags(tags, map[string]string{"attempts": strconv.Itoa(count)})
+	c.metrics.PushRequestTotalDuration(c.ctx, method, duration, tags)
+	if count == 1 {
+		return duration, tags
+	}
+	return c.metrics.Since(last), tags
+}
+
+// checkName is the name of the check recorded for a call with EmitChecks.
+func checkName(method, name string) string {
+	if name == "" {
+		return method
+	}
+	return method + " " + name
+}
+
+// checkPassed reports whether a call passes its check: it must not have failed
+// nor, for tool calls, returned a result flagged with isError.
+func checkPassed(res any, err error) bool {
+	if err != nil {
+		return false
+	}
+	result, ok := res.(*mcp.CallToolResult)
+	return !ok || result == nil || !result.IsError
+}
+
+type resultTagsKey struct{}
+
+// setResultTag adds a tag to the request metrics of the call running with
+// ctx, for tags that depend on how the call went.
+func setResultTag(ctx context.Context, key, value string) {
+	if tags, ok := ctx.Value(resultTagsKey{}).(map[string]string); ok {
+		tags[key] = value
+	}
+}
+
+// withTags returns the union of base and extra, with extra taking precedence.
+func withTags(base, extra map[string]string) map[string]string {
+	tags := make(map[string]string, len(base)+len(extra))
+	for k, v := range base {
+		tags[k] = v
+	}
+	for k, v := range extra {
+		tags[k] = v
+	}
+	return tags
+}
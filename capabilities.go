@@ -0,0 +1,51 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RequiredCapabilities lists the server capabilities a script depends on.
+// Capabilities left false are not checked.
+type RequiredCapabilities struct {
+	Tools       bool
+	Resources   bool
+	Prompts     bool
+	Logging     bool
+	Completions bool
+}
+
+// RequireCapabilities fails if the server did not advertise every required
+// capability during initialization. It is meant to be called in setup() so a
+// run against the wrong server build stops early.
+func (c *Client) RequireCapabilities(r RequiredCapabilities) error {
+	init := c.initializeResult()
+	if init == nil || init.Capabilities == nil {
+		return errors.New("server did not advertise any capabilities")
+	}
+	caps := init.Capabilities
+
+	var missing []string
+	if r.Tools && caps.Tools == nil {
+		missing = append(missing, "tools")
+	}
+	if r.Resources && caps.Resources == nil {
+		missing = append(missing, "resources")
+	}
+	if r.Prompts && caps.Prompts == nil {
+		missing = append(missing, "prompts")
+	}
+	if r.Logging && caps.Logging == nil {
+		missing = append(missing, "logging")
+	}
+	if r.Completions && caps.Completions == nil {
+		missing = append(missing, "completions")
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("server is missing required capabilities: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
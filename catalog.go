@@ -0,0 +1,115 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ListResourceTemplatesMethod is the method listing the resource templates of
+// the server.
+const ListResourceTemplatesMethod = "resources/templates/list"
+
+// Catalog is everything the server offers, as returned by Client.Catalog.
+type Catalog struct {
+	Tools             []mcp.Tool
+	Resources         []mcp.Resource
+	ResourceTemplates []mcp.ResourceTemplate
+	Prompts           []mcp.Prompt
+}
+
+// Catalog lists every tool, resource, resource template and prompt of the
+// server, the four enumerations running at once, each page being recorded in
+// the request metrics like the calls of listAllTools and its siblings. It
+// fails with the errors of every enumeration that failed.
+func (c *Client) Catalog() (*Catalog, error) {
+	var (
+		catalog Catalog
+		errs    [4]error
+	)
+
+	c.notifications.iteration.Store(c.iteration())
+	_, _ = await(c, func() (struct{}, error) {
+		enumerations := []func(){
+			func() {
+				catalog.Tools, errs[0] = listPages(c, ListToolsMethod, "tools",
+					func(ctx context.Context, cursor string) (*mcp.ListToolsResult, error) {
+						return c.listTools(ctx, &mcp.ListToolsParams{Cursor: cursor})
+					},
+					func(res *mcp.ListToolsResult) ([]*mcp.Tool, string) { return res.Tools, res.NextCursor })
+			},
+			func() {
+				catalog.Resources, errs[1] = listPages(c, ListResourcesMethod, "resources",
+					func(ctx context.Context, cursor string) (*mcp.ListResourcesResult, error) {
+						return c.listResources(ctx, &mcp.ListResourcesParams{Cursor: cursor})
+					},
+					func(res *mcp.ListResourcesResult) ([]*mcp.Resource, string) { return res.Resources, res.NextCursor })
+			},
+			func() {
+				catalog.ResourceTemplates, errs[2] = listPages(c, ListResourceTemplatesMethod, "resource templates",
+					func(ctx context.Context, cursor string) (*mcp.ListResourceTemplatesResult, error) {
+						return c.listResourceTemplates(ctx, &mcp.ListResourceTemplatesParams{Cursor: cursor})
+					},
+					func(res *mcp.ListResourceTemplatesResult) ([]*mcp.ResourceTemplate, string) {
+						return res.ResourceTemplates, res.NextCursor
+					})
+			},
+			func() {
+				catalog.Prompts, errs[3] = listPages(c, ListPromptsMethod, "prompts",
+					func(ctx context.Context, cursor string) (*mcp.ListPromptsResult, error) {
+						return c.listPrompts(ctx, &mcp.ListPromptsParams{Cursor: cursor})
+					},
+					func(res *mcp.ListPromptsResult) ([]*mcp.Prompt, string) { return res.Prompts, res.NextCursor })
+			},
+		}
+		var wg sync.WaitGroup
+		for _, enumerate := range enumerations {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				enumerate()
+			}()
+		}
+		wg.Wait()
+		return struct{}{}, nil
+	})
+
+	if err := errors.Join(errs[:]...); err != nil {
+		return nil, err
+	}
+	return &catalog, nil
+}
+
+// listPages lists every page of method off the VU goroutine, for Catalog,
+// collecting the items page returns from each result.
+func listPages[R any, T any](
+	c *Client,
+	method, what string,
+	list func(ctx context.Context, cursor string) (R, error),
+	page func(R) ([]*T, string),
+) ([]T, error) {
+	var all []T
+	cursor := ""
+	for {
+		res, err := invoke(c, method, "", nil, strictly(c, intercepting(c, func(ctx context.Context) (R, error) {
+			return list(ctx, cursor)
+		})))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", what, err)
+		}
+
+		items, next := page(res)
+		for _, item := range items {
+			if item != nil {
+				all = append(all, *item)
+			}
+		}
+		if next == "" {
+			return all, nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,140 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type (
+	// ClientComparison calls two clients the same way and compares their
+	// results, for validating parity between two servers.
+	ClientComparison struct {
+		a, b *Client
+	}
+
+	ComparisonResult struct {
+		A     *mcp.CallToolResult
+		B     *mcp.CallToolResult
+		Equal bool
+		// Diff lists every JSON value that differs between A and B.
+		Diff []Difference
+	}
+
+	// Difference is a value found at Path, a JSON pointer, in one or both
+	// results. A side without the value holds null.
+	Difference struct {
+		Path string
+		A    any
+		B    any
+	}
+)
+
+func (m *MCPInstance) compareClients(a, b *Client) (*ClientComparison, error) {
+	if a == nil || b == nil {
+		return nil, errors.New("CompareClients expects two clients")
+	}
+	return &ClientComparison{a: a, b: b}, nil
+}
+
+// CompareTool calls the tool on both clients, one after the other, tagging
+// their request metrics with server=a or server=b.
+func (cc *ClientComparison) CompareTool(r mcp.CallToolParams) (*ComparisonResult, error) {
+	a, err := cc.a.callTool(r, map[string]string{"server": "a"})
+	if err != nil {
+		return nil, fmt.Errorf("server a: %w", err)
+	}
+	b, err := cc.b.callTool(r, map[string]string{"server": "b"})
+	if err != nil {
+		return nil, fmt.Errorf("server b: %w", err)
+	}
+
+	diff, err := diffJSON(a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ComparisonResult{A: a, B: b, Equal: len(diff) == 0, Diff: diff}, nil
+}
+
+// diffJSON compares a and b through their JSON representation.
+func diffJSON(a, b any) ([]Difference, error) {
+	var va, vb any
+	for _, p := range []struct {
+		in  any
+		out *any
+	}{{a, &va}, {b, &vb}} {
+		data, err := json.Marshal(p.in)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, p.out); err != nil {
+			return nil, err
+		}
+	}
+
+	var diff []Difference
+	collectDiff("", va, vb, true, true, &diff)
+	return diff, nil
+}
+
+func collectDiff(path string, a, b any, hasA, hasB bool, diff *[]Difference) {
+	if hasA && hasB {
+		switch a := a.(type) {
+		case map[string]any:
+			if b, ok := b.(map[string]any); ok {
+				keys := slices.Collect(maps.Keys(a))
+				for k := range b {
+					if _, ok := a[k]; !ok {
+						keys = append(keys, k)
+					}
+				}
+				slices.Sort(keys)
+				for _, k := range keys {
+					va, okA := a[k]
+					vb, okB := b[k]
+					collectDiff(path+"/"+escapePointer(k), va, vb, okA, okB, diff)
+				}
+				return
+			}
+		case []any:
+			if b, ok := b.([]any); ok {
+				for i := range max(len(a), len(b)) {
+					var va, vb any
+					if i < len(a) {
+						va = a[i]
+					}
+					if i < len(b) {
+						vb = b[i]
+					}
+					collectDiff(path+"/"+strconv.Itoa(i), va, vb, i < len(a), i < len(b), diff)
+				}
+				return
+			}
+		}
+		if reflect.DeepEqual(a, b) {
+			return
+		}
+	}
+
+	d := Difference{Path: path}
+	if hasA {
+		d.A = a
+	}
+	if hasB {
+		d.B = b
+	}
+	*diff = append(*diff, d)
+}
+
+// escapePointer escapes a key for use as a JSON pointer token.
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
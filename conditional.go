@@ -0,0 +1,159 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ConditionalReadResult is the result of a resource read by
+// ReadResourceConditional. When the server reports the resource as not
+// modified, it has no contents.
+type ConditionalReadResult struct {
+	mcp.ReadResourceResult
+	NotModified bool
+	// ETag is the entity tag of the resource, as last sent by the server
+	ETag string `js:"etag"`
+}
+
+type conditionalReadKey struct{}
+
+// conditionalRead carries the entity tag of a conditional read to its HTTP
+// request, and how the server answered back.
+type conditionalRead struct {
+	etag string
+
+	mu          sync.Mutex
+	notModified bool
+	responseTag string
+}
+
+func (r *conditionalRead) answered(resp *http.Response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if resp.StatusCode == http.StatusNotModified {
+		r.notModified = true
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		r.responseTag = etag
+	}
+}
+
+// ReadResourceConditional reads the resource at uri unless it still has the
+// entity tag etag, which is sent as If-None-Match. Servers answer with HTTP
+// status 304 when the resource is unchanged, in which case the result is
+// flagged as not modified and has no contents. The request metrics are tagged
+// with conditional=hit for those, and conditional=miss otherwise.
+//
+// Only Streamable HTTP requests carry the entity tag, so the reads of other
+// transports always miss. Reads bypass the cache.
+func (c *Client) ReadResourceConditional(uri, etag string) (*ConditionalReadResult, error) {
+	return call(c, ReadResourceMethod, func(ctx context.Context) (*ConditionalReadResult, error) {
+		params := &mcp.ReadResourceParams{URI: uri}
+		recordParams(ctx, params)
+		cond := &conditionalRead{etag: etag}
+		res, err := c.session.ReadResource(context.WithValue(ctx, conditionalReadKey{}, cond), params)
+		if err != nil {
+			return nil, err
+		}
+
+		cond.mu.Lock()
+		defer cond.mu.Unlock()
+		result := &ConditionalReadResult{NotModified: cond.notModified, ETag: cond.responseTag}
+		if result.ETag == "" && result.NotModified {
+			result.ETag = etag
+		}
+		if result.NotModified {
+			setResultTag(ctx, "conditional", "hit")
+		} else {
+			result.ReadResourceResult = *res
+			setResultTag(ctx, "conditional", "miss")
+		}
+		return result, nil
+	})
+}
+
+// conditionalRoundTripper sends the entity tag of conditional reads as
+// If-None-Match. The SDK fails on any status other than 2xx, so a 304 answer
+// is turned into a result without contents, for the read to report as not
+// modified.
+type conditionalRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (t *conditionalRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	cond, ok := req.Context().Value(conditionalReadKey{}).(*conditionalRead)
+	if !ok || req.Method != http.MethodPost {
+		return t.next.RoundTrip(req)
+	}
+
+	if cond.etag != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cond.etag)
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	cond.answered(resp)
+	if resp.StatusCode != http.StatusNotModified {
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+
+	body, err := notModifiedResponse(req)
+	if err != nil {
+		return nil, err
+	}
+	header := resp.Header.Clone()
+	header.Set("Content-Type", "application/json")
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         resp.Proto,
+		ProtoMajor:    resp.ProtoMajor,
+		ProtoMinor:    resp.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// notModifiedResponse encodes the empty resources/read result answering the
+// request of req.
+func notModifiedResponse(req *http.Request) ([]byte, error) {
+	if req.GetBody == nil {
+		return nil, errors.New("the body of the conditional read request cannot be read again")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = body.Close() }()
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := jsonrpc.DecodeMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+	call, ok := msg.(*jsonrpc.Request)
+	if !ok || call.Method != ReadResourceMethod {
+		return nil, errors.New("the conditional read request is not a resources/read call")
+	}
+
+	result, err := json.Marshal(&mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{}})
+	if err != nil {
+		return nil, err
+	}
+	return jsonrpc.EncodeMessage(&jsonrpc.Response{ID: call.ID, Result: result})
+}
@@ -0,0 +1,203 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	stdioTransport          = "stdio"
+	sseTransport            = "sse"
+	streamableHTTPTransport = "streamable-http"
+)
+
+// ValidateConfig checks cfg for consistency with the given transport before any
+// process is spawned or network call is made. Every problem found is reported
+// in a single aggregated error.
+func ValidateConfig(cfg ClientConfig, transport string) error {
+	var errs []error
+
+	switch transport {
+	case stdioTransport:
+		if cfg.Path == "" {
+			errs = append(errs, errors.New("path is required for stdio clients"))
+		}
+		if cfg.BaseURL != "" {
+			errs = append(errs, errors.New("base_url is not supported for stdio clients"))
+		}
+		if cfg.Auth.effectiveScheme() != authSchemeNone {
+			errs = append(errs, errors.New("auth is not supported for stdio clients"))
+		}
+		if cfg.Stateless {
+			errs = append(errs, errors.New("stateless is not supported for stdio clients"))
+		}
+		if cfg.CompressRequests {
+			errs = append(errs, errors.New("compress_requests is not supported for stdio clients"))
+		}
+		if cfg.DetailedTimings {
+			errs = append(errs, errors.New("detailed_timings is not supported for stdio clients"))
+		}
+		if len(cfg.Middlewares) > 0 {
+			errs = append(errs, errors.New("middlewares are not supported for stdio clients"))
+		}
+		if cfg.Tracing || len(cfg.Baggage) > 0 {
+			errs = append(errs, errors.New("tracing and baggage are not supported for stdio clients"))
+		}
+		if cfg.CorrelationIDHeader != "" {
+			errs = append(errs, errors.New("correlation_id_header is not supported for stdio clients"))
+		}
+		if cfg.KeepAlivePingInterval != "" {
+			errs = append(errs, errors.New("keep_alive_ping_interval is not supported for stdio clients"))
+		}
+		if cfg.Retries != 0 {
+			errs = append(errs, errors.New("retries are not supported for stdio clients"))
+		}
+		if cfg.MarshalOptions.EscapeHTML != nil {
+			errs = append(errs, errors.New("marshal_options.escape_html is not supported for stdio clients"))
+		}
+		if cfg.TLSSessionCache {
+			errs = append(errs, errors.New("tls_session_cache is not supported for stdio clients"))
+		}
+		if cfg.ReadBufferSize != 0 || cfg.DisableCompression {
+			errs = append(errs, errors.New("read_buffer_size and disable_compression are not supported for stdio clients"))
+		}
+		if cfg.CircuitBreaker.Scope == breakerScopeEndpoint {
+			errs = append(errs, errors.New("circuit_breaker.scope endpoint is not supported for stdio clients, whose servers are not shared"))
+		}
+		switch cfg.Framing {
+		case "", framingNewline, framingContentLength:
+		default:
+			errs = append(errs, fmt.Errorf("unknown framing %q, expected %s or %s", cfg.Framing, framingNewline, framingContentLength))
+		}
+	case sseTransport, streamableHTTPTransport:
+		if cfg.BaseURL == "" {
+			errs = append(errs, fmt.Errorf("base_url is required for %s clients", transport))
+		} else if u, err := url.Parse(cfg.BaseURL); err != nil {
+			errs = append(errs, fmt.Errorf("invalid base_url: %w", err))
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			errs = append(errs, fmt.Errorf("base_url must use http or https, got %q", u.Scheme))
+		}
+		if cfg.Path != "" || len(cfg.Args) > 0 || len(cfg.Env) > 0 {
+			errs = append(errs, fmt.Errorf("path, args and env are not supported for %s clients", transport))
+		}
+		if cfg.EnvFile != "" {
+			errs = append(errs, fmt.Errorf("env_file is not supported for %s clients", transport))
+		}
+		if cfg.DumpFrames != "" {
+			errs = append(errs, fmt.Errorf("dump_frames is not supported for %s clients", transport))
+		}
+		if cfg.Framing != "" {
+			errs = append(errs, fmt.Errorf("framing is not supported for %s clients", transport))
+		}
+		if transport == sseTransport && cfg.Stateless {
+			errs = append(errs, errors.New("stateless is not supported for sse clients"))
+		}
+		errs = append(errs, cfg.Auth.validate()...)
+		errs = append(errs, validateMiddlewares(cfg.Middlewares, cfg.MiddlewareOptions)...)
+		if len(cfg.Baggage) > 0 && !cfg.Tracing {
+			errs = append(errs, errors.New("baggage requires tracing"))
+		}
+		errs = append(errs, checkBaggage(cfg.Baggage)...)
+		if err := checkCorrelationIDHeader(cfg.CorrelationIDHeader); err != nil {
+			errs = append(errs, err)
+		}
+		if cfg.ReadBufferSize < 0 {
+			errs = append(errs, errors.New("read_buffer_size must not be negative"))
+		}
+		if cfg.Retries < 0 {
+			errs = append(errs, errors.New("retries must not be negative"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown transport %q", transport))
+	}
+
+	if err := checkDuration("cache_ttl", cfg.CacheTTL); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkDuration("timeout", cfg.Timeout); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, checkMethodTimeouts(cfg.MethodTimeouts)...)
+	if err := checkDuration("keep_alive_ping_interval", cfg.KeepAlivePingInterval); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkDuration("connect_jitter", cfg.ConnectJitter); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.CircuitBreaker.FailureThreshold < 0 {
+		errs = append(errs, errors.New("circuit_breaker.failure_threshold must not be negative"))
+	}
+	if cfg.CircuitBreaker.FailureThreshold > 0 && cfg.CircuitBreaker.OpenDuration == "" {
+		errs = append(errs, errors.New("circuit_breaker.open_duration is required"))
+	}
+	if err := checkDuration("circuit_breaker.open_duration", cfg.CircuitBreaker.OpenDuration); err != nil {
+		errs = append(errs, err)
+	}
+	switch cfg.CircuitBreaker.Scope {
+	case "", breakerScopeClient, breakerScopeEndpoint:
+	default:
+		errs = append(errs, fmt.Errorf("unknown circuit_breaker.scope %q", cfg.CircuitBreaker.Scope))
+	}
+
+	if err := checkRate("fault_injection.error_rate", cfg.FaultInjection.ErrorRate); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkRate("fault_injection.latency_rate", cfg.FaultInjection.LatencyRate); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.FaultInjection.LatencyMs < 0 {
+		errs = append(errs, errors.New("fault_injection.latency_ms must not be negative"))
+	}
+
+	if cfg.NotificationBufferSize < 0 {
+		errs = append(errs, errors.New("notification_buffer_size must not be negative"))
+	}
+
+	if !isNullish(cfg.OnCreateMessage) {
+		if _, ok := sobek.AssertFunction(cfg.OnCreateMessage); !ok {
+			errs = append(errs, errors.New("on_create_message must be a function"))
+		}
+	}
+	if !isNullish(cfg.OnElicit) {
+		if _, ok := sobek.AssertFunction(cfg.OnElicit); !ok {
+			errs = append(errs, errors.New("on_elicit must be a function"))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	return nil
+}
+
+func isNullish(v sobek.Value) bool {
+	return v == nil || sobek.IsUndefined(v) || sobek.IsNull(v)
+}
+
+// checkDuration reports whether value, if set, is a valid non-negative
+// duration such as "30s".
+func checkDuration(name, value string) error {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	if d < 0 {
+		return fmt.Errorf("%s must not be negative", name)
+	}
+	return nil
+}
+
+// durationOrZero parses a duration already checked by ValidateConfig.
+func durationOrZero(value string) time.Duration {
+	d, _ := time.ParseDuration(value)
+	return d
+}
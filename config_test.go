@@ -0,0 +1,137 @@
+package mcp_test
+
+import (
+	"testing"
+
+	mcp "github.com/grafana/xk6-mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	t.Run("valid stdio", func(t *testing.T) {
+		assert.NoError(t, mcp.ValidateConfig(mcp.ClientConfig{Path: "./server"}, "stdio"))
+	})
+
+	t.Run("valid streamable http", func(t *testing.T) {
+		assert.NoError(t, mcp.ValidateConfig(mcp.ClientConfig{BaseURL: "http://localhost:3001"}, "streamable-http"))
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		err := mcp.ValidateConfig(mcp.ClientConfig{}, "stdio")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "path is required")
+	})
+
+	t.Run("aggregates every problem", func(t *testing.T) {
+		err := mcp.ValidateConfig(mcp.ClientConfig{Path: "./server", Stateless: true}, "sse")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "base_url is required")
+		assert.Contains(t, err.Error(), "path, args and env are not supported")
+		assert.Contains(t, err.Error(), "stateless is not supported")
+	})
+
+	t.Run("invalid scheme", func(t *testing.T) {
+		err := mcp.ValidateConfig(mcp.ClientConfig{BaseURL: "ftp://localhost"}, "sse")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must use http or https")
+	})
+
+	t.Run("ambiguous auth", func(t *testing.T) {
+		err := mcp.ValidateConfig(mcp.ClientConfig{
+			BaseURL: "http://localhost:3001",
+			Auth:    mcp.AuthConfig{Scheme: "basic", Username: "k6", BearerToken: "token"},
+		}, "streamable-http")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "auth.bearer_token is not supported by the basic scheme")
+	})
+
+	t.Run("oauth2 options on another scheme", func(t *testing.T) {
+		err := mcp.ValidateConfig(mcp.ClientConfig{
+			BaseURL: "http://localhost:3001",
+			Auth:    mcp.AuthConfig{BearerToken: "token", TokenType: "DPoP"},
+		}, "streamable-http")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "auth.token_type is not supported by the bearer scheme")
+	})
+
+	t.Run("direct auth header on another scheme", func(t *testing.T) {
+		err := mcp.ValidateConfig(mcp.ClientConfig{
+			BaseURL: "http://localhost:3001",
+			Auth:    mcp.AuthConfig{Scheme: "basic", Username: "k6", DirectAuthHeader: true},
+		}, "streamable-http")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "auth.direct_auth_header is not supported by the basic scheme")
+	})
+
+	t.Run("unknown auth scheme", func(t *testing.T) {
+		err := mcp.ValidateConfig(mcp.ClientConfig{
+			BaseURL: "http://localhost:3001",
+			Auth:    mcp.AuthConfig{Scheme: "digest"},
+		}, "streamable-http")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown auth scheme "digest"`)
+	})
+
+	t.Run("invalid middlewares", func(t *testing.T) {
+		err := mcp.ValidateConfig(mcp.ClientConfig{
+			BaseURL:     "http://localhost:3001",
+			Middlewares: []string{"retry", "latency_injection", "fault_injection"},
+			MiddlewareOptions: mcp.MiddlewareOptions{
+				FaultInjection: mcp.FaultInjectionOptions{Rate: 2},
+			},
+		}, "streamable-http")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown middleware "retry"`)
+		assert.Contains(t, err.Error(), "middleware_options.latency_injection.latency is required")
+		assert.Contains(t, err.Error(), "middleware_options.fault_injection.rate must be between 0 and 1")
+	})
+
+	t.Run("invalid timeouts", func(t *testing.T) {
+		err := mcp.ValidateConfig(mcp.ClientConfig{
+			Path:           "./server",
+			Timeout:        "soon",
+			MethodTimeouts: map[string]string{"tools/call": "", "slow_tool": "-1s"},
+		}, "stdio")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid timeout")
+		assert.Contains(t, err.Error(), "method_timeouts.tools/call must not be empty")
+		assert.Contains(t, err.Error(), "method_timeouts.slow_tool must not be negative")
+	})
+
+	t.Run("framing", func(t *testing.T) {
+		assert.NoError(t, mcp.ValidateConfig(mcp.ClientConfig{Path: "./server", Framing: "content-length"}, "stdio"))
+
+		err := mcp.ValidateConfig(mcp.ClientConfig{Path: "./server", Framing: "lsp"}, "stdio")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown framing "lsp"`)
+
+		err = mcp.ValidateConfig(mcp.ClientConfig{BaseURL: "http://localhost:3001", Framing: "newline"}, "streamable-http")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "framing is not supported for streamable-http clients")
+	})
+
+	t.Run("correlation id header", func(t *testing.T) {
+		assert.NoError(t, mcp.ValidateConfig(mcp.ClientConfig{BaseURL: "http://localhost:3001", CorrelationIDHeader: "X-Correlation-ID"}, "streamable-http"))
+
+		err := mcp.ValidateConfig(mcp.ClientConfig{BaseURL: "http://localhost:3001", CorrelationIDHeader: "X Correlation"}, "streamable-http")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid correlation_id_header "X Correlation"`)
+
+		err = mcp.ValidateConfig(mcp.ClientConfig{Path: "./server", CorrelationIDHeader: "X-Correlation-ID"}, "stdio")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "correlation_id_header is not supported for stdio clients")
+	})
+
+	t.Run("marshal options", func(t *testing.T) {
+		yes, no := true, false
+		cfg := mcp.ClientConfig{Path: "./server"}
+		cfg.MarshalOptions.EmitDefaults = &yes
+		assert.NoError(t, mcp.ValidateConfig(cfg, "stdio"))
+
+		cfg.MarshalOptions.EscapeHTML = &no
+		err := mcp.ValidateConfig(cfg, "stdio")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "marshal_options.escape_html is not supported for stdio clients")
+	})
+}
@@ -0,0 +1,58 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// correlationIDMetadata is the sample metadata key holding the correlation ID
+// of a call. It is metadata rather than a tag, as a tag unique to every call
+// would create a time series per call.
+const correlationIDMetadata = "correlation_id"
+
+type correlationIDKey struct{}
+
+func withCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+func correlationID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDKey{}).(string)
+	return id, ok
+}
+
+// correlationRoundTripper sends the correlation ID of the call a request
+// belongs to in header.
+type correlationRoundTripper struct {
+	next   http.RoundTripper
+	header string
+}
+
+func (t *correlationRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	id, ok := correlationID(req.Context())
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set(t.header, id)
+	return t.next.RoundTrip(req)
+}
+
+// checkCorrelationIDHeader reports a correlation ID header name that is not a
+// valid HTTP token.
+func checkCorrelationIDHeader(header string) error {
+	if strings.ContainsFunc(header, func(r rune) bool { return !isTokenChar(r) }) {
+		return fmt.Errorf("invalid correlation_id_header %q", header)
+	}
+	return nil
+}
+
+// newCorrelationID returns a random UUID identifying a single call.
+func newCorrelationID() string {
+	return uuid.NewString()
+}
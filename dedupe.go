@@ -0,0 +1,33 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// deduplicate runs fn for a read-only call of method with params, unless an
+// identical call is already in flight, in which case it waits for the result
+// of that call instead. Calls that piggyback this way are counted and tagged
+// with deduplicated=true, while still recording their own request metrics.
+// Without Deduplicate, fn always runs.
+func deduplicate[T any](ctx context.Context, c *Client, method string, params any, fn func() (T, error)) (T, error) {
+	if c.inFlight == nil {
+		return fn()
+	}
+	key, err := json.Marshal(params)
+	if err != nil {
+		return fn()
+	}
+
+	ran := false
+	v, err, _ := c.inFlight.Do(method+"\x00"+string(key), func() (any, error) {
+		ran = true
+		return fn()
+	})
+	if !ran {
+		setResultTag(ctx, "deduplicated", "true")
+		c.metrics.PushDeduplicated(c.ctx, method)
+	}
+	res, _ := v.(T)
+	return res, err
+}
@@ -0,0 +1,151 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/grafana/sobek"
+)
+
+// SetDefaultArgs sets the arguments every later call of the named tool starts
+// from. The arguments of each call are deep-merged over them, so only the
+// values that differ need to be passed. Passing null clears the defaults.
+func (c *Client) SetDefaultArgs(name string, args map[string]any) {
+	if args == nil {
+		delete(c.defaultArgs, name)
+		return
+	}
+	if c.defaultArgs == nil {
+		c.defaultArgs = make(map[string]map[string]any)
+	}
+	c.defaultArgs[name] = args
+}
+
+// withDefaultArgs returns args merged over the default arguments of the named
+// tool. The defaults are copied, since the arguments of a call are later
+// rewritten in place.
+func (c *Client) withDefaultArgs(name string, args any) any {
+	defaults, ok := c.defaultArgs[name]
+	if !ok {
+		return args
+	}
+
+	switch args := args.(type) {
+	case nil:
+		return copyArg(defaults)
+	case map[string]any:
+		return mergeArgs(defaults, args)
+	default:
+		return args
+	}
+}
+
+// mergeArgs deep-merges args over defaults. Objects present in both are
+// merged key by key; any other value in args replaces the default.
+func mergeArgs(defaults, args map[string]any) map[string]any {
+	merged := make(map[string]any, len(defaults)+len(args))
+	for k, v := range defaults {
+		merged[k] = copyArg(v)
+	}
+	for k, v := range args {
+		base, baseIsMap := merged[k].(map[string]any)
+		override, overrideIsMap := v.(map[string]any)
+		if baseIsMap && overrideIsMap {
+			merged[k] = mergeArgs(base, override)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+func copyArg(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			m[k] = copyArg(item)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, item := range v {
+			s[i] = copyArg(item)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// setDefaults sets the config every later client of the VU starts from. The
+// config of each client is merged over it: nested objects are merged key by
+// key, except auth which is replaced as a whole, so that credentials of
+// different schemes never mix. Any other value in the client config replaces
+// the default. Passing null clears the defaults.
+func (m *MCPInstance) setDefaults(defaults sobek.Value) error {
+	if isNullish(defaults) {
+		m.clientDefaults = nil
+		return nil
+	}
+	obj, ok := defaults.(*sobek.Object)
+	if !ok || obj.ClassName() != "Object" {
+		return errors.New("invalid defaults: must be an object")
+	}
+	var cfg ClientConfig
+	if err := m.vu.Runtime().ExportTo(obj, &cfg); err != nil {
+		return fmt.Errorf("invalid defaults: %w", err)
+	}
+	m.clientDefaults = obj
+	return nil
+}
+
+// withClientDefaults returns config merged over the defaults of setDefaults.
+func (m *MCPInstance) withClientDefaults(rt *sobek.Runtime, config sobek.Value) sobek.Value {
+	if m.clientDefaults == nil {
+		return config
+	}
+	return mergeConfig(rt, m.clientDefaults, config)
+}
+
+// withNestedClientDefaults returns config, the config of a pool or group, with
+// its client config merged over the defaults of setDefaults.
+func (m *MCPInstance) withNestedClientDefaults(rt *sobek.Runtime, config sobek.Value) sobek.Value {
+	obj, ok := config.(*sobek.Object)
+	if !ok || m.clientDefaults == nil {
+		return config
+	}
+	withDefaults := rt.NewObject()
+	for _, k := range obj.Keys() {
+		_ = withDefaults.Set(k, obj.Get(k))
+	}
+	_ = withDefaults.Set("client", m.withClientDefaults(rt, obj.Get("client")))
+	return withDefaults
+}
+
+// mergeConfig merges config over defaults, leaving both untouched.
+func mergeConfig(rt *sobek.Runtime, defaults *sobek.Object, config sobek.Value) sobek.Value {
+	if isNullish(config) {
+		config = rt.NewObject()
+	}
+	override, ok := config.(*sobek.Object)
+	if !ok || override.ClassName() != "Object" {
+		// Left for the export of the config to reject.
+		return config
+	}
+
+	merged := rt.NewObject()
+	for _, k := range defaults.Keys() {
+		_ = merged.Set(k, defaults.Get(k))
+	}
+	for _, k := range override.Keys() {
+		v := override.Get(k)
+		base, baseIsObject := merged.Get(k).(*sobek.Object)
+		next, nextIsObject := v.(*sobek.Object)
+		if k != "auth" && baseIsObject && nextIsObject && base.ClassName() == "Object" && next.ClassName() == "Object" {
+			v = mergeConfig(rt, base, next)
+		}
+		_ = merged.Set(k, v)
+	}
+	return merged
+}
@@ -0,0 +1,70 @@
+package mcp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	k6lib "go.k6.io/k6/lib"
+)
+
+// envReplacer returns a replacer expanding the per-VU placeholders supported
+// in stdio Env values: ${VU}, ${ITER} and ${SCENARIO}. A placeholder escaped
+// as $${VU} is kept as ${VU}, and unknown placeholders are left as they are.
+func (m *MCPInstance) envReplacer() *strings.Replacer {
+	var vu, iter, scenario string
+	if state := m.vu.State(); state != nil {
+		vu = strconv.FormatUint(state.VUID, 10)
+		iter = strconv.FormatInt(state.Iteration, 10)
+	}
+	if s := k6lib.GetScenarioState(m.getContext()); s != nil {
+		scenario = s.Name
+	}
+
+	return strings.NewReplacer(
+		"$${", "${",
+		"${VU}", vu,
+		"${ITER}", iter,
+		"${SCENARIO}", scenario,
+	)
+}
+
+// loadEnvFile reads the KEY=VALUE pairs of a dotenv-style file, in file
+// order. Blank lines and lines starting with # are skipped, an "export "
+// prefix is allowed and values may be wrapped in single or double quotes.
+func loadEnvFile(path string) ([][2]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("env_file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var env [][2]string
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(strings.TrimPrefix(text, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("env_file %s: line %d: expected KEY=VALUE", path, line)
+		}
+
+		value = strings.TrimSpace(value)
+		if n := len(value); n >= 2 && (value[0] == '"' || value[0] == '\'') && value[n-1] == value[0] {
+			value = value[1 : n-1]
+		}
+
+		env = append(env, [2]string{key, value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("env_file %s: %w", path, err)
+	}
+
+	return env, nil
+}
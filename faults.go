@@ -0,0 +1,57 @@
+package mcp
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// faultInjector decides which calls get a synthetic error or extra latency.
+type faultInjector struct {
+	errorRate   float64
+	latencyRate float64
+	latency     time.Duration
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newFaultInjector(cfg FaultInjectionConfig) *faultInjector {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+
+	return &faultInjector{
+		errorRate:   cfg.ErrorRate,
+		latencyRate: cfg.LatencyRate,
+		latency:     time.Duration(cfg.LatencyMs) * time.Millisecond,
+		rng:         rand.New(rand.NewPCG(seed, seed)),
+	}
+}
+
+// inject delays the call if it was picked for latency, and returns
+// errInjectedFault if it was picked to fail. injected reports whether either
+// fault applied.
+func (f *faultInjector) inject(ctx context.Context) (injected bool, err error) {
+	f.mu.Lock()
+	delay := f.rng.Float64() < f.latencyRate
+	fail := f.rng.Float64() < f.errorRate
+	f.mu.Unlock()
+
+	if delay {
+		timer := time.NewTimer(f.latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return true, ctx.Err()
+		}
+	}
+	if fail {
+		return true, errInjectedFault
+	}
+
+	return delay, nil
+}
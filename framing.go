@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+)
+
+// The framings of stdio messages: one message per line, as the MCP
+// specification has it, or each message preceded by a Content-Length header,
+// as the Language Server Protocol does.
+const (
+	framingNewline       = "newline"
+	framingContentLength = "content-length"
+)
+
+// maxFrameSize bounds the Content-Length accepted from the server, so that a
+// corrupt header does not make the client allocate without limit.
+const maxFrameSize = 64 << 20
+
+// contentLengthConn exchanges JSON-RPC messages over rwc, each one framed by
+// a header block holding its Content-Length. Other headers, such as
+// Content-Type, are ignored. Batches are not supported.
+type contentLengthConn struct {
+	rwc io.ReadWriteCloser
+
+	incoming chan frame
+	closed   chan struct{}
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// frame is a message body read off the connection, or the error that ended
+// reading.
+type frame struct {
+	body []byte
+	err  error
+}
+
+func newContentLengthConn(rwc io.ReadWriteCloser) *contentLengthConn {
+	c := &contentLengthConn{
+		rwc:      rwc,
+		incoming: make(chan frame),
+		closed:   make(chan struct{}),
+	}
+	go c.readFrames()
+	return c
+}
+
+// readFrames reads frames until the connection fails or is closed, so that
+// Read can give up on a frame when its context is done or the connection
+// closed.
+func (c *contentLengthConn) readFrames() {
+	r := textproto.NewReader(bufio.NewReader(c.rwc))
+	for {
+		body, err := readFrame(r)
+		select {
+		case c.incoming <- frame{body: body, err: err}:
+		case <-c.closed:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func readFrame(r *textproto.Reader) ([]byte, error) {
+	header, err := r.ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("invalid frame header: %w", err)
+	}
+	value := header.Get("Content-Length")
+	if value == "" {
+		return nil, errors.New("invalid frame header: no Content-Length")
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 || size > maxFrameSize {
+		return nil, fmt.Errorf("invalid frame header: Content-Length %q", value)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r.R, body); err != nil {
+		return nil, fmt.Errorf("truncated frame: %w", err)
+	}
+	return body, nil
+}
+
+func (c *contentLengthConn) Read(ctx context.Context) (jsonrpc.Message, error) {
+	select {
+	case f := <-c.incoming:
+		if f.err != nil {
+			return nil, f.err
+		}
+		return jsonrpc.DecodeMessage(f.body)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.closed:
+		return nil, io.EOF
+	}
+}
+
+func (c *contentLengthConn) Write(ctx context.Context, msg jsonrpc.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	body, err := jsonrpc.EncodeMessage(msg)
+	if err != nil {
+		return err
+	}
+	data := fmt.Appendf(nil, "Content-Length: %d\r\n\r\n", len(body))
+	data = append(data, body...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	// One write per frame, so that frames are dumped whole.
+	_, err = c.rwc.Write(data)
+	return err
+}
+
+func (c *contentLengthConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.closeErr = c.rwc.Close()
+	})
+	return c.closeErr
+}
+
+func (c *contentLengthConn) SessionID() string {
+	return ""
+}
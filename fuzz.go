@@ -0,0 +1,306 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// defaultFuzzCount is the number of calls made by FuzzTool when
+// FuzzOptions.Count is not set.
+const defaultFuzzCount = 10
+
+type (
+	// FuzzOptions configures FuzzTool.
+	FuzzOptions struct {
+		Count int
+		// Seed makes the generated arguments reproducible. A random seed is
+		// used if it is zero.
+		Seed uint64
+	}
+
+	// FuzzSummary counts the outcomes of the calls made by FuzzTool.
+	FuzzSummary struct {
+		Seed       uint64
+		Calls      int
+		Succeeded  int
+		ToolErrors int
+		Failed     int
+		// Failures lists the calls that did not succeed, in order.
+		Failures []FuzzFailure
+	}
+
+	// FuzzFailure is a call made by FuzzTool that did not succeed.
+	FuzzFailure struct {
+		Arguments map[string]any
+		Error     string
+	}
+)
+
+// FuzzTool calls the named tool opts.Count times, each with random arguments
+// generated from its input schema, and summarizes how the calls went. The
+// request metrics of the calls are tagged with fuzz=true.
+func (c *Client) FuzzTool(name string, opts FuzzOptions) (*FuzzSummary, error) {
+	tool, err := c.GetTool(name)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := toolInputSchema(tool)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input schema of tool %q: %w", name, err)
+	}
+
+	count := opts.Count
+	if count <= 0 {
+		count = defaultFuzzCount
+	}
+	seed := opts.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	gen := &argumentGenerator{rng: rand.New(rand.NewPCG(seed, seed))}
+
+	summary := &FuzzSummary{Seed: seed}
+	for range count {
+		args, _ := gen.value(schema, 0).(map[string]any)
+		if args == nil {
+			args = map[string]any{}
+		}
+
+		res, err := c.callTool(mcp.CallToolParams{Name: name, Arguments: args}, map[string]string{"fuzz": "true"})
+		summary.Calls++
+
+		var toolErr *ToolError
+		switch {
+		case errors.As(err, &toolErr):
+			summary.ToolErrors++
+		case err != nil:
+			summary.Failed++
+		case res.IsError:
+			summary.ToolErrors++
+			err = newToolError(name, res)
+		default:
+			summary.Succeeded++
+			continue
+		}
+		summary.Failures = append(summary.Failures, FuzzFailure{Arguments: args, Error: err.Error()})
+	}
+
+	return summary, nil
+}
+
+func toolInputSchema(t *mcp.Tool) (*jsonschema.Schema, error) {
+	raw, err := json.Marshal(t.InputSchema)
+	if err != nil {
+		return nil, err
+	}
+	var schema jsonschema.Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// maxFuzzDepth is the depth past which generated objects only get their
+// required properties, so that recursive schemas stay finite.
+const maxFuzzDepth = 4
+
+// argumentGenerator generates random values valid against a JSON schema. It
+// honors types, enums, consts, bounds, lengths, item counts, required
+// properties and the date-time, date, email, uri and uuid formats, but not
+// patterns or combinations such as allOf and not.
+type argumentGenerator struct {
+	rng *rand.Rand
+}
+
+func (g *argumentGenerator) value(s *jsonschema.Schema, depth int) any {
+	switch {
+	case s == nil:
+		return g.string(nil)
+	case s.Const != nil:
+		return *s.Const
+	case len(s.Enum) > 0:
+		return s.Enum[g.rng.IntN(len(s.Enum))]
+	case len(s.OneOf) > 0:
+		return g.value(s.OneOf[g.rng.IntN(len(s.OneOf))], depth)
+	case len(s.AnyOf) > 0:
+		return g.value(s.AnyOf[g.rng.IntN(len(s.AnyOf))], depth)
+	}
+
+	switch g.typeOf(s) {
+	case "object":
+		return g.object(s, depth)
+	case "array":
+		return g.array(s, depth)
+	case "integer":
+		return g.integer(s)
+	case "number":
+		return g.number(s)
+	case "boolean":
+		return g.rng.IntN(2) == 0
+	case "null":
+		return nil
+	default:
+		return g.string(s)
+	}
+}
+
+func (g *argumentGenerator) typeOf(s *jsonschema.Schema) string {
+	switch {
+	case s.Type != "":
+		return s.Type
+	case len(s.Types) > 0:
+		return s.Types[g.rng.IntN(len(s.Types))]
+	case s.Properties != nil:
+		return "object"
+	case s.Items != nil:
+		return "array"
+	default:
+		return "string"
+	}
+}
+
+func (g *argumentGenerator) object(s *jsonschema.Schema, depth int) map[string]any {
+	obj := make(map[string]any)
+	for _, key := range slices.Sorted(maps.Keys(s.Properties)) {
+		required := slices.Contains(s.Required, key)
+		if required || (depth < maxFuzzDepth && g.rng.IntN(2) == 0) {
+			obj[key] = g.value(s.Properties[key], depth+1)
+		}
+	}
+	return obj
+}
+
+func (g *argumentGenerator) array(s *jsonschema.Schema, depth int) []any {
+	lo, hi := 0, 3
+	if s.MinItems != nil {
+		lo = *s.MinItems
+		hi = max(hi, lo)
+	}
+	if s.MaxItems != nil {
+		hi = *s.MaxItems
+	}
+	if depth >= maxFuzzDepth {
+		hi = lo
+	}
+
+	items := make([]any, lo+g.rng.IntN(max(hi-lo, 0)+1))
+	for i := range items {
+		items[i] = g.value(s.Items, depth+1)
+	}
+	return items
+}
+
+func (g *argumentGenerator) integer(s *jsonschema.Schema) int64 {
+	lo, hi := g.bounds(s)
+	first, last := int64(math.Ceil(lo)), int64(math.Floor(hi))
+	if s.ExclusiveMinimum != nil && float64(first) <= *s.ExclusiveMinimum {
+		first++
+	}
+	if s.ExclusiveMaximum != nil && float64(last) >= *s.ExclusiveMaximum {
+		last--
+	}
+	if last < first {
+		return first
+	}
+	return first + g.rng.Int64N(last-first+1)
+}
+
+func (g *argumentGenerator) number(s *jsonschema.Schema) float64 {
+	lo, hi := g.bounds(s)
+	v := lo + g.rng.Float64()*(hi-lo)
+	if s.ExclusiveMinimum != nil && v <= lo {
+		v = math.Nextafter(lo, hi)
+	}
+	return v
+}
+
+// bounds returns the range of the numbers valid against s, defaulting to
+// [-1000, 1000] around whichever bound is set.
+func (g *argumentGenerator) bounds(s *jsonschema.Schema) (lo, hi float64) {
+	const span = 1000
+
+	lo, hi = math.NaN(), math.NaN()
+	if s.Minimum != nil {
+		lo = *s.Minimum
+	}
+	if s.ExclusiveMinimum != nil {
+		lo = *s.ExclusiveMinimum
+	}
+	if s.Maximum != nil {
+		hi = *s.Maximum
+	}
+	if s.ExclusiveMaximum != nil {
+		hi = *s.ExclusiveMaximum
+	}
+
+	switch {
+	case math.IsNaN(lo) && math.IsNaN(hi):
+		return -span, span
+	case math.IsNaN(lo):
+		return hi - 2*span, hi
+	case math.IsNaN(hi):
+		return lo, lo + 2*span
+	default:
+		return lo, hi
+	}
+}
+
+const fuzzAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 _-"
+
+func (g *argumentGenerator) string(s *jsonschema.Schema) string {
+	if s != nil {
+		switch s.Format {
+		case "date-time":
+			return g.time().Format(time.RFC3339)
+		case "date":
+			return g.time().Format(time.DateOnly)
+		case "email":
+			return g.letters(8) + "@example.com"
+		case "uri":
+			return "https://example.com/" + g.letters(8)
+		case "uuid":
+			b := make([]byte, 16)
+			for i := range b {
+				b[i] = byte(g.rng.IntN(256))
+			}
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+		}
+	}
+
+	lo, hi := 0, 16
+	if s != nil && s.MinLength != nil {
+		lo = *s.MinLength
+		hi = max(hi, lo)
+	}
+	if s != nil && s.MaxLength != nil {
+		hi = *s.MaxLength
+	}
+
+	n := lo + g.rng.IntN(max(hi-lo, 0)+1)
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = fuzzAlphabet[g.rng.IntN(len(fuzzAlphabet))]
+	}
+	return string(b)
+}
+
+func (g *argumentGenerator) letters(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = fuzzAlphabet[g.rng.IntN(26)]
+	}
+	return string(b)
+}
+
+func (g *argumentGenerator) time() time.Time {
+	return time.Unix(g.rng.Int64N(4102444800), 0).UTC()
+}
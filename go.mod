@@ -8,12 +8,14 @@ replace github.com/modelcontextprotocol/go-sdk => github.com/brkane/mcp-go-sdk v
 
 require (
 	github.com/google/jsonschema-go v0.3.0
+	github.com/google/uuid v1.6.0
 	github.com/grafana/sobek v0.0.0-20251030131753-d05c9166857d
 	github.com/modelcontextprotocol/go-sdk v1.1.0
 	github.com/sirupsen/logrus v1.9.3
 	github.com/stretchr/testify v1.11.1
 	go.k6.io/k6 v1.4.0
 	golang.org/x/oauth2 v0.30.0
+	golang.org/x/sync v0.17.0
 )
 
 require (
@@ -27,7 +29,6 @@ require (
 	github.com/go-logr/stdr v1.2.2 // indirect
 	github.com/go-sourcemap/sourcemap v2.1.4+incompatible // indirect
 	github.com/google/pprof v0.0.0-20250317173921-a4b03ec1a45e // indirect
-	github.com/google/uuid v1.6.0 // indirect
 	github.com/grpc-ecosystem/grpc-gateway/v2 v2.27.2 // indirect
 	github.com/josharian/intern v1.0.0 // indirect
 	github.com/mailru/easyjson v0.9.0 // indirect
@@ -0,0 +1,147 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+)
+
+type (
+	// ConnectionGroupConfig configures a ConnectionGroup.
+	ConnectionGroupConfig struct {
+		// Name tags the connection metric of the group. It defaults to the
+		// transport and server address.
+		Name      string
+		Transport string
+		Client    ClientConfig
+	}
+
+	// ConnectionGroup vends clients of a VU that each have a session of
+	// their own, but send their HTTP requests over the same connections.
+	ConnectionGroup struct {
+		m   *MCPInstance
+		cfg ConnectionGroupConfig
+
+		// conns is created by the first client, as the VU dialer is only
+		// available once the VU runs.
+		conns *http.Transport
+		open  atomic.Int64
+	}
+)
+
+func (m *MCPInstance) newConnectionGroup(c sobek.ConstructorCall, rt *sobek.Runtime) *sobek.Object {
+	var cfg ConnectionGroupConfig
+	if err := rt.ExportTo(m.withNestedClientDefaults(rt, c.Argument(0)), &cfg); err != nil {
+		common.Throw(rt, fmt.Errorf("invalid connection group config: %w", err))
+	}
+
+	var errs []error
+	if cfg.Transport == stdioTransport {
+		errs = append(errs, errors.New("stdio sessions cannot share a connection"))
+	} else if err := ValidateConfig(cfg.Client, cfg.Transport); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		common.Throw(rt, fmt.Errorf("invalid connection group config: %w", err))
+	}
+
+	if cfg.Name == "" {
+		cfg.Name = cfg.Transport + " " + cfg.Client.BaseURL
+	}
+
+	return rt.ToValue(&ConnectionGroup{m: m, cfg: cfg}).ToObject(rt)
+}
+
+// Client connects a new session over the connections of the group. auth, if
+// set, replaces the auth config of the group for this session, so that
+// credentials only known once the VU runs, such as a token returned by
+// setup(), need not be in the group config. Authentication happens per
+// request, on top of the connections, so they are shared all the same.
+func (g *ConnectionGroup) Client(auth *AuthConfig) (*Client, error) {
+	cfg := g.cfg.Client
+	if auth != nil {
+		if err := errors.Join(auth.validate()...); err != nil {
+			return nil, fmt.Errorf("invalid auth config: %w", err)
+		}
+		cfg.Auth = *auth
+	}
+
+	if g.conns == nil {
+		g.conns = g.m.newHTTPTransport(cfg)
+		g.conns.DialContext = g.countingDial(g.conns.DialContext)
+	}
+
+	client, err := g.m.wrapClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	transport, isStateless, err := g.m.newTransport(cfg, g.cfg.Transport, client.stats, client.scopes, g.conns)
+	if err != nil {
+		return nil, err
+	}
+	opts := client.clientOptions(g.m.vu.Runtime(), cfg)
+	opts.Stateless = isStateless
+	client.reinit = newReinitializer(transport)
+	transport = client.strictTransport(transport)
+
+	if err := client.waitConnectJitter(g.m.getContext(), durationOrZero(cfg.ConnectJitter)); err != nil {
+		return nil, err
+	}
+	logger := g.m.sessionLogger(g.cfg.Transport).WithField("group", g.cfg.Name)
+	session, err := dial(g.m.getContext(), logger, transport, isStateless, opts, client.timeRootsList)
+	if err != nil {
+		return nil, fmt.Errorf("connection error: %w", err)
+	}
+	client.session = session
+	if err := client.checkNegotiatedVersion(); err != nil {
+		_ = session.Close()
+		return nil, fmt.Errorf("connection error: %w", err)
+	}
+	client.startKeepAlive(session, durationOrZero(cfg.KeepAlivePingInterval))
+
+	return client, nil
+}
+
+// OpenConnections returns the number of connections the group holds open.
+func (g *ConnectionGroup) OpenConnections() int64 {
+	return g.open.Load()
+}
+
+// countingDial wraps dial so that the connections it opens are counted in
+// the connection metric of the group until they are closed.
+func (g *ConnectionGroup) countingDial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	metrics := g.m.newK6Metrics(g.cfg.Client)
+	ctx := g.m.getContext()
+
+	return func(dialCtx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(dialCtx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		metrics.PushGroupConnections(ctx, g.cfg.Name, g.open.Add(1))
+		return &groupConn{Conn: conn, closed: func() {
+			metrics.PushGroupConnections(ctx, g.cfg.Name, g.open.Add(-1))
+		}}, nil
+	}
+}
+
+type groupConn struct {
+	net.Conn
+	once   sync.Once
+	closed func()
+}
+
+func (c *groupConn) Close() error {
+	c.once.Do(c.closed)
+	return c.Conn.Close()
+}
@@ -0,0 +1,205 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/grafana/sobek"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	createMessageHandler = "create_message"
+	elicitHandler        = "elicit"
+)
+
+// await runs fn off the VU goroutine and, until it returns, executes any JS
+// callbacks queued by server-initiated requests. Server requests only arrive
+// while a call is in flight, so this is where they get a chance to run. Calls
+// all go through it on the VU, so it is also where the stats of the client
+// are moved on to a new scenario.
+func await[T any](c *Client, fn func() (T, error)) (T, error) {
+	type result struct {
+		v   T
+		err error
+	}
+
+	c.stats.enterScenario(c.scenario())
+
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		done <- result{v, err}
+	}()
+
+	for {
+		select {
+		case r := <-done:
+			return r.v, r.err
+		case cb := <-c.callbacks:
+			cb()
+		}
+	}
+}
+
+// eventLoop runs functions on the event loop for a call made off the VU
+// goroutine behind a promise, keeping the event loop alive until the promise
+// is settled.
+type eventLoop struct {
+	c        *Client
+	callback func(func() error)
+	// abandoned is set when the VU context ended while a function was due,
+	// which leaves no callback to settle the promise with
+	abandoned bool
+}
+
+// eventLoop registers a callback with the event loop of the VU. It must be
+// called on the VU goroutine.
+func (c *Client) eventLoop() *eventLoop {
+	return &eventLoop{c: c, callback: c.vu.RegisterCallback()}
+}
+
+// run runs fn on the event loop and waits for it to return, reporting false
+// if the VU context ended first.
+func (l *eventLoop) run(fn func()) bool {
+	done := make(chan func(func() error), 1)
+	l.callback(func() error {
+		next := l.c.vu.RegisterCallback()
+		fn()
+		done <- next
+		return nil
+	})
+
+	select {
+	case next := <-done:
+		l.callback = next
+		return true
+	case <-l.c.ctx.Done():
+		l.abandoned = true
+		return false
+	}
+}
+
+// settle runs fn on the event loop as the last thing done for the promise,
+// unless it was abandoned.
+func (l *eventLoop) settle(fn func() error) {
+	if !l.abandoned {
+		l.callback(fn)
+	}
+}
+
+// awaitOnLoop works like await off the VU goroutine: the JS callbacks queued
+// by server-initiated requests while fn runs are executed on the event loop.
+func awaitOnLoop[T any](l *eventLoop, fn func() (T, error)) (T, error) {
+	type result struct {
+		v   T
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		done <- result{v, err}
+	}()
+
+	for {
+		select {
+		case r := <-done:
+			return r.v, r.err
+		case cb := <-l.c.callbacks:
+			if !l.run(cb) {
+				// The handler gives up once the call is cancelled with the VU.
+				r := <-done
+				return r.v, r.err
+			}
+		}
+	}
+}
+
+// runOnVU schedules fn on the VU goroutine and waits for it to finish.
+func (c *Client) runOnVU(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+	select {
+	case c.callbacks <- func() { defer close(done); fn() }:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// callHandler invokes a user-registered JS handler with params and decodes
+// its return value into out using the MCP JSON representation.
+func (c *Client) callHandler(ctx context.Context, rt *sobek.Runtime, handler string, fn sobek.Callable, params any, out any) error {
+	var (
+		v   sobek.Value
+		err error
+	)
+	if qerr := c.runOnVU(ctx, func() {
+		start := c.metrics.Now()
+		v, err = fn(sobek.Undefined(), rt.ToValue(params))
+		c.metrics.PushHandler(c.ctx, handler, c.metrics.Since(start))
+	}); qerr != nil {
+		return qerr
+	}
+	if err != nil {
+		return fmt.Errorf("%s handler: %w", handler, err)
+	}
+
+	b, err := json.Marshal(v.Export())
+	if err != nil {
+		return fmt.Errorf("%s handler: %w", handler, err)
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("%s handler returned an invalid result: %w", handler, err)
+	}
+
+	return nil
+}
+
+// clientOptions builds the SDK client options, wiring in any handlers
+// registered in cfg.
+func (c *Client) clientOptions(rt *sobek.Runtime, cfg ClientConfig) *mcp.ClientOptions {
+	opts := &mcp.ClientOptions{}
+
+	if fn, ok := sobek.AssertFunction(cfg.OnCreateMessage); ok {
+		opts.CreateMessageHandler = func(ctx context.Context, req *mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+			var res mcp.CreateMessageResult
+			if err := c.callHandler(ctx, rt, createMessageHandler, fn, req.Params, &res); err != nil {
+				return nil, err
+			}
+			return &res, nil
+		}
+	}
+
+	if fn, ok := sobek.AssertFunction(cfg.OnElicit); ok {
+		opts.ElicitationHandler = func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			var res mcp.ElicitResult
+			if err := c.callHandler(ctx, rt, elicitHandler, fn, req.Params, &res); err != nil {
+				return nil, err
+			}
+			return &res, nil
+		}
+	}
+
+	if c.cache != nil {
+		opts.ResourceUpdatedHandler = func(_ context.Context, req *mcp.ResourceUpdatedNotificationRequest) {
+			c.cache.invalidate(req.Params.URI)
+		}
+	}
+	if c.logFile != nil {
+		opts.LoggingMessageHandler = func(_ context.Context, req *mcp.LoggingMessageRequest) {
+			c.logFile.write(req.Params, c.metrics.Now())
+		}
+	}
+	opts.ProgressNotificationHandler = recordProgress
+	c.notifications.notificationHandlers(opts)
+
+	return opts
+}
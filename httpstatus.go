@@ -0,0 +1,71 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// StatusError is thrown by calls that failed because the server answered an
+// HTTP request with an error status, which scripts reach as the value of the
+// thrown error, for example to retry on 503 but not on 401.
+type StatusError struct {
+	StatusCode int
+	err        error
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%v (HTTP status %d)", e.err, e.StatusCode)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.err
+}
+
+type httpStatusKey struct{}
+
+// statusRoundTripper records the error statuses of the HTTP responses to the
+// requests of a call, for a failing call to report.
+type statusRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (t *statusRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode >= http.StatusBadRequest {
+		if status, ok := req.Context().Value(httpStatusKey{}).(*atomic.Int32); ok {
+			status.Store(int32(resp.StatusCode))
+		}
+	}
+	return resp, err
+}
+
+// withHTTPStatus returns ctx with a slot for the error status of the HTTP
+// responses to the requests of the call running with it.
+func withHTTPStatus(ctx context.Context) (context.Context, *atomic.Int32) {
+	status := &atomic.Int32{}
+	return context.WithValue(ctx, httpStatusKey{}, status), status
+}
+
+// statusError returns err as a StatusError with the last error status
+// recorded for its call, if any.
+func statusError(err error, status *atomic.Int32) error {
+	code := int(status.Load())
+	var statusErr *StatusError
+	if err == nil || code == 0 || errors.As(err, &statusErr) {
+		return err
+	}
+	return &StatusError{StatusCode: code, err: err}
+}
+
+// statusTags returns the http_status tag of calls failing with a StatusError.
+func statusTags(err error) map[string]string {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return nil
+	}
+	return map[string]string{"http_status": strconv.Itoa(statusErr.StatusCode)}
+}
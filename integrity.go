@@ -0,0 +1,122 @@
+package mcp
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultIntegrityAlgorithm = "sha256"
+	defaultIntegrityMetaKey   = "hash"
+
+	integrityOK       = "ok"
+	integrityMismatch = "mismatch"
+	integrityMissing  = "missing"
+)
+
+// integrityAlgorithms are the hash functions ReadResourceVerified supports, by
+// name.
+var integrityAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+type (
+	// ReadResourceVerifiedOptions selects how ReadResourceVerified checks the
+	// contents of a resource: the hash function, sha256 by default, and the
+	// _meta key of the declared hash, hash by default.
+	ReadResourceVerifiedOptions struct {
+		Algorithm string
+		MetaKey   string
+	}
+
+	// VerifiedReadResult is the result of a resource read by
+	// ReadResourceVerified, along with the outcome of the check: ok when
+	// every declared hash matched, mismatch when one did not, and missing
+	// when no hash was declared.
+	VerifiedReadResult struct {
+		mcp.ReadResourceResult
+		Integrity string
+		// Mismatches lists the URIs of the contents that did not match their
+		// declared hash.
+		Mismatches []string
+	}
+)
+
+// ReadResourceVerified reads the resource at uri and checks its contents
+// against the hashes declared in their _meta, or in the _meta of the result
+// for contents without one. The request metrics are tagged with the outcome
+// as integrity, and mismatches are counted. Reads bypass the cache, which
+// would only verify the copy it holds.
+func (c *Client) ReadResourceVerified(uri string, opts ReadResourceVerifiedOptions) (*VerifiedReadResult, error) {
+	algorithm := strings.ToLower(opts.Algorithm)
+	if algorithm == "" {
+		algorithm = defaultIntegrityAlgorithm
+	}
+	newHash, ok := integrityAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash algorithm %q", opts.Algorithm)
+	}
+	key := opts.MetaKey
+	if key == "" {
+		key = defaultIntegrityMetaKey
+	}
+
+	return call(c, ReadResourceMethod, func(ctx context.Context) (*VerifiedReadResult, error) {
+		params := &mcp.ReadResourceParams{URI: uri}
+		recordParams(ctx, params)
+		res, err := c.session.ReadResource(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+
+		verified := &VerifiedReadResult{ReadResourceResult: *res, Integrity: integrityMissing}
+		for _, contents := range res.Contents {
+			declared, ok := declaredHash(contents.Meta, key, algorithm)
+			if !ok {
+				declared, ok = declaredHash(res.Meta, key, algorithm)
+			}
+			if !ok {
+				continue
+			}
+
+			h := newHash()
+			h.Write([]byte(contents.Text))
+			h.Write(contents.Blob)
+			if hex.EncodeToString(h.Sum(nil)) != declared {
+				verified.Mismatches = append(verified.Mismatches, contents.URI)
+			} else if verified.Integrity == integrityMissing {
+				verified.Integrity = integrityOK
+			}
+		}
+		if len(verified.Mismatches) > 0 {
+			verified.Integrity = integrityMismatch
+			c.metrics.PushIntegrityMismatch(c.ctx, ReadResourceMethod)
+		}
+		setResultTag(ctx, "integrity", verified.Integrity)
+
+		return verified, nil
+	})
+}
+
+// declaredHash returns the hash declared under key in meta, as lowercase hex
+// without the algorithm prefix it may have, such as sha256:.
+func declaredHash(meta mcp.Meta, key, algorithm string) (string, bool) {
+	value, ok := meta[key].(string)
+	if !ok || value == "" {
+		return "", false
+	}
+	value = strings.ToLower(strings.TrimSpace(value))
+	return strings.TrimPrefix(value, algorithm+":"), true
+}
@@ -0,0 +1,163 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/grafana/sobek"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type interceptorKey struct{}
+
+// UseRequestInterceptor sets fn to be called with the method and params of
+// every later request of the client, right before it is sent, for example to
+// sign it. fn returns the params to send instead, or nothing to send the ones
+// it was given, which it may have changed in place. Passing null removes the
+// interceptor.
+func (c *Client) UseRequestInterceptor(fn sobek.Value) error {
+	if isNullish(fn) {
+		c.interceptor = nil
+		return nil
+	}
+	callable, ok := sobek.AssertFunction(fn)
+	if !ok {
+		return errors.New("the request interceptor must be a function")
+	}
+	c.interceptor = callable
+	return nil
+}
+
+// UseResponseInterceptor sets fn to be called with the method, result and
+// error of every later request of the client, as soon as it completes, for
+// example to unwrap a common envelope. fn returns the result to hand to the
+// script instead, or nothing to keep the one it was given, which it may have
+// changed in place. The error is null for successful requests; for failed
+// ones the result is null and what fn returns is ignored. Throwing fails the
+// call with the thrown error. Passing null removes the interceptor.
+func (c *Client) UseResponseInterceptor(fn sobek.Value) error {
+	if isNullish(fn) {
+		c.responseInterceptor = nil
+		return nil
+	}
+	callable, ok := sobek.AssertFunction(fn)
+	if !ok {
+		return errors.New("the response interceptor must be a function")
+	}
+	c.responseInterceptor = callable
+	return nil
+}
+
+// intercepting returns fn, running with the interceptors of c, if any, in
+// its context. Only calls whose await services the callbacks of c can use it,
+// since the interceptors run on the VU goroutine.
+func intercepting[T any](c *Client, fn func(context.Context) (T, error)) func(context.Context) (T, error) {
+	if c.interceptor == nil && c.responseInterceptor == nil {
+		return fn
+	}
+	i := &interceptors{c: c, request: c.interceptor, response: c.responseInterceptor}
+	return func(ctx context.Context) (T, error) {
+		return fn(context.WithValue(ctx, interceptorKey{}, i))
+	}
+}
+
+// interceptors holds the interceptors set when a call started.
+type interceptors struct {
+	c        *Client
+	request  sobek.Callable
+	response sobek.Callable
+}
+
+// interceptRequests is the sending middleware of every session, which calls
+// the interceptors of the call a request is sent for.
+func interceptRequests(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		i, ok := ctx.Value(interceptorKey{}).(*interceptors)
+		if !ok || strings.HasPrefix(method, "notifications/") {
+			return next(ctx, method, req)
+		}
+
+		if i.request != nil {
+			if err := i.run(ctx, i.request, method, req.GetParams(), nil); err != nil {
+				return nil, fmt.Errorf("request interceptor: %w", err)
+			}
+		}
+		res, err := next(ctx, method, req)
+		if i.response != nil {
+			var v any
+			if err == nil {
+				v = res
+			}
+			if ierr := i.run(ctx, i.response, method, v, &err); ierr != nil {
+				return nil, fmt.Errorf("response interceptor: %w", ierr)
+			}
+		}
+		return res, err
+	}
+}
+
+// run calls fn with method and v, the params or result of a request, in its
+// JSON form, followed by the error of the request when reqErr is set. v is
+// replaced with what fn returns, or with the JSON it was given, which fn may
+// have changed in place.
+func (i *interceptors) run(ctx context.Context, fn sobek.Callable, method string, v any, reqErr *error) error {
+	target := reflect.ValueOf(v)
+	if v != nil && (target.Kind() != reflect.Pointer || target.IsNil()) {
+		v = nil
+	}
+	var obj map[string]any
+	if v != nil {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return err
+		}
+	}
+
+	var (
+		raw     []byte
+		callErr error
+	)
+	if err := i.c.runOnVU(ctx, func() {
+		rt := i.c.vu.Runtime()
+		args := []sobek.Value{rt.ToValue(method), sobek.Null()}
+		if obj != nil {
+			args[1] = rt.ToValue(obj)
+		}
+		if reqErr != nil {
+			errArg := sobek.Null()
+			if *reqErr != nil {
+				errArg = rt.ToValue((*reqErr).Error())
+			}
+			args = append(args, errArg)
+		}
+
+		var out sobek.Value
+		out, callErr = fn(sobek.Undefined(), args...)
+		if callErr != nil || obj == nil {
+			return
+		}
+		if isNullish(out) {
+			out = args[1]
+		}
+		raw, callErr = json.Marshal(out.Export())
+	}); err != nil {
+		return err
+	}
+	if callErr != nil || v == nil {
+		return callErr
+	}
+
+	replaced := reflect.New(target.Elem().Type())
+	if err := json.Unmarshal(raw, replaced.Interface()); err != nil {
+		return fmt.Errorf("invalid value returned for %s: %w", method, err)
+	}
+	target.Elem().Set(replaced.Elem())
+	return nil
+}
@@ -0,0 +1,28 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// waitConnectJitter waits for a random duration up to limit before the client
+// connects, spreading out the handshakes of VUs that start together, and
+// records how long it waited.
+func (c *Client) waitConnectJitter(ctx context.Context, limit time.Duration) error {
+	if limit <= 0 {
+		return nil
+	}
+	delay := rand.N(limit + 1)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return fmt.Errorf("connection error: %w", ctx.Err())
+	}
+	c.metrics.PushConnectJitter(c.ctx, delay)
+	return nil
+}
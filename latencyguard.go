@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"go.k6.io/k6/errext"
+)
+
+const (
+	defaultLatencyGuardWindow = 100
+
+	latencyGuardAbortIteration = "iteration"
+	latencyGuardAbortTest      = "test"
+)
+
+// LatencyGuardOptions configures SetLatencyGuard. The guard is breached once
+// the p95 of the last WindowSize calls, 100 by default, exceeds P95Ms. Abort
+// is what a breach ends: the iteration, by default, or the test.
+type LatencyGuardOptions struct {
+	P95Ms      float64 `js:"p95_ms"`
+	WindowSize int
+	Abort      string
+}
+
+// latencyGuardError fails a tool call that breached the latency guard of its
+// tool.
+type latencyGuardError struct {
+	tool      string
+	calls     int
+	p95       time.Duration
+	budget    time.Duration
+	abortTest bool
+}
+
+func (e *latencyGuardError) Error() string {
+	return fmt.Sprintf("latency guard of tool %q breached: the p95 of the last %d calls is %s, over %s",
+		e.tool, e.calls, e.p95, e.budget)
+}
+
+// latencyGuard keeps the durations of the last calls of a tool, in a ring.
+type latencyGuard struct {
+	budget    time.Duration
+	abortTest bool
+
+	mu     sync.Mutex
+	window []time.Duration
+	next   int
+	full   bool
+}
+
+// latencyGuards holds the latency guards of a client, by tool name.
+type latencyGuards struct {
+	mu     sync.Mutex
+	byTool map[string]*latencyGuard
+}
+
+// SetLatencyGuard fails the calls of the named tool made while the p95 of its
+// last calls exceeds a budget, throwing to end the iteration, or aborting the
+// test. The guard only judges once its window is full, and the windowed p95
+// is recorded in the mcp_latency_guard_p95 gauge after every call. Setting
+// the guard of a tool again starts over with an empty window.
+func (c *Client) SetLatencyGuard(name string, opts LatencyGuardOptions) error {
+	if opts.P95Ms <= 0 {
+		return errors.New("p95_ms must be positive")
+	}
+	if opts.WindowSize < 0 {
+		return errors.New("window_size must not be negative")
+	}
+	size := opts.WindowSize
+	if size == 0 {
+		size = defaultLatencyGuardWindow
+	}
+	switch opts.Abort {
+	case "", latencyGuardAbortIteration, latencyGuardAbortTest:
+	default:
+		return fmt.Errorf("unknown abort %q, expected %s or %s", opts.Abort, latencyGuardAbortIteration, latencyGuardAbortTest)
+	}
+
+	c.guards.mu.Lock()
+	defer c.guards.mu.Unlock()
+	if c.guards.byTool == nil {
+		c.guards.byTool = make(map[string]*latencyGuard)
+	}
+	c.guards.byTool[name] = &latencyGuard{
+		budget:    time.Duration(opts.P95Ms * float64(time.Millisecond)),
+		abortTest: opts.Abort == latencyGuardAbortTest,
+		window:    make([]time.Duration, size),
+	}
+	return nil
+}
+
+// guardLatency adds the duration of a call of the named tool to the window of
+// its latency guard, if it has one, records the windowed p95 and returns the
+// error failing the call if the guard is breached.
+func (c *Client) guardLatency(name string, duration time.Duration) error {
+	c.guards.mu.Lock()
+	g := c.guards.byTool[name]
+	c.guards.mu.Unlock()
+	if g == nil {
+		return nil
+	}
+
+	p95, calls, full := g.observe(duration)
+	c.metrics.PushLatencyGuard(c.ctx, name, p95)
+	if !full || p95 <= g.budget {
+		return nil
+	}
+	return &latencyGuardError{tool: name, calls: calls, p95: p95, budget: g.budget, abortTest: g.abortTest}
+}
+
+// observe adds d to the window, returning the p95 of the window, the number
+// of calls it holds and whether it is full.
+func (g *latencyGuard) observe(d time.Duration) (time.Duration, int, bool) {
+	g.mu.Lock()
+	g.window[g.next] = d
+	g.next = (g.next + 1) % len(g.window)
+	if g.next == 0 {
+		g.full = true
+	}
+	calls := g.next
+	if g.full {
+		calls = len(g.window)
+	}
+	sorted := slices.Clone(g.window[:calls])
+	full := g.full
+	g.mu.Unlock()
+
+	slices.Sort(sorted)
+	return sorted[(calls*95+99)/100-1], calls, full
+}
+
+// abortOnLatencyGuard aborts the test, from the VU goroutine, if err breached
+// a latency guard set to abort it.
+func (c *Client) abortOnLatencyGuard(err error) {
+	var guardErr *latencyGuardError
+	if errors.As(err, &guardErr) && guardErr.abortTest {
+		c.vu.Runtime().Interrupt(&errext.InterruptError{Reason: errext.AbortTest + ": " + err.Error()})
+	}
+}
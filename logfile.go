@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// logRecord is the line written to the LogFile for a notifications/message
+	// notification.
+	logRecord struct {
+		Level  mcp.LoggingLevel `json:"level"`
+		Logger string           `json:"logger,omitempty"`
+		Data   any              `json:"data"`
+		Ts     string           `json:"ts"`
+	}
+
+	// logFile appends the server logs received by every client logging to
+	// the same path. Lines are written as they arrive rather than buffered,
+	// since notifications keep arriving on pooled sessions after the VU that
+	// dialed them is done.
+	logFile struct {
+		path   string
+		logger logrus.FieldLogger
+
+		mu     sync.Mutex
+		file   *os.File
+		failed bool
+	}
+)
+
+// logFile returns the log file appending to path, opening it the first time
+// it is asked for.
+func (r *RootModule) logFile(path string, logger logrus.FieldLogger) (*logFile, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.logFiles[path]; ok {
+		return f, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log_file: %w", err)
+	}
+	f := &logFile{
+		path:   path,
+		logger: logger.WithField("log_file", path),
+		file:   file,
+	}
+	if r.logFiles == nil {
+		r.logFiles = make(map[string]*logFile)
+	}
+	r.logFiles[path] = f
+
+	return f, nil
+}
+
+func (f *logFile) write(params *mcp.LoggingMessageParams, received time.Time) {
+	line, err := json.Marshal(logRecord{
+		Level:  params.Level,
+		Logger: params.Logger,
+		Data:   params.Data,
+		Ts:     received.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		f.logger.WithError(err).Warn("Failed to encode a server log")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.file.Write(append(line, '\n')); err != nil && !f.failed {
+		// Writes keep failing after the first error, so it is only reported
+		// once.
+		f.failed = true
+		f.logger.WithError(err).Warn("Failed to write server logs")
+	}
+}
@@ -0,0 +1,184 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type emitDefaultsKey struct{}
+
+// withEmitDefaults returns ctx with the EmitDefaults marshal option, which
+// shapeParams applies to the params of the requests sent with ctx.
+func withEmitDefaults(ctx context.Context, emit bool) context.Context {
+	return context.WithValue(ctx, emitDefaultsKey{}, emit)
+}
+
+// shapeParams is the innermost sending middleware of every session, which
+// encodes the params of the requests sent with a withEmitDefaults context as
+// the option says, whatever the transport.
+func shapeParams(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		emit, ok := ctx.Value(emitDefaultsKey{}).(bool)
+		params := req.GetParams()
+		if !ok || params == nil || reflect.ValueOf(params).IsNil() {
+			return next(ctx, method, req)
+		}
+		return next(ctx, method, &shapedRequest{Request: req, params: &shapedParams{Params: params, emitDefaults: emit}})
+	}
+}
+
+// shapedRequest is a request whose params are replaced, keeping the rest of
+// the request it embeds.
+type shapedRequest struct {
+	mcp.Request
+	params mcp.Params
+}
+
+func (r *shapedRequest) GetParams() mcp.Params {
+	return r.params
+}
+
+// shapedParams encodes the params it embeds with every top-level field that
+// has an empty value sent, if emitDefaults is set, or left out otherwise.
+// Fields keep the order of the params struct.
+type shapedParams struct {
+	mcp.Params
+	emitDefaults bool
+}
+
+func (p *shapedParams) MarshalJSON() ([]byte, error) {
+	raw, err := json.Marshal(p.Params)
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.Indirect(reflect.ValueOf(p.Params))
+	if v.Kind() != reflect.Struct {
+		return raw, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, f := range reflect.VisibleFields(v.Type()) {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" || (f.Anonymous && name == "") {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		value, sent := fields[name]
+		var decoded any
+		switch {
+		case !sent && !p.emitDefaults:
+			continue
+		case !sent:
+			if value, err = json.Marshal(emptyValue(f.Type)); err != nil {
+				return nil, err
+			}
+		case !p.emitDefaults && json.Unmarshal(value, &decoded) == nil && isEmptyJSON(decoded):
+			continue
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// emptyValue returns the value a field of type t is sent with by
+// EmitDefaults when it was left out: an empty object or array for maps and
+// slices, and the zero value of the type, or of the type pointed to,
+// otherwise.
+func emptyValue(t reflect.Type) any {
+	switch t.Kind() {
+	case reflect.Map:
+		return map[string]any{}
+	case reflect.Slice:
+		return []any{}
+	case reflect.Pointer:
+		return emptyValue(t.Elem())
+	case reflect.Interface:
+		return nil
+	}
+	return reflect.Zero(t).Interface()
+}
+
+// unescapeHTML encodes the JSON document data again, without the \u003c,
+// \u003e and \u0026 escapes encoding/json gives <, > and & in strings. The
+// rest of the document is kept as is, including the order of the fields.
+func unescapeHTML(data []byte) ([]byte, error) {
+	if !bytes.Contains(data, []byte(`\u00`)) {
+		return data, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+
+	// containers holds, for every object or array the token is in, whether
+	// it is an object and the number of keys and values read in it.
+	type container struct {
+		object bool
+		tokens int
+	}
+	var containers []container
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return out.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			out.WriteByte(byte(d))
+			containers = containers[:len(containers)-1]
+			continue
+		}
+		if n := len(containers); n > 0 {
+			top := &containers[n-1]
+			switch {
+			case top.object && top.tokens%2 == 1:
+				out.WriteByte(':')
+			case top.tokens > 0:
+				out.WriteByte(',')
+			}
+			top.tokens++
+		}
+
+		switch tok := tok.(type) {
+		case json.Delim:
+			out.WriteByte(byte(tok))
+			containers = append(containers, container{object: tok == '{'})
+		case json.Number:
+			out.WriteString(tok.String())
+		default:
+			if err := enc.Encode(tok); err != nil {
+				return nil, err
+			}
+			// Encode ends every value with a newline.
+			out.Truncate(out.Len() - 1)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxTaggedMatrixCombinations is the largest matrix whose calls are tagged
+// with their argument combination. Larger ones would create a time series
+// per combination.
+const maxTaggedMatrixCombinations = 20
+
+type (
+	// CallToolMatrixOptions configures CallToolMatrix.
+	CallToolMatrixOptions struct {
+		// Concurrency is the number of calls made at once. Calls are made one
+		// after the other unless it is greater than 1.
+		Concurrency int
+	}
+
+	// MatrixResult is the outcome of the call made by CallToolMatrix for one
+	// argument combination. Key identifies the combination, as name=value
+	// pairs in the order of the argument names.
+	MatrixResult struct {
+		Key       string
+		Arguments map[string]any
+		Result    *mcp.CallToolResult
+		Error     string
+	}
+)
+
+// CallToolMatrix calls the named tool once for every combination of the
+// values listed for each argument, and returns the outcomes in the order of
+// the combinations, the last argument name varying fastest. Failed calls are
+// reported in their outcome rather than failing the matrix. The request
+// metrics of the calls are tagged with their combination as matrix, unless
+// there are more than maxTaggedMatrixCombinations of them.
+func (c *Client) CallToolMatrix(name string, matrix map[string]any, opts CallToolMatrixOptions) ([]MatrixResult, error) {
+	if err := c.checkReadOnly(name, nil); err != nil {
+		return nil, err
+	}
+	names := slices.Sorted(maps.Keys(matrix))
+	values := make([][]any, len(names))
+	combinations := 1
+	for i, arg := range names {
+		list, ok := matrix[arg].([]any)
+		if !ok {
+			return nil, fmt.Errorf("the values of argument %q must be an array", arg)
+		}
+		values[i] = list
+		combinations *= len(list)
+	}
+	if len(names) == 0 {
+		combinations = 0
+	}
+
+	results := make([]MatrixResult, combinations)
+	calls := make([]func(context.Context) (*mcp.CallToolResult, error), combinations)
+	for n := range combinations {
+		args := make(map[string]any, len(names))
+		pairs := make([]string, len(names))
+		// Decompose n into one index per argument, the last one varying
+		// fastest.
+		rest := n
+		for i := len(names) - 1; i >= 0; i-- {
+			v := values[i][rest%len(values[i])]
+			rest /= len(values[i])
+			args[names[i]] = v
+			pairs[i] = names[i] + "=" + matrixValue(v)
+		}
+		results[n] = MatrixResult{Key: strings.Join(pairs, ","), Arguments: args}
+		// Arguments are encoded here, on the VU, as binary ones need the
+		// runtime.
+		calls[n] = c.toolCall(mcp.CallToolParams{Name: name, Arguments: args})
+	}
+
+	c.notifications.iteration.Store(c.iteration())
+	_, _ = await(c, func() (struct{}, error) {
+		sem := make(chan struct{}, max(opts.Concurrency, 1))
+		var wg sync.WaitGroup
+		for n := range results {
+			var tags map[string]string
+			if combinations <= maxTaggedMatrixCombinations {
+				tags = map[string]string{"matrix": results[n].Key}
+			}
+			sem <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer func() { <-sem; wg.Done() }()
+				res, err := invoke(c, CallToolMethod, name, tags, strictly(c, intercepting(c, calls[n])))
+				if err != nil {
+					results[n].Error = err.Error()
+					return
+				}
+				results[n].Result = res
+			}()
+		}
+		wg.Wait()
+		return struct{}{}, nil
+	})
+
+	return results, nil
+}
+
+// matrixValue formats an argument value for the key of its combination:
+// strings as is, and anything else as JSON.
+func matrixValue(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(raw)
+}
@@ -3,10 +3,14 @@ package mcp
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/grafana/sobek"
@@ -14,8 +18,9 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules"
+	k6lib "go.k6.io/k6/lib"
 	k6metrics "go.k6.io/k6/metrics"
-	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/grafana/xk6-mcp/metrics"
 )
@@ -25,31 +30,242 @@ func init() {
 }
 
 type (
-	RootModule struct{}
+	RootModule struct {
+		// pools holds the session pools shared by every VU, by name,
+		// recorders the call recorders, by path, logFiles the server log
+		// files, by path, and breakers the circuit breakers of endpoint
+		// scope, by base URL
+		mu        sync.Mutex
+		pools     map[string]*sessionPool
+		recorders map[string]*callRecorder
+		logFiles  map[string]*logFile
+		breakers  map[string]*circuitBreaker
+
+		// dropped counts the metric samples dropped by every VU
+		dropped metrics.DroppedSamples
+
+		// traffic counts the HTTP bytes transferred by every client
+		traffic trafficTotals
+
+		// clock, if set, replaces the system clock for the metrics
+		clock metrics.Clock
+	}
 
 	// MCPInstance represents an instance of the MCP module
 	MCPInstance struct {
+		root     *RootModule
 		vu       modules.VU
 		logger   logrus.FieldLogger
 		registry *k6metrics.Registry
+
+		// clientDefaults is the config set by setDefaults, if any
+		clientDefaults *sobek.Object
 	}
 
 	// ClientConfig represents the configuration for the MCP client
 	ClientConfig struct {
 		// Stdio
-		Path  string
-		Args  []string
-		Env   map[string]string
-		Debug bool
+		Path    string
+		Args    []string
+		Env     map[string]string
+		EnvFile string
+		Debug   bool
+		// DumpFrames is the path of a file the raw bytes sent to and received
+		// from the server are appended to, for diagnosing framing issues
+		DumpFrames string
+		// Framing is how messages are delimited on the pipes of the server:
+		// newline, the default, or content-length for servers framing them
+		// with a Content-Length header like language servers
+		Framing string
 
 		// SSE and Streamable HTTP
-		BaseURL   string
-		Auth      AuthConfig
-		Stateless bool
+		BaseURL          string
+		Auth             AuthConfig
+		Stateless        bool
+		CompressRequests bool
+		DetailedTimings  bool
+		// ReadBufferSize sizes the connection read buffer, 4KB by default.
+		// DisableCompression asks for uncompressed responses, so SSE events
+		// are not held back by gzip decoding.
+		ReadBufferSize     int
+		DisableCompression bool
+		// ConnectJitter, if set, delays connecting by a random duration up
+		// to it, so that VUs starting together do not connect at once
+		ConnectJitter string
+		// KeepAlivePingInterval, if set, pings the server on this interval
+		// so that idle connections are not dropped
+		KeepAlivePingInterval string
+		// Tracing sends a traceparent header with every call and attaches
+		// its trace ID to the request duration metric
+		Tracing bool
+		// Baggage is sent as W3C baggage with every request when Tracing
+		// is enabled, for example to tag all the traffic of a test run
+		Baggage map[string]string
+		// CorrelationIDHeader, if set, is the header carrying a UUID
+		// generated for every call, which is also attached to its request
+		// duration metric and, with Tracing, sent as baggage
+		CorrelationIDHeader string
+		// Retries is how many times a request rejected with HTTP 429 is
+		// retried, after waiting for its Retry-After delay
+		Retries int
+		// MarshalOptions tunes the JSON of the messages sent to the server
+		MarshalOptions MarshalOptions
+		// TLSSessionCache lets new connections resume earlier TLS sessions
+		TLSSessionCache bool
+		// Middlewares wrap every HTTP request, the first one outermost
+		Middlewares       []string
+		MiddlewareOptions MiddlewareOptions
+
+		// Timeout bounds every call, unless MethodTimeouts has an entry for
+		// its tool name or method
+		Timeout        string
+		MethodTimeouts map[string]string
+
+		// ServerDurationMetaKey is the _meta key of the processing duration,
+		// in milliseconds, the server reports in its results, serverDurationMs
+		// by default
+		ServerDurationMetaKey string
+
+		// Caching of resources/read results
+		CacheReads bool
+		CacheTTL   string
+
+		// ToolCacheFile is the path of a file written by dumpToolCache, which
+		// seeds the tool cache so that getTool needs no tools/list
+		ToolCacheFile string
+
+		// Deduplicate makes concurrent read-only calls with the same params
+		// share a single request
+		Deduplicate bool
+
+		CircuitBreaker CircuitBreakerConfig
+		FaultInjection FaultInjectionConfig
+
+		// NotificationBufferSize is the number of server notifications kept
+		// for drainNotifications, 100 by default. Older ones are dropped, and
+		// counted, once it is full.
+		NotificationBufferSize int
+
+		// ThrowOnToolError makes callTool throw a ToolError for results
+		// flagged with isError, instead of returning them
+		ThrowOnToolError bool
+
+		// EmitChecks records a k6 check for every call, named after its
+		// method and, for tool calls, the tool, such as "tools/call echo". It
+		// passes unless the call failed or returned a result flagged with
+		// isError.
+		EmitChecks bool
+
+		// ReadOnly refuses to call tools that are not annotated as read-only,
+		// as a safety net against destructive calls. The annotations are
+		// taken from the tool cache, or listed once.
+		ReadOnly bool
+
+		// StrictMode fails calls on protocol-level warnings that are
+		// otherwise tolerated, for conformance testing
+		StrictMode bool
+
+		// MetricTags, if set, lists the tags the samples of the client may
+		// carry besides those of the VU, such as method or name. The others
+		// are dropped before pushing, to keep the cardinality down.
+		MetricTags []string
+
+		// RecordCalls is the path of a file every call is appended to, as a
+		// line of JSON
+		RecordCalls string
+
+		// LogFile is the path of a file every notifications/message received
+		// is appended to, as a line of JSON
+		LogFile string
+
+		// Handlers for server-initiated requests
+		OnCreateMessage sobek.Value
+		OnElicit        sobek.Value
 	}
 
+	// AuthConfig selects how HTTP requests are authenticated. Scheme is one
+	// of none, bearer, basic, oauth2_client_credentials or api_key; when
+	// empty it is inferred from whether BearerToken or APIKey is set.
 	AuthConfig struct {
+		Scheme string
+
+		// bearer
 		BearerToken string
+
+		// basic
+		Username string
+		Password string
+
+		// oauth2_client_credentials, with EndpointParams sent as extra form
+		// values of the token request, and TokenType overriding the type of
+		// the issued tokens in the Authorization header
+		ClientID       string
+		ClientSecret   string
+		TokenURL       string
+		Scopes         []string
+		EndpointParams map[string]string
+		TokenType      string
+
+		// api_key, sent in APIKeyHeader which defaults to X-API-Key
+		APIKey       string
+		APIKeyHeader string
+
+		// DirectAuthHeader sets the Authorization header of the bearer and
+		// oauth2_client_credentials schemes with a plain RoundTripper on top
+		// of the transport, instead of wrapping the client with oauth2
+		DirectAuthHeader bool
+	}
+
+	// MiddlewareOptions holds the parameters of the built-in middlewares.
+	// Each one only applies when its middleware is listed in Middlewares.
+	MiddlewareOptions struct {
+		FaultInjection   FaultInjectionOptions
+		LatencyInjection LatencyInjectionOptions
+	}
+
+	// FaultInjectionOptions fails Rate (0 to 1) of the requests with a
+	// synthetic transport error, without sending them.
+	FaultInjectionOptions struct {
+		Rate float64
+	}
+
+	// LatencyInjectionOptions delays Rate (0 to 1) of the requests by Latency
+	// before sending them.
+	LatencyInjectionOptions struct {
+		Rate    float64
+		Latency string
+	}
+
+	// FaultInjectionConfig fails ErrorRate (0 to 1) of the calls with a
+	// synthetic error and delays LatencyRate of them by LatencyMs. Setting
+	// Seed makes the injected faults repeat from one run to the next.
+	FaultInjectionConfig struct {
+		ErrorRate   float64
+		LatencyMs   int
+		LatencyRate float64
+		Seed        uint64
+	}
+
+	// CircuitBreakerConfig enables failing calls locally once the server
+	// keeps failing. It is disabled while FailureThreshold is zero.
+	CircuitBreakerConfig struct {
+		FailureThreshold int
+		OpenDuration     string
+		// Scope is client, the default, for a breaker of its own, or
+		// endpoint for one shared by every client of the same base URL
+		Scope string
+	}
+
+	// MarshalOptions tunes the JSON encoding of outgoing messages. Unset
+	// options keep the encoding of the SDK.
+	MarshalOptions struct {
+		// EscapeHTML set to false sends <, > and & in strings as is, rather
+		// than as \u003c, \u003e and \u0026. HTTP clients only.
+		EscapeHTML *bool
+		// EmitDefaults set to true sends every top-level field of the params
+		// of calls, even those left empty, and set to false leaves out every
+		// one that is empty.
+		EmitDefaults *bool
 	}
 )
 
@@ -57,6 +273,12 @@ func New() *RootModule {
 	return &RootModule{}
 }
 
+// NewWithClock works like New, with the metrics timed by clock rather than
+// the system clock, so that tests can assert exact durations.
+func NewWithClock(clock metrics.Clock) *RootModule {
+	return &RootModule{clock: clock}
+}
+
 var (
 	_ modules.Instance = &MCPInstance{}
 	_ modules.Module   = &RootModule{}
@@ -69,15 +291,18 @@ const (
 	ReadResourceMethod  = "resources/read"
 	ListPromptsMethod   = "prompts/list"
 	GetPromptMethod     = "prompts/get"
+	SubscribeMethod     = "resources/subscribe"
+	UnsubscribeMethod   = "resources/unsubscribe"
 )
 
 // NewModuleInstance initializes a new module instance
-func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+func (r *RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 	env := vu.InitEnv()
 
 	logger := env.Logger.WithField("component", "xk6-mcp")
 
 	return &MCPInstance{
+		root:     r,
 		vu:       vu,
 		logger:   logger,
 		registry: env.Registry,
@@ -87,187 +312,524 @@ func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 // Client wraps an MCP client session
 type Client struct {
 	ctx     context.Context
-	session *mcp.ClientSession
+	vu      modules.VU
+	session *liveSession
 	metrics *metrics.K6Metrics
+
+	// callbacks carries JS callbacks that must run on the VU goroutine
+	callbacks chan func()
+
+	// cache is nil unless CacheReads is enabled
+	cache *readCache
+	// interceptor and responseInterceptor are set by UseRequestInterceptor
+	// and UseResponseInterceptor
+	interceptor         sobek.Callable
+	responseInterceptor sobek.Callable
+	// strict is nil unless StrictMode is enabled
+	strict *strictChecker
+	// inFlight is nil unless Deduplicate is enabled
+	inFlight *singleflight.Group
+	// breaker is nil unless a circuit breaker is configured, and
+	// breakerEndpoint is only set when it is shared by its endpoint
+	breaker         *circuitBreaker
+	breakerEndpoint string
+	// faults is nil unless fault injection is configured
+	faults *faultInjector
+	// serverDurationKey is the _meta key of server processing durations
+	serverDurationKey string
+	// tracing enables a trace context per call
+	tracing bool
+	// correlation enables a correlation ID per call
+	correlation bool
+	// notifications buffers server notifications for DrainNotifications
+	notifications *notificationBuffer
+	// iteration returns the current VU iteration
+	iteration func() int64
+	// scenario returns the name of the current scenario
+	scenario func() string
+	// stats accumulates the totals reported by Stats
+	stats *clientStats
+	// scopes holds the scopes of the last OAuth2 token issued to the client
+	scopes *grantedScopes
+	// defaultArgs holds the arguments set by SetDefaultArgs, by tool name
+	defaultArgs map[string]map[string]any
+	// throwOnToolError turns tool results flagged with isError into errors
+	throwOnToolError bool
+	// emitDefaults is the EmitDefaults marshal option, if set
+	emitDefaults *bool
+	// emitChecks records a check for every call
+	emitChecks bool
+	// readOnly only lets read-only tools be called
+	readOnly bool
+	// retries is how many times rate-limited requests are retried
+	retries int
+	// guards holds the latency guards set by SetLatencyGuard
+	guards latencyGuards
+	// timeouts bounds the duration of calls
+	timeouts callTimeouts
+	// recorder is nil unless RecordCalls is set
+	recorder *callRecorder
+	// logFile is nil unless LogFile is set
+	logFile *logFile
+	// handlers holds the options built from the config of a pooled client,
+	// which its session calls into while it is leased to it
+	handlers *mcp.ClientOptions
+	// reinit is nil unless the session can be reinitialized, and initResult
+	// holds the result of the last reinitialization
+	reinit     *reinitializer
+	initResult atomic.Pointer[mcp.InitializeResult]
+	// tools caches the definitions of the listed or loaded tools
+	tools toolCache
+	// outputSchemas holds the $id of the output schemas of the listed tools,
+	// by tool name
+	outputSchemas sync.Map
+	// abortCtx is the context of in-flight calls, cancelled by AbortAll
+	abortMu  sync.Mutex
+	abortCtx context.Context
+	abort    context.CancelCauseFunc
+	// release is only set for clients acquired from a SessionPool
+	release  func()
+	released atomic.Bool
 }
 
 // Exports defines the JavaScript-accessible functions
 func (m *MCPInstance) Exports() modules.Exports {
 	return modules.Exports{
 		Named: map[string]interface{}{
-			"StdioClient":          m.newStdioClient,
-			"SSEClient":            m.newSSEClient,
-			"StreamableHTTPClient": m.newStreamableHTTPClient,
+			"StdioClient":            m.newStdioClient,
+			"SSEClient":              m.newSSEClient,
+			"StreamableHTTPClient":   m.newStreamableHTTPClient,
+			"SessionPool":            m.newSessionPool,
+			"ConnectionGroup":        m.newConnectionGroup,
+			"CompareClients":         m.compareClients,
+			"validateConfig":         m.validateConfig,
+			"droppedSamples":         m.droppedSamples,
+			"droppedSamplesByMethod": m.droppedSamplesByMethod,
+			"totalBytes":             m.totalBytes,
+			"flushRecordings":        m.flushRecordings,
+			"waitForServer":          m.waitForServer,
+			"probeColdStart":         m.probeColdStart,
+			"setDefaults":            m.setDefaults,
 		},
 	}
 }
 
-func (m *MCPInstance) newK6Metrics() *metrics.K6Metrics {
+func (m *MCPInstance) newK6Metrics(cfg ClientConfig) *metrics.K6Metrics {
 	return metrics.NewK6Metrics(
 		m.registry,
 		m.vu.State().Samples,
 		m.vu.State().Tags.GetCurrentValues(),
+		&m.root.dropped,
+		m.logger,
+		cfg.MetricTags,
+		m.root.clock,
 	)
 }
 
+// droppedSamples returns the number of MCP metric samples dropped so far
+// because a VU was already done when they were pushed.
+func (m *MCPInstance) droppedSamples() int64 {
+	return m.root.dropped.Count()
+}
+
+// droppedSamplesByMethod returns the counts of droppedSamples by the method
+// of the samples, or by metric name for samples of no method.
+func (m *MCPInstance) droppedSamplesByMethod() map[string]int64 {
+	return m.root.dropped.ByMethod()
+}
+
 func (m *MCPInstance) getContext() context.Context {
 	return m.vu.Context()
 }
 
-func (m *MCPInstance) newStdioClient(c sobek.ConstructorCall, rt *sobek.Runtime) *sobek.Object {
+// validateConfig lets scripts check a config for the given transport without
+// connecting.
+func (m *MCPInstance) validateConfig(transport string, config sobek.Value) error {
 	var cfg ClientConfig
-	if err := rt.ExportTo(c.Argument(0), &cfg); err != nil {
-		common.Throw(rt, fmt.Errorf("invalid config: %w", err))
+	if err := m.vu.Runtime().ExportTo(config, &cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
 	}
+	return ValidateConfig(cfg, transport)
+}
 
-	cmd := exec.Command(cfg.Path, cfg.Args...)
-	for k, v := range cfg.Env {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
-	}
+func (m *MCPInstance) newStdioClient(c sobek.ConstructorCall, rt *sobek.Runtime) *sobek.Object {
+	return m.newClient(rt, c.Argument(0), stdioTransport)
+}
 
-	if cfg.Debug {
-		cmd.Stderr = os.Stderr
-	}
+func (m *MCPInstance) newSSEClient(c sobek.ConstructorCall, rt *sobek.Runtime) *sobek.Object {
+	return m.newClient(rt, c.Argument(0), sseTransport)
+}
 
-	transport := &mcp.CommandTransport{
-		Command: cmd,
-	}
+func (m *MCPInstance) newStreamableHTTPClient(c sobek.ConstructorCall, rt *sobek.Runtime) *sobek.Object {
+	return m.newClient(rt, c.Argument(0), streamableHTTPTransport)
+}
 
-	clientObj := m.connect(rt, transport, false)
-	var client *Client
-	if err := rt.ExportTo(clientObj, &client); err != nil {
-		common.Throw(rt, fmt.Errorf("failed to extract Client: %w", err))
-	}
+// newTransport builds the SDK transport for kind from cfg, also reporting
+// whether the session should be stateless. HTTP traffic is counted in stats,
+// and goes over conns if set, or else over connections of its own. The scopes
+// of issued OAuth2 tokens are recorded in scopes, if set.
+func (m *MCPInstance) newTransport(cfg ClientConfig, kind string, stats *clientStats, scopes *grantedScopes, conns *http.Transport) (mcp.Transport, bool, error) {
+	switch kind {
+	case stdioTransport:
+		cmd := exec.Command(cfg.Path, cfg.Args...)
+		replacer := m.envReplacer()
+		if cfg.EnvFile != "" {
+			env, err := loadEnvFile(cfg.EnvFile)
+			if err != nil {
+				return nil, false, err
+			}
+			for _, kv := range env {
+				cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", kv[0], replacer.Replace(kv[1])))
+			}
+		}
+		// Later entries win, so Env takes precedence over EnvFile.
+		for k, v := range cfg.Env {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, replacer.Replace(v)))
+		}
 
-	return rt.ToValue(&Client{
-		ctx:     m.getContext(),
-		session: client.session,
-		metrics: m.newK6Metrics(),
-	}).ToObject(rt)
-}
+		if cfg.Debug {
+			cmd.Stderr = os.Stderr
+		}
 
-func (m *MCPInstance) newSSEClient(c sobek.ConstructorCall, rt *sobek.Runtime) *sobek.Object {
-	var cfg ClientConfig
-	if err := rt.ExportTo(c.Argument(0), &cfg); err != nil {
-		common.Throw(rt, fmt.Errorf("invalid config: %w", err))
+		return &commandTransport{
+			CommandTransport: mcp.CommandTransport{Command: cmd},
+			dumpFrames:       cfg.DumpFrames,
+			framing:          cfg.Framing,
+		}, false, nil
+	case sseTransport:
+		logger := m.sessionLogger(kind)
+		httpClient := m.newk6HTTPClient(cfg, stats, scopes, logger, conns)
+		// Resumed streams go through the whole chain again, auth included.
+		httpClient.Transport = &sseResumeRoundTripper{
+			next:    httpClient.Transport,
+			ctx:     m.getContext(),
+			metrics: m.newK6Metrics(cfg),
+			logger:  logger,
+		}
+		return &mcp.SSEClientTransport{
+			Endpoint:   cfg.BaseURL,
+			HTTPClient: httpClient,
+		}, true, nil
+	default:
+		httpClient := m.newk6HTTPClient(cfg, stats, scopes, m.sessionLogger(kind), conns)
+		httpClient.Transport = &conditionalRoundTripper{next: httpClient.Transport}
+		return &mcp.StreamableClientTransport{
+			Endpoint:   cfg.BaseURL,
+			HTTPClient: httpClient,
+		}, cfg.Stateless, nil
 	}
+}
 
-	transport := &mcp.SSEClientTransport{
-		Endpoint:   cfg.BaseURL,
-		HTTPClient: m.newk6HTTPClient(cfg),
+func (m *MCPInstance) newk6HTTPClient(cfg ClientConfig, stats *clientStats, scopes *grantedScopes, logger logrus.FieldLogger, conns *http.Transport) *http.Client {
+	if conns == nil {
+		conns = m.newHTTPTransport(cfg)
 	}
-
-	clientObj := m.connect(rt, transport, true)
-	var client *Client
-	if err := rt.ExportTo(clientObj, &client); err != nil {
-		common.Throw(rt, fmt.Errorf("failed to extract Client: %w", err))
+	var roundTripper http.RoundTripper = &statsRoundTripper{
+		next:    conns,
+		stats:   stats,
+		logger:  logger,
+		traffic: &m.root.traffic,
+		ctx:     m.getContext(),
+		metrics: m.newK6Metrics(cfg),
 	}
-
-	return rt.ToValue(&Client{
+	if strings.HasPrefix(cfg.BaseURL, "https:") {
+		roundTripper = &tlsRoundTripper{
+			next:    roundTripper,
+			ctx:     m.getContext(),
+			metrics: m.newK6Metrics(cfg),
+		}
+	}
+	if cfg.CompressRequests {
+		roundTripper = &gzipRoundTripper{
+			next:    roundTripper,
+			ctx:     m.getContext(),
+			metrics: m.newK6Metrics(cfg),
+		}
+	}
+	if cfg.MarshalOptions.EscapeHTML != nil && !*cfg.MarshalOptions.EscapeHTML {
+		roundTripper = &unescapeHTMLRoundTripper{next: roundTripper}
+	}
+	if cfg.DetailedTimings {
+		roundTripper = &timingRoundTripper{
+			next:    roundTripper,
+			ctx:     m.getContext(),
+			metrics: m.newK6Metrics(cfg),
+		}
+	}
+	roundTripper = &rateLimitRoundTripper{
+		next:    roundTripper,
 		ctx:     m.getContext(),
-		session: client.session,
-		metrics: m.newK6Metrics(),
-	}).ToObject(rt)
-}
-
-func (m *MCPInstance) newStreamableHTTPClient(c sobek.ConstructorCall, rt *sobek.Runtime) *sobek.Object {
-	var cfg ClientConfig
-	if err := rt.ExportTo(c.Argument(0), &cfg); err != nil {
-		common.Throw(rt, fmt.Errorf("invalid config: %w", err))
+		metrics: m.newK6Metrics(cfg),
+		retries: cfg.Retries,
 	}
-
-	transport := &mcp.StreamableClientTransport{
-		Endpoint:   cfg.BaseURL,
-		HTTPClient: m.newk6HTTPClient(cfg),
+	roundTripper = m.withMiddlewares(roundTripper, cfg.Middlewares, cfg.MiddlewareOptions)
+	if cfg.Tracing {
+		roundTripper = &tracingRoundTripper{next: roundTripper, baggage: encodeBaggage(cfg.Baggage)}
 	}
+	if cfg.CorrelationIDHeader != "" {
+		roundTripper = &correlationRoundTripper{next: roundTripper, header: cfg.CorrelationIDHeader}
+	}
+	roundTripper = &statusRoundTripper{next: roundTripper}
 
-	clientObj := m.connect(rt, transport, cfg.Stateless)
-	var client *Client
-	if err := rt.ExportTo(clientObj, &client); err != nil {
-		common.Throw(rt, fmt.Errorf("failed to extract Client: %w", err))
+	httpClient := &http.Client{
+		Transport: roundTripper,
 	}
 
-	return rt.ToValue(&Client{
-		ctx:     m.getContext(),
-		session: client.session,
-		metrics: m.newK6Metrics(),
-	}).ToObject(rt)
+	return withAuth(httpClient, cfg.Auth, scopes)
 }
 
-func (m *MCPInstance) newk6HTTPClient(cfg ClientConfig) *http.Client {
+// newHTTPTransport builds the connections of an HTTP client, through the VU
+// dialer and with its TLS settings.
+func (m *MCPInstance) newHTTPTransport(cfg ClientConfig) *http.Transport {
 	var tlsConfig *tls.Config
 	if m.vu.State().TLSConfig != nil {
 		tlsConfig = m.vu.State().TLSConfig.Clone()
 		tlsConfig.NextProtos = []string{"http/1.1"}
+		if cfg.TLSSessionCache {
+			tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+		}
 	}
 
-	transport := http.Transport{
-		Proxy:             http.ProxyFromEnvironment,
-		TLSClientConfig:   tlsConfig,
-		DisableKeepAlives: m.vu.State().Options.NoConnectionReuse.ValueOrZero() || m.vu.State().Options.NoVUConnectionReuse.ValueOrZero(),
+	transport := &http.Transport{
+		Proxy:              http.ProxyFromEnvironment,
+		TLSClientConfig:    tlsConfig,
+		DisableKeepAlives:  m.vu.State().Options.NoConnectionReuse.ValueOrZero() || m.vu.State().Options.NoVUConnectionReuse.ValueOrZero(),
+		ReadBufferSize:     cfg.ReadBufferSize,
+		DisableCompression: cfg.DisableCompression,
 	}
 
 	if m.vu.State().Dialer != nil {
 		transport.DialContext = m.vu.State().Dialer.DialContext
 	}
-	httpClient := &http.Client{
-		Transport: &transport,
+	return transport
+}
+
+// newClient connects a client of the given transport kind configured by
+// config, and wraps it for JS.
+func (m *MCPInstance) newClient(rt *sobek.Runtime, config sobek.Value, kind string) *sobek.Object {
+	var cfg ClientConfig
+	if err := rt.ExportTo(m.withClientDefaults(rt, config), &cfg); err != nil {
+		common.Throw(rt, fmt.Errorf("invalid config: %w", err))
+	}
+	if err := ValidateConfig(cfg, kind); err != nil {
+		common.Throw(rt, err)
 	}
 
-	if cfg.Auth.BearerToken != "" {
-		// Explicitly creating a dummy context for the oauth2 library
-		// to pull the http.Client from
-		ctx := context.Background()
+	client, err := m.wrapClient(cfg)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	transport, isStateless, err := m.newTransport(cfg, kind, client.stats, client.scopes, nil)
+	if err != nil {
+		common.Throw(rt, err)
+	}
 
-		ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+	opts := client.clientOptions(rt, cfg)
+	opts.Stateless = isStateless
+	client.reinit = newReinitializer(transport)
+	transport = client.strictTransport(transport)
+	if err := client.waitConnectJitter(m.getContext(), durationOrZero(cfg.ConnectJitter)); err != nil {
+		common.Throw(rt, err)
+	}
+	client.session = m.connect(rt, kind, transport, isStateless, opts, client.timeRootsList)
+	if err := client.checkNegotiatedVersion(); err != nil {
+		_ = client.session.Close()
+		common.Throw(rt, fmt.Errorf("connection error: %w", err))
+	}
+	client.startKeepAlive(client.session, durationOrZero(cfg.KeepAlivePingInterval))
+
+	return rt.ToValue(client).ToObject(rt)
+}
 
-		token := oauth2.Token{
-			AccessToken: cfg.Auth.BearerToken,
+// wrapClient builds the JS-facing Client for cfg, without its session.
+func (m *MCPInstance) wrapClient(cfg ClientConfig) (*Client, error) {
+	client := &Client{
+		ctx:           m.getContext(),
+		vu:            m.vu,
+		metrics:       m.newK6Metrics(cfg),
+		callbacks:     make(chan func()),
+		notifications: newNotificationBuffer(cfg.NotificationBufferSize),
+		tracing:       cfg.Tracing,
+		correlation:   cfg.CorrelationIDHeader != "",
+		stats:         &clientStats{},
+		scopes:        &grantedScopes{},
+
+		throwOnToolError: cfg.ThrowOnToolError,
+		emitDefaults:     cfg.MarshalOptions.EmitDefaults,
+		emitChecks:       cfg.EmitChecks,
+		readOnly:         cfg.ReadOnly,
+		retries:          cfg.Retries,
+		timeouts:         newCallTimeouts(cfg),
+		iteration: func() int64 {
+			if state := m.vu.State(); state != nil {
+				return state.Iteration
+			}
+			return 0
+		},
+		scenario: func() string {
+			if s := k6lib.GetScenarioState(m.getContext()); s != nil {
+				return s.Name
+			}
+			return ""
+		},
+	}
+	client.notifications.dropped = func(method string) {
+		client.metrics.PushNotificationDropped(client.ctx, method)
+	}
+	if cfg.CacheReads {
+		client.cache = newReadCache(durationOrZero(cfg.CacheTTL))
+	}
+	client.serverDurationKey = cfg.ServerDurationMetaKey
+	if client.serverDurationKey == "" {
+		client.serverDurationKey = defaultServerDurationMetaKey
+	}
+	if cfg.StrictMode {
+		client.strict = newStrictChecker()
+	}
+	if cfg.Deduplicate {
+		client.inFlight = &singleflight.Group{}
+	}
+	if cfg.CircuitBreaker.FailureThreshold > 0 {
+		client.breaker = m.root.circuitBreaker(cfg.BaseURL, cfg.CircuitBreaker)
+		if cfg.CircuitBreaker.Scope == breakerScopeEndpoint {
+			client.breakerEndpoint = cfg.BaseURL
 		}
-		tokenSource := oauth2.StaticTokenSource(&token)
+	}
+	if fi := cfg.FaultInjection; fi.ErrorRate > 0 || fi.LatencyRate > 0 {
+		client.faults = newFaultInjector(fi)
+	}
+	if cfg.ToolCacheFile != "" {
+		if err := client.loadToolCache(cfg.ToolCacheFile); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.LogFile != "" {
+		f, err := m.root.logFile(cfg.LogFile, m.logger)
+		if err != nil {
+			return nil, err
+		}
+		client.logFile = f
+	}
+	if cfg.RecordCalls != "" {
+		recorder, err := m.root.callRecorder(cfg.RecordCalls, m.logger)
+		if err != nil {
+			return nil, err
+		}
+		client.recorder = recorder
+		// Records still buffered when the VU is done would otherwise be
+		// lost if the script does not flush them in teardown.
+		go func() {
+			<-client.ctx.Done()
+			if err := recorder.flush(); err != nil {
+				m.logger.WithError(err).Warn("Failed to record calls")
+			}
+		}()
+	}
+
+	return client, nil
+}
 
-		httpClient = oauth2.NewClient(ctx, tokenSource)
+func (m *MCPInstance) connect(rt *sobek.Runtime, kind string, transport mcp.Transport, isStateless bool, opts *mcp.ClientOptions, receiving ...mcp.Middleware) *liveSession {
+	session, err := dial(m.getContext(), m.sessionLogger(kind), transport, isStateless, opts, receiving...)
+	if err != nil {
+		common.Throw(rt, fmt.Errorf("connection error: %w", err))
 	}
 
-	return httpClient
+	return session
+}
+
+// sessionLogger returns the logger of the sessions of the given transport
+// kind.
+func (m *MCPInstance) sessionLogger(kind string) logrus.FieldLogger {
+	return m.logger.WithField("transport", kind)
 }
 
-func (m *MCPInstance) connect(rt *sobek.Runtime, transport mcp.Transport, isStateless bool) *sobek.Object {
-	var ctx context.Context
-	var cancel context.CancelFunc
-	if isStateless {
-		ctx = m.getContext()
-		cancel = func() {}
-	} else {
-		ctx, cancel = context.WithTimeout(m.getContext(), 30*time.Second)
+// dial starts an MCP session over transport, passing the requests of the
+// server through receiving. Stateful sessions must be established within 30
+// seconds; stateless ones are only bound by parent. The connection itself
+// lives until parent is done or the session is closed.
+func dial(parent context.Context, logger logrus.FieldLogger, transport mcp.Transport, isStateless bool, opts *mcp.ClientOptions, receiving ...mcp.Middleware) (*liveSession, error) {
+	start := time.Now()
+	ctx, cancel := context.WithCancel(parent)
+	var timeout *time.Timer
+	if !isStateless {
+		timeout = time.AfterFunc(30*time.Second, cancel)
 	}
-	defer cancel()
 
-	client := mcp.NewClient(&mcp.Implementation{Name: "k6", Version: "1.0.0"}, &mcp.ClientOptions{
-		Stateless: isStateless,
-	})
+	client := mcp.NewClient(&mcp.Implementation{Name: "k6", Version: "1.0.0"}, opts)
+	var initialize *mcp.InitializeParams
+	client.AddSendingMiddleware(interceptRequests, trackProgressTokens, recordInitializeParams(&initialize), shapeParams)
+	client.AddReceivingMiddleware(receiving...)
 	session, err := client.Connect(ctx, transport, nil)
+	if timeout != nil {
+		timeout.Stop()
+	}
 	if err != nil {
-		common.Throw(rt, fmt.Errorf("connection error: %w", err))
+		cancel()
+		logger.WithField("duration", time.Since(start)).WithError(err).Debug("MCP session failed to connect")
+		return nil, err
+	}
+	logger.WithField("duration", time.Since(start)).Debug("MCP session connected")
+	// Servers answer with the newest version they support, which the SDK
+	// accepts as long as it supports it too.
+	if requested, negotiated := initialize.ProtocolVersion, session.InitializeResult().ProtocolVersion; downgraded(requested, negotiated) {
+		logger.WithField("requested_version", requested).WithField("protocol_version", negotiated).
+			Info("MCP server negotiated an older protocol version")
 	}
+	live := watchSession(session, logger)
+	live.initializeParams = initialize
+	go func() {
+		<-live.closed
+		cancel()
+	}()
+	return live, nil
+}
 
-	return rt.ToValue(&Client{session: session}).ToObject(rt)
+// IsConnected reports whether the session is still connected, without a
+// round trip to the server. Released pooled clients are never connected.
+func (c *Client) IsConnected() bool {
+	return !c.released.Load() && c.session.connected()
+}
+
+// Close ends the session, stopping its keep-alive pings. Pooled clients
+// must be released instead, since their session is shared.
+func (c *Client) Close() error {
+	if c.release != nil {
+		return errPooledClose
+	}
+	return c.session.Close()
 }
 
 func (c *Client) Ping() bool {
-	err := c.session.Ping(c.ctx, &mcp.PingParams{})
+	_, err := await(c, func() (struct{}, error) {
+		return struct{}{}, c.session.Ping(c.ctx, &mcp.PingParams{})
+	})
 	return err == nil
 }
 
 func (c *Client) ListTools(r mcp.ListToolsParams) (*mcp.ListToolsResult, error) {
-	start := time.Now()
-	res, err := c.session.ListTools(c.ctx, &r)
-	c.metrics.Push(c.ctx, ListToolsMethod, time.Since(start), err)
+	return call(c, ListToolsMethod, func(ctx context.Context) (*mcp.ListToolsResult, error) {
+		return c.listTools(ctx, &r)
+	})
+}
+
+// listTools lists a page of tools, for ListTools and Catalog.
+func (c *Client) listTools(ctx context.Context, r *mcp.ListToolsParams) (*mcp.ListToolsResult, error) {
+	recordParams(ctx, r)
+	res, err := deduplicate(ctx, c, ListToolsMethod, r, func() (*mcp.ListToolsResult, error) {
+		return c.session.ListTools(ctx, r)
+	})
+	if err == nil {
+		c.rememberTools(res.Tools)
+		c.metrics.PushListResultSize(c.ctx, ListToolsMethod, len(res.Tools))
+	}
 	return res, err
 }
 
 type ListAllToolsParams struct {
 	Meta mcp.Meta
+	// AnnotationFilter, if set, only keeps the tools whose annotations match
+	AnnotationFilter *ToolAnnotationFilter
 }
 
 type ListAllToolsResult struct {
@@ -294,7 +856,7 @@ func (c *Client) ListAllTools(r ListAllToolsParams) (*ListAllToolsResult, error)
 		}
 
 		for _, t := range result.Tools {
-			if t != nil {
+			if t != nil && r.AnnotationFilter.matches(t) {
 				allTools = append(allTools, *t)
 			}
 		}
@@ -314,43 +876,146 @@ func (c *Client) ListAllTools(r ListAllToolsParams) (*ListAllToolsResult, error)
 	}, nil
 }
 
-func (c *Client) CallTool(r mcp.CallToolParams) (*mcp.CallToolResult, error) {
-	start := time.Now()
-	result, err := c.session.CallTool(c.ctx, &r)
-	c.metrics.Push(c.ctx, CallToolMethod, time.Since(start), err)
-	return result, err
+// CallToolOptions holds the options of a single tool call.
+type CallToolOptions struct {
+	// Tags are added to the request metrics of the call, for example to
+	// tell apart the rows of a data-driven test. They are only ever set by
+	// the script, to keep the cardinality of the metrics under its control.
+	Tags map[string]string
+}
+
+func (c *Client) CallTool(r mcp.CallToolParams, opts CallToolOptions) (*mcp.CallToolResult, error) {
+	if _, ok := opts.Tags["method"]; ok {
+		return nil, errors.New(`the "method" tag is reserved`)
+	}
+	return c.callTool(r, opts.Tags)
+}
+
+// callTool calls a tool, adding tags to the request metrics.
+func (c *Client) callTool(r mcp.CallToolParams, tags map[string]string) (*mcp.CallToolResult, error) {
+	if err := c.checkReadOnly(r.Name, tags); err != nil {
+		return nil, err
+	}
+	return callNamed(c, CallToolMethod, r.Name, tags, c.toolCall(r))
+}
+
+// toolCall returns the work of calling a tool as r requests, for callNamed,
+// or for invoke when several calls run at once.
+func (c *Client) toolCall(r mcp.CallToolParams) func(context.Context) (*mcp.CallToolResult, error) {
+	args, pointers, size := encodeBinaryArguments(c.withDefaultArgs(r.Name, r.Arguments))
+	r.Arguments = args
+	if len(pointers) > 0 {
+		if r.Meta == nil {
+			r.Meta = mcp.Meta{}
+		}
+		r.Meta[binaryArgumentsMetaKey] = pointers
+		c.metrics.PushBinaryUpload(c.ctx, CallToolMethod, size)
+	}
+
+	return func(ctx context.Context) (*mcp.CallToolResult, error) {
+		params := r
+		recordParams(ctx, &params)
+		ctx, progress := withProgress(ctx)
+		res, err := c.session.CallTool(ctx, &params)
+		if updates := progress.Load(); updates > 0 {
+			setResultTag(ctx, "had_progress", "true")
+			c.metrics.PushProgressUpdates(c.ctx, CallToolMethod, int(updates))
+		}
+		if err == nil {
+			c.annotateOutputSchema(r.Name, res)
+		}
+		if err == nil && res.IsError && c.throwOnToolError {
+			return nil, newToolError(r.Name, res)
+		}
+		return res, err
+	}
 }
 
 func (c *Client) ListResources(r mcp.ListResourcesParams) (*mcp.ListResourcesResult, error) {
-	start := time.Now()
-	res, err := c.session.ListResources(c.ctx, &r)
-	c.metrics.Push(c.ctx, ListResourcesMethod, time.Since(start), err)
+	return call(c, ListResourcesMethod, func(ctx context.Context) (*mcp.ListResourcesResult, error) {
+		return c.listResources(ctx, &r)
+	})
+}
+
+// listResources lists a page of resources, for ListResources,
+// ResourcesIterator and Catalog.
+func (c *Client) listResources(ctx context.Context, r *mcp.ListResourcesParams) (*mcp.ListResourcesResult, error) {
+	recordParams(ctx, r)
+	res, err := deduplicate(ctx, c, ListResourcesMethod, r, func() (*mcp.ListResourcesResult, error) {
+		return c.session.ListResources(ctx, r)
+	})
+	if err == nil {
+		c.metrics.PushListResultSize(c.ctx, ListResourcesMethod, len(res.Resources))
+	}
 	return res, err
 }
 
 func (c *Client) ReadResource(r mcp.ReadResourceParams) (*mcp.ReadResourceResult, error) {
-	start := time.Now()
-	res, err := c.session.ReadResource(c.ctx, &r)
-	c.metrics.Push(c.ctx, ReadResourceMethod, time.Since(start), err)
+	return c.readResource(r, nil)
+}
+
+// readResource reads a resource through the cache, adding tags to the request
+// metrics.
+func (c *Client) readResource(r mcp.ReadResourceParams, tags map[string]string) (*mcp.ReadResourceResult, error) {
+	if c.cache != nil {
+		if res, ok := c.cache.get(r.URI); ok {
+			c.metrics.PushCacheHit(c.ctx, ReadResourceMethod)
+			return res, nil
+		}
+	}
+
+	res, err := callWithTags(c, ReadResourceMethod, tags, func(ctx context.Context) (*mcp.ReadResourceResult, error) {
+		recordParams(ctx, &r)
+		return deduplicate(ctx, c, ReadResourceMethod, r, func() (*mcp.ReadResourceResult, error) {
+			return c.session.ReadResource(ctx, &r)
+		})
+	})
+
+	if err == nil && c.cache != nil {
+		c.cache.put(r.URI, res)
+	}
 	return res, err
 }
 
+func (c *Client) Subscribe(r mcp.SubscribeParams) error {
+	_, err := call(c, SubscribeMethod, func(ctx context.Context) (struct{}, error) {
+		recordParams(ctx, &r)
+		return struct{}{}, c.session.Subscribe(ctx, &r)
+	})
+	return err
+}
+
+func (c *Client) Unsubscribe(r mcp.UnsubscribeParams) error {
+	_, err := call(c, UnsubscribeMethod, func(ctx context.Context) (struct{}, error) {
+		recordParams(ctx, &r)
+		return struct{}{}, c.session.Unsubscribe(ctx, &r)
+	})
+	return err
+}
+
 func (c *Client) ListPrompts(r mcp.ListPromptsParams) (*mcp.ListPromptsResult, error) {
-	start := time.Now()
-	res, err := c.session.ListPrompts(c.ctx, &r)
-	c.metrics.Push(c.ctx, ListPromptsMethod, time.Since(start), err)
-	return res, err
+	return call(c, ListPromptsMethod, func(ctx context.Context) (*mcp.ListPromptsResult, error) {
+		return c.listPrompts(ctx, &r)
+	})
 }
 
-func (c *Client) GetPrompt(r mcp.GetPromptParams) (*mcp.GetPromptResult, error) {
-	start := time.Now()
-	res, err := c.session.GetPrompt(c.ctx, &r)
-	c.metrics.Push(c.ctx, GetPromptMethod, time.Since(start), err)
+// listPrompts lists a page of prompts, for ListPrompts and Catalog.
+func (c *Client) listPrompts(ctx context.Context, r *mcp.ListPromptsParams) (*mcp.ListPromptsResult, error) {
+	recordParams(ctx, r)
+	res, err := deduplicate(ctx, c, ListPromptsMethod, r, func() (*mcp.ListPromptsResult, error) {
+		return c.session.ListPrompts(ctx, r)
+	})
+	if err == nil {
+		c.metrics.PushListResultSize(c.ctx, ListPromptsMethod, len(res.Prompts))
+	}
 	return res, err
 }
 
 type ListAllResourcesParams struct {
 	Meta mcp.Meta
+	// MIMEType, if set, only keeps the resources of this MIME type, or of
+	// any subtype for patterns such as image/*
+	MIMEType string `js:"mime_type"`
 }
 
 type ListAllResourcesResult struct {
@@ -377,7 +1042,7 @@ func (c *Client) ListAllResources(r ListAllResourcesParams) (*ListAllResourcesRe
 		}
 
 		for _, res := range result.Resources {
-			if res != nil {
+			if res != nil && matchesMIMEType(r.MIMEType, res.MIMEType) {
 				allResources = append(allResources, *res)
 			}
 		}
@@ -397,8 +1062,76 @@ func (c *Client) ListAllResources(r ListAllResourcesParams) (*ListAllResourcesRe
 	}, nil
 }
 
+func (c *Client) ListResourceTemplates(r mcp.ListResourceTemplatesParams) (*mcp.ListResourceTemplatesResult, error) {
+	return call(c, ListResourceTemplatesMethod, func(ctx context.Context) (*mcp.ListResourceTemplatesResult, error) {
+		return c.listResourceTemplates(ctx, &r)
+	})
+}
+
+// listResourceTemplates lists a page of resource templates, for
+// ListResourceTemplates and Catalog.
+func (c *Client) listResourceTemplates(ctx context.Context, r *mcp.ListResourceTemplatesParams) (*mcp.ListResourceTemplatesResult, error) {
+	recordParams(ctx, r)
+	res, err := c.session.ListResourceTemplates(ctx, r)
+	if err == nil {
+		c.metrics.PushListResultSize(c.ctx, ListResourceTemplatesMethod, len(res.ResourceTemplates))
+	}
+	return res, err
+}
+
+type ListAllResourceTemplatesParams struct {
+	Meta mcp.Meta
+}
+
+type ListAllResourceTemplatesResult struct {
+	ResourceTemplates []mcp.ResourceTemplate
+}
+
+func (c *Client) ListAllResourceTemplates(r ListAllResourceTemplatesParams) (*ListAllResourceTemplatesResult, error) {
+	if r.Meta == nil {
+		r.Meta = mcp.Meta{}
+	}
+
+	var allTemplates []mcp.ResourceTemplate
+	cursor := ""
+	var err error
+	for {
+		params := mcp.ListResourceTemplatesParams{Meta: r.Meta}
+		if cursor != "" {
+			params.Cursor = cursor
+		}
+		var result *mcp.ListResourceTemplatesResult
+		result, err = c.ListResourceTemplates(params)
+		if err != nil {
+			break
+		}
+
+		for _, t := range result.ResourceTemplates {
+			if t != nil {
+				allTemplates = append(allTemplates, *t)
+			}
+		}
+
+		if result.NextCursor == "" {
+			break
+		}
+		cursor = result.NextCursor
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resource templates: %w", err)
+	}
+
+	return &ListAllResourceTemplatesResult{
+		ResourceTemplates: allTemplates,
+	}, nil
+}
+
 type ListAllPromptsParams struct {
 	Meta mcp.Meta
+	// HasArgument, if set, only keeps the prompts declaring an argument of
+	// this name
+	HasArgument string
 }
 
 type ListAllPromptsResult struct {
@@ -425,7 +1158,7 @@ func (c *Client) ListAllPrompts(r ListAllPromptsParams) (*ListAllPromptsResult,
 		}
 
 		for _, p := range result.Prompts {
-			if p != nil {
+			if p != nil && hasPromptArgument(p, r.HasArgument) {
 				allPrompts = append(allPrompts, *p)
 			}
 		}
@@ -2,19 +2,101 @@ package metrics
 
 import (
 	"context"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	k6metrics "go.k6.io/k6/metrics"
 )
 
 type (
 	K6Metrics struct {
-		samples               chan<- k6metrics.SampleContainer
-		tagsAndMeta           k6metrics.TagsAndMeta
+		samples     chan<- k6metrics.SampleContainer
+		tagsAndMeta k6metrics.TagsAndMeta
+		dropped     *DroppedSamples
+		logger      logrus.FieldLogger
+		clock       Clock
+		// allowedTags, unless nil, holds the only tags kept besides those of
+		// the VU
+		allowedTags           map[string]struct{}
 		requestDuration       *k6metrics.Metric
 		requestCount          *k6metrics.Metric
 		requestErrors         *k6metrics.Metric
 		requestErrorsDuration *k6metrics.Metric
+		requestTotalDuration  *k6metrics.Metric
+		handlerDuration       *k6metrics.Metric
+		cacheHits             *k6metrics.Metric
+		binaryUploadBytes     *k6metrics.Metric
+		circuitState          *k6metrics.Metric
+		circuitRejected       *k6metrics.Metric
+		poolWaitDuration      *k6metrics.Metric
+		poolActive            *k6metrics.Metric
+		poolIdle              *k6metrics.Metric
+		poolSize              *k6metrics.Metric
+		requestRawBytes       *k6metrics.Metric
+		requestCompressed     *k6metrics.Metric
+		dnsDuration           *k6metrics.Metric
+		tcpConnectDuration    *k6metrics.Metric
+		tlsDuration           *k6metrics.Metric
+		ttfb                  *k6metrics.Metric
+		paginationPages       *k6metrics.Metric
+		sequenceDuration      *k6metrics.Metric
+		keepAlivePings        *k6metrics.Metric
+		rateLimited           *k6metrics.Metric
+		progressUpdates       *k6metrics.Metric
+		notificationWait      *k6metrics.Metric
+		tlsResumed            *k6metrics.Metric
+		sseGaps               *k6metrics.Metric
+		reinitialize          *k6metrics.Metric
+		listResultSize        *k6metrics.Metric
+		serverProcessing      *k6metrics.Metric
+		connectJitter         *k6metrics.Metric
+		deduplicated          *k6metrics.Metric
+		groupConnections      *k6metrics.Metric
+		integrityMismatches   *k6metrics.Metric
+		coldStart             *k6metrics.Metric
+		totalBytes            *k6metrics.Metric
+		watchLatency          *k6metrics.Metric
+		notificationsDropped  *k6metrics.Metric
+		rootsListServed       *k6metrics.Metric
+		rootsListDuration     *k6metrics.Metric
+		latencyGuardP95       *k6metrics.Metric
+		// checks is the built-in metric of k6 checks
+		checks *k6metrics.Metric
+	}
+
+	// Clock tells the time. K6Metrics stamps samples with it, and the
+	// durations pushed are measured with it, so that tests can control both.
+	Clock interface {
+		Now() time.Time
+	}
+
+	systemClock struct{}
+
+	// DroppedSamples counts the samples that could not be pushed because the
+	// VU was already done. It is meant to be shared by every K6Metrics of a
+	// test run.
+	DroppedSamples struct {
+		count  atomic.Int64
+		warned atomic.Bool
+
+		mu sync.Mutex
+		// byMethod counts the samples dropped by the method they are tagged
+		// with, or by metric name for samples without one
+		byMethod map[string]int64
+	}
+
+	// HTTPTimings holds the phases of a single HTTP request. Phases that did
+	// not happen, such as DNS lookup on a reused connection, are zero.
+	HTTPTimings struct {
+		DNS        time.Duration
+		TCPConnect time.Duration
+		TLS        time.Duration
+		TTFB       time.Duration
 	}
 )
 
@@ -23,58 +105,808 @@ const (
 	requestCountName          = "mcp_request_count"
 	requestErrorsName         = "mcp_request_errors"
 	requestErrorsDurationName = "mcp_request_errors_duration"
+	requestTotalDurationName  = "mcp_request_total_duration"
+	handlerDurationName       = "mcp_handler_duration"
+	cacheHitsName             = "mcp_cache_hits"
+	binaryUploadBytesName     = "mcp_binary_upload_bytes"
+	circuitStateName          = "mcp_circuit_state"
+	circuitRejectedName       = "mcp_circuit_rejected"
+	poolWaitDurationName      = "mcp_pool_wait_duration"
+	poolActiveName            = "mcp_pool_active"
+	poolIdleName              = "mcp_pool_idle"
+	poolSizeName              = "mcp_pool_size"
+	requestRawBytesName       = "mcp_request_raw_bytes"
+	requestCompressedName     = "mcp_request_compressed_bytes"
+	dnsDurationName           = "mcp_dns_duration"
+	tcpConnectDurationName    = "mcp_tcp_connect_duration"
+	tlsDurationName           = "mcp_tls_duration"
+	ttfbName                  = "mcp_ttfb"
+	paginationPagesName       = "mcp_pagination_pages"
+	sequenceDurationName      = "mcp_sequence_duration"
+	keepAlivePingsName        = "mcp_keepalive_pings"
+	rateLimitedName           = "mcp_rate_limited"
+	progressUpdatesName       = "mcp_progress_updates"
+	notificationWaitName      = "mcp_notification_wait"
+	tlsResumedName            = "mcp_tls_resumed"
+	sseGapsName               = "mcp_sse_gaps"
+	reinitializeName          = "mcp_reinitialize_duration"
+	listResultSizeName        = "mcp_list_result_size"
+	serverProcessingName      = "mcp_server_processing_duration"
+	connectJitterName         = "mcp_connect_jitter"
+	deduplicatedName          = "mcp_deduplicated"
+	groupConnectionsName      = "mcp_group_connections"
+	integrityMismatchesName   = "mcp_integrity_mismatches"
+	coldStartName             = "mcp_cold_start_duration"
+	totalBytesName            = "mcp_total_bytes"
+	watchLatencyName          = "mcp_watch_latency"
+	notificationsDroppedName  = "mcp_notifications_dropped"
+	rootsListServedName       = "mcp_roots_list_served"
+	rootsListDurationName     = "mcp_roots_list_duration"
+	latencyGuardP95Name       = "mcp_latency_guard_p95"
 )
 
-func NewK6Metrics(registry *k6metrics.Registry, samples chan<- k6metrics.SampleContainer, tagsAndMeta k6metrics.TagsAndMeta) *K6Metrics {
+func NewK6Metrics(
+	registry *k6metrics.Registry,
+	samples chan<- k6metrics.SampleContainer,
+	tagsAndMeta k6metrics.TagsAndMeta,
+	dropped *DroppedSamples,
+	logger logrus.FieldLogger,
+	allowedTags []string,
+	clock Clock,
+) *K6Metrics {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	var allowed map[string]struct{}
+	if allowedTags != nil {
+		allowed = make(map[string]struct{}, len(allowedTags))
+		for _, name := range allowedTags {
+			allowed[name] = struct{}{}
+		}
+	}
+
 	return &K6Metrics{
+		allowedTags:           allowed,
+		clock:                 clock,
 		samples:               samples,
 		tagsAndMeta:           tagsAndMeta,
+		dropped:               dropped,
+		logger:                logger,
 		requestDuration:       registry.MustNewMetric(requestDurationName, k6metrics.Trend, k6metrics.Time),
 		requestCount:          registry.MustNewMetric(requestCountName, k6metrics.Counter),
 		requestErrors:         registry.MustNewMetric(requestErrorsName, k6metrics.Counter),
 		requestErrorsDuration: registry.MustNewMetric(requestErrorsDurationName, k6metrics.Trend, k6metrics.Time),
+		requestTotalDuration:  registry.MustNewMetric(requestTotalDurationName, k6metrics.Trend, k6metrics.Time),
+		handlerDuration:       registry.MustNewMetric(handlerDurationName, k6metrics.Trend, k6metrics.Time),
+		cacheHits:             registry.MustNewMetric(cacheHitsName, k6metrics.Counter),
+		binaryUploadBytes:     registry.MustNewMetric(binaryUploadBytesName, k6metrics.Counter, k6metrics.Data),
+		circuitState:          registry.MustNewMetric(circuitStateName, k6metrics.Gauge),
+		circuitRejected:       registry.MustNewMetric(circuitRejectedName, k6metrics.Counter),
+		poolWaitDuration:      registry.MustNewMetric(poolWaitDurationName, k6metrics.Trend, k6metrics.Time),
+		poolActive:            registry.MustNewMetric(poolActiveName, k6metrics.Gauge),
+		poolIdle:              registry.MustNewMetric(poolIdleName, k6metrics.Gauge),
+		poolSize:              registry.MustNewMetric(poolSizeName, k6metrics.Gauge),
+		requestRawBytes:       registry.MustNewMetric(requestRawBytesName, k6metrics.Counter, k6metrics.Data),
+		requestCompressed:     registry.MustNewMetric(requestCompressedName, k6metrics.Counter, k6metrics.Data),
+		dnsDuration:           registry.MustNewMetric(dnsDurationName, k6metrics.Trend, k6metrics.Time),
+		tcpConnectDuration:    registry.MustNewMetric(tcpConnectDurationName, k6metrics.Trend, k6metrics.Time),
+		tlsDuration:           registry.MustNewMetric(tlsDurationName, k6metrics.Trend, k6metrics.Time),
+		ttfb:                  registry.MustNewMetric(ttfbName, k6metrics.Trend, k6metrics.Time),
+		paginationPages:       registry.MustNewMetric(paginationPagesName, k6metrics.Trend),
+		sequenceDuration:      registry.MustNewMetric(sequenceDurationName, k6metrics.Trend, k6metrics.Time),
+		keepAlivePings:        registry.MustNewMetric(keepAlivePingsName, k6metrics.Counter),
+		rateLimited:           registry.MustNewMetric(rateLimitedName, k6metrics.Counter),
+		progressUpdates:       registry.MustNewMetric(progressUpdatesName, k6metrics.Trend),
+		notificationWait:      registry.MustNewMetric(notificationWaitName, k6metrics.Trend, k6metrics.Time),
+		tlsResumed:            registry.MustNewMetric(tlsResumedName, k6metrics.Counter),
+		sseGaps:               registry.MustNewMetric(sseGapsName, k6metrics.Counter),
+		reinitialize:          registry.MustNewMetric(reinitializeName, k6metrics.Trend, k6metrics.Time),
+		listResultSize:        registry.MustNewMetric(listResultSizeName, k6metrics.Trend),
+		serverProcessing:      registry.MustNewMetric(serverProcessingName, k6metrics.Trend, k6metrics.Time),
+		connectJitter:         registry.MustNewMetric(connectJitterName, k6metrics.Trend, k6metrics.Time),
+		deduplicated:          registry.MustNewMetric(deduplicatedName, k6metrics.Counter),
+		groupConnections:      registry.MustNewMetric(groupConnectionsName, k6metrics.Gauge),
+		integrityMismatches:   registry.MustNewMetric(integrityMismatchesName, k6metrics.Counter),
+		coldStart:             registry.MustNewMetric(coldStartName, k6metrics.Trend, k6metrics.Time),
+		totalBytes:            registry.MustNewMetric(totalBytesName, k6metrics.Counter, k6metrics.Data),
+		watchLatency:          registry.MustNewMetric(watchLatencyName, k6metrics.Trend, k6metrics.Time),
+		notificationsDropped:  registry.MustNewMetric(notificationsDroppedName, k6metrics.Counter),
+		rootsListServed:       registry.MustNewMetric(rootsListServedName, k6metrics.Counter),
+		rootsListDuration:     registry.MustNewMetric(rootsListDurationName, k6metrics.Trend, k6metrics.Time),
+		latencyGuardP95:       registry.MustNewMetric(latencyGuardP95Name, k6metrics.Gauge, k6metrics.Time),
+		checks:                registry.MustNewMetric(k6metrics.ChecksName, k6metrics.Rate),
+	}
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Now returns the time of the clock of k, which durations pushed to k should
+// be measured with.
+func (k *K6Metrics) Now() time.Time {
+	return k.clock.Now()
+}
+
+// Since returns the time elapsed since t on the clock of k.
+func (k *K6Metrics) Since(t time.Time) time.Duration {
+	return k.Now().Sub(t)
+}
+
+// push sends samples without the tags that are not allowed.
+func (k *K6Metrics) push(ctx context.Context, samples k6metrics.SampleContainer) {
+	k.send(ctx, k.filterTags(samples), samples)
+}
+
+// send sends samples, which are pushed as filtered, unless ctx is done, in
+// which case pushed are counted as dropped. The first drop is logged, since
+// it means the metrics of the test are incomplete.
+func (k *K6Metrics) send(ctx context.Context, samples, pushed k6metrics.SampleContainer) {
+	if k6metrics.PushIfNotDone(ctx, k.samples, samples) {
+		return
+	}
+
+	// Drops are counted by the method tag of the samples as pushed, which
+	// filtering may have left out.
+	k.dropped.add(pushed.GetSamples())
+	if k.dropped.warned.CompareAndSwap(false, true) {
+		k.logger.Warn("MCP metric samples were dropped because the VU was done; metrics may be incomplete")
 	}
 }
 
+// filterTags returns samples without the tags that are neither allowed nor
+// tags of the VU, leaving samples as is.
+func (k *K6Metrics) filterTags(samples k6metrics.SampleContainer) k6metrics.SampleContainer {
+	if k.allowedTags == nil {
+		return samples
+	}
+
+	filtered := slices.Clone(samples.GetSamples())
+	for i, sample := range filtered {
+		tags := k.tagsAndMeta.Tags
+		for name, value := range sample.Tags.Map() {
+			if _, ok := k.allowedTags[name]; ok {
+				tags = tags.With(name, value)
+			}
+		}
+		filtered[i].Tags = tags
+	}
+	return k6metrics.Samples(filtered)
+}
+
+func (d *DroppedSamples) add(samples []k6metrics.Sample) {
+	d.count.Add(int64(len(samples)))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.byMethod == nil {
+		d.byMethod = make(map[string]int64)
+	}
+	for _, sample := range samples {
+		method, ok := sample.Tags.Get("method")
+		if !ok {
+			method = sample.Metric.Name
+		}
+		d.byMethod[method]++
+	}
+}
+
+// Count returns the number of samples dropped so far.
+func (d *DroppedSamples) Count() int64 {
+	return d.count.Load()
+}
+
+// ByMethod returns the number of samples dropped so far by the method they
+// were tagged with, or by metric name for samples of no method.
+func (d *DroppedSamples) ByMethod() map[string]int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	byMethod := make(map[string]int64, len(d.byMethod))
+	maps.Copy(byMethod, d.byMethod)
+	return byMethod
+}
+
 func (k *K6Metrics) Push(ctx context.Context, method string, duration time.Duration, err error) {
+	k.PushWithTags(ctx, method, duration, err, nil)
+}
+
+// PushWithTags works like Push, adding extra tags to every sample.
+func (k *K6Metrics) PushWithTags(ctx context.Context, method string, duration time.Duration, err error, extra map[string]string) {
+	k.PushWithMetadata(ctx, method, duration, err, extra, nil)
+}
+
+// PushWithMetadata works like PushWithTags, also attaching metadata, such as
+// a trace ID, to the request duration sample.
+func (k *K6Metrics) PushWithMetadata(
+	ctx context.Context,
+	method string,
+	duration time.Duration,
+	err error,
+	extra map[string]string,
+	metadata map[string]string,
+) {
 	tags := k.tagsAndMeta.Tags.With(
 		"method", method,
 	)
-	k6metrics.PushIfNotDone(ctx, k.samples, k6metrics.Sample{
+	for key, value := range extra {
+		tags = tags.With(key, value)
+	}
+
+	var sampleMetadata map[string]string
+	if len(metadata) > 0 {
+		sampleMetadata = make(map[string]string, len(k.tagsAndMeta.Metadata)+len(metadata))
+		maps.Copy(sampleMetadata, k.tagsAndMeta.Metadata)
+		maps.Copy(sampleMetadata, metadata)
+	}
+
+	k.push(ctx, k6metrics.Sample{
 		TimeSeries: k6metrics.TimeSeries{
 			Metric: k.requestDuration,
 			Tags:   tags,
 		},
-		Time:  time.Now(),
-		Value: float64(duration) / float64(time.Millisecond),
+		Time:     k.Now(),
+		Value:    float64(duration) / float64(time.Millisecond),
+		Metadata: sampleMetadata,
 	})
 
-	k6metrics.PushIfNotDone(ctx, k.samples, k6metrics.Sample{
+	k.push(ctx, k6metrics.Sample{
 		TimeSeries: k6metrics.TimeSeries{
 			Metric: k.requestCount,
 			Tags:   tags,
 		},
-		Time:  time.Now(),
+		Time:  k.Now(),
 		Value: 1,
 	})
 
 	if err != nil {
-		k6metrics.PushIfNotDone(ctx, k.samples, k6metrics.Sample{
+		k.push(ctx, k6metrics.Sample{
 			TimeSeries: k6metrics.TimeSeries{
 				Metric: k.requestErrors,
 				Tags:   tags,
 			},
-			Time:  time.Now(),
+			Time:  k.Now(),
 			Value: 1,
 		})
 
-		k6metrics.PushIfNotDone(ctx, k.samples, k6metrics.Sample{
+		k.push(ctx, k6metrics.Sample{
 			TimeSeries: k6metrics.TimeSeries{
 				Metric: k.requestErrorsDuration,
 				Tags:   tags,
 			},
-			Time:  time.Now(),
+			Time:  k.Now(),
 			Value: float64(duration) / float64(time.Millisecond),
 		})
 	}
 }
+
+// PushRequestTotalDuration records the duration of a call of method including
+// its retries and the delays before them, tagged like its request duration.
+func (k *K6Metrics) PushRequestTotalDuration(ctx context.Context, method string, duration time.Duration, extra map[string]string) {
+	tags := k.tagsAndMeta.Tags.With(
+		"method", method,
+	)
+	for key, value := range extra {
+		tags = tags.With(key, value)
+	}
+
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.requestTotalDuration,
+			Tags:   tags,
+		},
+		Time:  k.Now(),
+		Value: float64(duration) / float64(time.Millisecond),
+	})
+}
+
+// PushHandler records the time spent in a user-registered handler answering a
+// server-initiated request.
+func (k *K6Metrics) PushHandler(ctx context.Context, handler string, duration time.Duration) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.handlerDuration,
+			Tags: k.tagsAndMeta.Tags.With(
+				"handler", handler,
+			),
+		},
+		Time:  k.Now(),
+		Value: float64(duration) / float64(time.Millisecond),
+	})
+}
+
+// PushCacheHit records a call served from the client-side cache.
+func (k *K6Metrics) PushCacheHit(ctx context.Context, method string) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.cacheHits,
+			Tags: k.tagsAndMeta.Tags.With(
+				"method", method,
+			),
+		},
+		Time:  k.Now(),
+		Value: 1,
+	})
+}
+
+// PushBinaryUpload records the raw size of binary arguments sent with a call,
+// before base64 encoding.
+func (k *K6Metrics) PushBinaryUpload(ctx context.Context, method string, size int) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.binaryUploadBytes,
+			Tags: k.tagsAndMeta.Tags.With(
+				"method", method,
+			),
+		},
+		Time:  k.Now(),
+		Value: float64(size),
+	})
+}
+
+// PushCircuitState records a circuit breaker state change: 0 for closed, 1
+// for half-open and 2 for open. The state of breakers shared by an endpoint
+// is tagged with it.
+func (k *K6Metrics) PushCircuitState(ctx context.Context, state float64, endpoint string) {
+	tags := k.tagsAndMeta.Tags
+	if endpoint != "" {
+		tags = tags.With("endpoint", endpoint)
+	}
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.circuitState,
+			Tags:   tags,
+		},
+		Time:  k.Now(),
+		Value: state,
+	})
+}
+
+// PushCircuitRejected records a call of method that an open circuit breaker
+// failed without sending it, adding extra tags. Such calls are kept out of the
+// request metrics, whose durations they would drag down.
+func (k *K6Metrics) PushCircuitRejected(ctx context.Context, method string, extra map[string]string) {
+	tags := k.tagsAndMeta.Tags.With(
+		"method", method,
+	)
+	for key, value := range extra {
+		tags = tags.With(key, value)
+	}
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.circuitRejected,
+			Tags:   tags,
+		},
+		Time:  k.Now(),
+		Value: 1,
+	})
+}
+
+// PushPoolWait records how long acquiring a session from a pool took.
+func (k *K6Metrics) PushPoolWait(ctx context.Context, pool string, duration time.Duration) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.poolWaitDuration,
+			Tags: k.tagsAndMeta.Tags.With(
+				"pool", pool,
+			),
+		},
+		Time:  k.Now(),
+		Value: float64(duration) / float64(time.Millisecond),
+	})
+}
+
+// PushPoolSize records the number of checked out and idle sessions of a pool,
+// and their total.
+func (k *K6Metrics) PushPoolSize(ctx context.Context, pool string, active, idle int) {
+	tags := k.tagsAndMeta.Tags.With(
+		"pool", pool,
+	)
+	now := k.Now()
+
+	k.push(ctx, k6metrics.Samples{
+		{
+			TimeSeries: k6metrics.TimeSeries{Metric: k.poolActive, Tags: tags},
+			Time:       now,
+			Value:      float64(active),
+		},
+		{
+			TimeSeries: k6metrics.TimeSeries{Metric: k.poolIdle, Tags: tags},
+			Time:       now,
+			Value:      float64(idle),
+		},
+		{
+			TimeSeries: k6metrics.TimeSeries{Metric: k.poolSize, Tags: tags},
+			Time:       now,
+			Value:      float64(active + idle),
+		},
+	})
+}
+
+// PushCompression records the size of a request body before and after
+// compression.
+func (k *K6Metrics) PushCompression(ctx context.Context, raw, compressed int) {
+	now := k.Now()
+
+	k.push(ctx, k6metrics.Samples{
+		{
+			TimeSeries: k6metrics.TimeSeries{Metric: k.requestRawBytes, Tags: k.tagsAndMeta.Tags},
+			Time:       now,
+			Value:      float64(raw),
+		},
+		{
+			TimeSeries: k6metrics.TimeSeries{Metric: k.requestCompressed, Tags: k.tagsAndMeta.Tags},
+			Time:       now,
+			Value:      float64(compressed),
+		},
+	})
+}
+
+// PushTimings records the phases of an HTTP request sent for method. Phases
+// that did not happen are skipped.
+func (k *K6Metrics) PushTimings(ctx context.Context, method string, timings HTTPTimings) {
+	tags := k.tagsAndMeta.Tags.With(
+		"method", method,
+	)
+	now := k.Now()
+
+	var samples k6metrics.Samples
+	for _, phase := range []struct {
+		metric   *k6metrics.Metric
+		duration time.Duration
+	}{
+		{k.dnsDuration, timings.DNS},
+		{k.tcpConnectDuration, timings.TCPConnect},
+		{k.tlsDuration, timings.TLS},
+		{k.ttfb, timings.TTFB},
+	} {
+		if phase.duration == 0 {
+			continue
+		}
+		samples = append(samples, k6metrics.Sample{
+			TimeSeries: k6metrics.TimeSeries{Metric: phase.metric, Tags: tags},
+			Time:       now,
+			Value:      float64(phase.duration) / float64(time.Millisecond),
+		})
+	}
+
+	if len(samples) > 0 {
+		k.push(ctx, samples)
+	}
+}
+
+// PushPaginationPages records the number of pages a full listing with method
+// took.
+func (k *K6Metrics) PushPaginationPages(ctx context.Context, method string, pages int) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.paginationPages,
+			Tags: k.tagsAndMeta.Tags.With(
+				"method", method,
+			),
+		},
+		Time:  k.Now(),
+		Value: float64(pages),
+	})
+}
+
+// PushSequence records the total time a user-defined sequence of calls took.
+func (k *K6Metrics) PushSequence(ctx context.Context, sequence string, duration time.Duration) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.sequenceDuration,
+			Tags: k.tagsAndMeta.Tags.With(
+				"sequence", sequence,
+			),
+		},
+		Time:  k.Now(),
+		Value: float64(duration) / float64(time.Millisecond),
+	})
+}
+
+// PushKeepAlivePing records a keep-alive ping, tagged with whether it failed.
+func (k *K6Metrics) PushKeepAlivePing(ctx context.Context, err error) {
+	status := "ok"
+	if err != nil {
+		status = "failed"
+	}
+
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.keepAlivePings,
+			Tags: k.tagsAndMeta.Tags.With(
+				"status", status,
+			),
+		},
+		Time:  k.Now(),
+		Value: 1,
+	})
+}
+
+// PushRateLimited records a request of method that the server rejected with
+// HTTP 429 Too Many Requests.
+func (k *K6Metrics) PushRateLimited(ctx context.Context, method string) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.rateLimited,
+			Tags: k.tagsAndMeta.Tags.With(
+				"method", method,
+			),
+		},
+		Time:  k.Now(),
+		Value: 1,
+	})
+}
+
+// PushProgressUpdates records how many progress notifications the server sent
+// during a call of method.
+func (k *K6Metrics) PushProgressUpdates(ctx context.Context, method string, updates int) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.progressUpdates,
+			Tags: k.tagsAndMeta.Tags.With(
+				"method", method,
+			),
+		},
+		Time:  k.Now(),
+		Value: float64(updates),
+	})
+}
+
+// PushNotificationWait records how long a script waited for a notification of
+// method.
+func (k *K6Metrics) PushNotificationWait(ctx context.Context, method string, duration time.Duration) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.notificationWait,
+			Tags: k.tagsAndMeta.Tags.With(
+				"method", method,
+			),
+		},
+		Time:  k.Now(),
+		Value: float64(duration) / float64(time.Millisecond),
+	})
+}
+
+// PushTLSResumed records a TLS handshake, tagged with whether it resumed an
+// earlier session.
+func (k *K6Metrics) PushTLSResumed(ctx context.Context, resumed bool) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.tlsResumed,
+			Tags: k.tagsAndMeta.Tags.With(
+				"resumed", strconv.FormatBool(resumed),
+			),
+		},
+		Time:  k.Now(),
+		Value: 1,
+	})
+}
+
+// PushSSEGap records an SSE event stream that dropped, tagged with whether it
+// was resumed from its last event.
+func (k *K6Metrics) PushSSEGap(ctx context.Context, resumed bool) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.sseGaps,
+			Tags: k.tagsAndMeta.Tags.With(
+				"resumed", strconv.FormatBool(resumed),
+			),
+		},
+		Time:  k.Now(),
+		Value: 1,
+	})
+}
+
+// PushReinitialize records how long a session took to be reinitialized.
+func (k *K6Metrics) PushReinitialize(ctx context.Context, duration time.Duration) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.reinitialize,
+			Tags:   k.tagsAndMeta.Tags,
+		},
+		Time:  k.Now(),
+		Value: k6metrics.D(duration),
+	})
+}
+
+// PushListResultSize records the number of items in a page returned by a list
+// call of method.
+func (k *K6Metrics) PushListResultSize(ctx context.Context, method string, size int) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.listResultSize,
+			Tags: k.tagsAndMeta.Tags.With(
+				"method", method,
+			),
+		},
+		Time:  k.Now(),
+		Value: float64(size),
+	})
+}
+
+// PushServerProcessing records the processing duration a server reported for
+// a call of method, to be compared with its request duration.
+func (k *K6Metrics) PushServerProcessing(ctx context.Context, method string, duration time.Duration) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.serverProcessing,
+			Tags: k.tagsAndMeta.Tags.With(
+				"method", method,
+			),
+		},
+		Time:  k.Now(),
+		Value: k6metrics.D(duration),
+	})
+}
+
+// PushConnectJitter records the random delay a client waited for before
+// connecting.
+func (k *K6Metrics) PushConnectJitter(ctx context.Context, delay time.Duration) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.connectJitter,
+			Tags:   k.tagsAndMeta.Tags,
+		},
+		Time:  k.Now(),
+		Value: k6metrics.D(delay),
+	})
+}
+
+// PushDeduplicated records a call of method that waited for the result of an
+// identical call in flight instead of sending its own request.
+func (k *K6Metrics) PushDeduplicated(ctx context.Context, method string) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.deduplicated,
+			Tags: k.tagsAndMeta.Tags.With(
+				"method", method,
+			),
+		},
+		Time:  k.Now(),
+		Value: 1,
+	})
+}
+
+// PushGroupConnections records the number of connections a connection group
+// holds open.
+func (k *K6Metrics) PushGroupConnections(ctx context.Context, group string, open int64) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.groupConnections,
+			Tags: k.tagsAndMeta.Tags.With(
+				"group", group,
+			),
+		},
+		Time:  k.Now(),
+		Value: float64(open),
+	})
+}
+
+// PushIntegrityMismatch records a call of method whose content did not match
+// the hash the server declared for it.
+func (k *K6Metrics) PushIntegrityMismatch(ctx context.Context, method string) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.integrityMismatches,
+			Tags: k.tagsAndMeta.Tags.With(
+				"method", method,
+			),
+		},
+		Time:  k.Now(),
+		Value: 1,
+	})
+}
+
+// PushColdStart records the duration of a phase of a cold start probe.
+func (k *K6Metrics) PushColdStart(ctx context.Context, phase string, duration time.Duration) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.coldStart,
+			Tags: k.tagsAndMeta.Tags.With(
+				"phase", phase,
+			),
+		},
+		Time:  k.Now(),
+		Value: k6metrics.D(duration),
+	})
+}
+
+// PushTotalBytes records bytes transferred over HTTP in direction, in for
+// responses or out for requests.
+func (k *K6Metrics) PushTotalBytes(ctx context.Context, direction string, n int64) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.totalBytes,
+			Tags: k.tagsAndMeta.Tags.With(
+				"direction", direction,
+			),
+		},
+		Time:  k.Now(),
+		Value: float64(n),
+	})
+}
+
+// PushWatchLatency records the time between a change of a watched resource
+// being noticed and its new version being handed to the script, in mode,
+// subscribe or poll.
+func (k *K6Metrics) PushWatchLatency(ctx context.Context, mode string, duration time.Duration) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.watchLatency,
+			Tags: k.tagsAndMeta.Tags.With(
+				"mode", mode,
+			),
+		},
+		Time:  k.Now(),
+		Value: k6metrics.D(duration),
+	})
+}
+
+// PushRootsListServed records a roots/list request of the server answered by
+// the client, and the time spent answering it.
+func (k *K6Metrics) PushRootsListServed(ctx context.Context, duration time.Duration) {
+	now := k.Now()
+
+	k.push(ctx, k6metrics.Samples{
+		{
+			TimeSeries: k6metrics.TimeSeries{Metric: k.rootsListServed, Tags: k.tagsAndMeta.Tags},
+			Time:       now,
+			Value:      1,
+		},
+		{
+			TimeSeries: k6metrics.TimeSeries{Metric: k.rootsListDuration, Tags: k.tagsAndMeta.Tags},
+			Time:       now,
+			Value:      k6metrics.D(duration),
+		},
+	})
+}
+
+// PushLatencyGuard records the p95 of the calls of tool in the window of its
+// latency guard.
+func (k *K6Metrics) PushLatencyGuard(ctx context.Context, tool string, p95 time.Duration) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.latencyGuardP95,
+			Tags: k.tagsAndMeta.Tags.With(
+				"tool", tool,
+			),
+		},
+		Time:  k.Now(),
+		Value: k6metrics.D(p95),
+	})
+}
+
+// PushNotificationDropped records a notification of method dropped from a
+// full notification buffer before being drained.
+func (k *K6Metrics) PushNotificationDropped(ctx context.Context, method string) {
+	k.push(ctx, k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.notificationsDropped,
+			Tags: k.tagsAndMeta.Tags.With(
+				"method", method,
+			),
+		},
+		Time:  k.Now(),
+		Value: 1,
+	})
+}
+
+// PushCheck records a k6 check named name, as the built-in check() does. The
+// check tag is the only one added to the tags of the VU, so checks are never
+// filtered.
+func (k *K6Metrics) PushCheck(ctx context.Context, name string, passed bool) {
+	sample := k6metrics.Sample{
+		TimeSeries: k6metrics.TimeSeries{
+			Metric: k.checks,
+			Tags: k.tagsAndMeta.Tags.With(
+				"check", name,
+			),
+		},
+		Time:     k.Now(),
+		Metadata: k.tagsAndMeta.Metadata,
+	}
+	if passed {
+		sample.Value = 1
+	}
+	k.send(ctx, sample, sample)
+}
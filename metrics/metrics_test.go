@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/jsonschema-go/jsonschema"
 	mcp "github.com/grafana/xk6-mcp"
+	"github.com/grafana/xk6-mcp/metrics"
 	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.k6.io/k6/js/modulestest"
@@ -40,6 +44,12 @@ const (
 func setupTest(t *testing.T) *testCase {
 	t.Helper()
 
+	return setupTestWith(t, mcp.New())
+}
+
+func setupTestWith(t *testing.T, root *mcp.RootModule) *testCase {
+	t.Helper()
+
 	registry := k6metrics.NewRegistry()
 	samples := make(chan k6metrics.SampleContainer, 1000)
 	state := &k6lib.State{
@@ -53,7 +63,7 @@ func setupTest(t *testing.T) *testCase {
 	rt := modulestest.NewRuntime(t)
 	vu := rt.VU
 
-	mod, ok := mcp.New().NewModuleInstance(vu).(*mcp.MCPInstance)
+	mod, ok := root.NewModuleInstance(vu).(*mcp.MCPInstance)
 	require.True(t, ok)
 	require.NoError(t, vu.RuntimeField.Set("mcp", mod.Exports().Named))
 
@@ -110,7 +120,13 @@ func TestK6Metrics(t *testing.T) {
 	sampleContainers := k6metrics.GetBufferedSamples(tc.samples)
 	assert.Greater(t, len(sampleContainers), 0)
 	for _, sampleContainer := range sampleContainers {
-		sampleCount += len(sampleContainer.GetSamples())
+		for _, sample := range sampleContainer.GetSamples() {
+			// Traffic is recorded as the HTTP bodies are read, which is
+			// not what these tests are about.
+			if sample.Metric.Name != "mcp_total_bytes" {
+				sampleCount++
+			}
+		}
 	}
 	assert.Equal(t, sampleCount, 2)
 }
@@ -135,7 +151,395 @@ func TestK6ErrorMetrics(t *testing.T) {
 	sampleContainers := k6metrics.GetBufferedSamples(tc.samples)
 	assert.Greater(t, len(sampleContainers), 0)
 	for _, sampleContainer := range sampleContainers {
-		sampleCount += len(sampleContainer.GetSamples())
+		for _, sample := range sampleContainer.GetSamples() {
+			// Traffic is recorded as the HTTP bodies are read, which is
+			// not what these tests are about.
+			if sample.Metric.Name != "mcp_total_bytes" {
+				sampleCount++
+			}
+		}
 	}
 	assert.Equal(t, sampleCount, 4)
 }
+
+func TestK6HandlerMetrics(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName}, func(ctx context.Context, req *mcpsdk.CallToolRequest, _ MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		res, err := req.Session.CreateMessage(ctx, &mcpsdk.CreateMessageParams{
+			Messages: []*mcpsdk.SamplingMessage{{Role: "user", Content: &mcpsdk.TextContent{Text: "hello"}}},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{res.Content}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, nil)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	// The standalone event stream stays open for as long as the client lives.
+	defer ts.CloseClientConnections()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      on_create_message: (params) => ({
+        role: "assistant",
+        model: "test",
+        content: { type: "text", text: "sampled" },
+      }),
+    });
+    client.callTool({name: "%s", arguments: {id: 1}}).content[0].text;`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "sampled", v.String())
+
+	var handlerSamples int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name == "mcp_handler_duration" {
+				handler, _ := sample.Tags.Get("handler")
+				assert.Equal(t, "create_message", handler)
+				handlerSamples++
+			}
+		}
+	}
+	assert.Equal(t, 1, handlerSamples)
+}
+
+func TestK6ColdWarmProbeMetrics(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(handler.ServeHTTP))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s"
+    });
+    const probe = client.coldWarmProbe({name: "%s", arguments: {id: 1}});
+    if (!(probe.cold_ms > 0 && probe.warm_ms > 0)) {
+      throw new Error("missing probe durations");
+    }`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	cacheStates := map[string]int{}
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name == "mcp_request_duration" {
+				state, _ := sample.Tags.Get("cache_state")
+				cacheStates[state]++
+			}
+		}
+	}
+	assert.Equal(t, map[string]int{"cold": 1, "warm": 1}, cacheStates)
+}
+
+func TestK6TimingMetrics(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(handler.ServeHTTP))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      detailed_timings: true
+    });
+    client.callTool({name: "%s", arguments: {id: 1}});`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	methods := map[string]map[string]bool{}
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			method, _ := sample.Tags.Get("method")
+			if methods[sample.Metric.Name] == nil {
+				methods[sample.Metric.Name] = map[string]bool{}
+			}
+			methods[sample.Metric.Name][method] = true
+		}
+	}
+	assert.True(t, methods["mcp_ttfb"]["tools/call"])
+	assert.NotEmpty(t, methods["mcp_tcp_connect_duration"])
+	assert.Empty(t, methods["mcp_tls_duration"])
+}
+
+func TestK6DroppedSamples(t *testing.T) {
+	registry := k6metrics.NewRegistry()
+	samples := make(chan k6metrics.SampleContainer, 10)
+	logger, hook := logtest.NewNullLogger()
+
+	var dropped metrics.DroppedSamples
+	k := metrics.NewK6Metrics(registry, samples, k6metrics.TagsAndMeta{Tags: registry.RootTagSet()}, &dropped, logger, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	k.Push(ctx, "tools/list", time.Millisecond, nil)
+	assert.Zero(t, dropped.Count())
+
+	cancel()
+	k.Push(ctx, "tools/list", time.Millisecond, nil)
+	k.Push(ctx, "tools/list", time.Millisecond, nil)
+	k.PushSSEGap(ctx, true)
+	assert.Equal(t, int64(5), dropped.Count())
+	assert.Equal(t, map[string]int64{"tools/list": 4, "mcp_sse_gaps": 1}, dropped.ByMethod())
+	assert.Len(t, hook.AllEntries(), 1)
+}
+
+func TestK6PaginationMetrics(t *testing.T) {
+	inputSchema, err := jsonschema.For[MyToolInput](nil)
+	require.NoError(t, err)
+	toolHandler := func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		return nil, MyToolOutput{toolName}, nil
+	}
+
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, &mcpsdk.ServerOptions{PageSize: 2})
+	for i := range 5 {
+		mcpsdk.AddTool(server, &mcpsdk.Tool{Name: fmt.Sprintf("tool%d", i), InputSchema: inputSchema}, toolHandler)
+	}
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const stats = client.toolListStats();
+    if (stats.total_tools !== 5 || stats.page_count !== 3 || stats.per_page_duration_ms.length !== 3) {
+      throw new Error("unexpected stats: " + JSON.stringify(stats));
+    }`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	var pages []float64
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name == "mcp_pagination_pages" {
+				pages = append(pages, sample.Value)
+			}
+		}
+	}
+	assert.Equal(t, []float64{3}, pages)
+}
+
+func TestK6SequenceMetrics(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(handler.ServeHTTP))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s"
+    });
+    const result = client.measureSequence("agent_loop", () => {
+      client.listTools();
+      return client.callTool({name: "%s", arguments: {id: 1}});
+    });
+    if (result.is_error) {
+      throw new Error("unexpected tool error");
+    }`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	var sequences, requests int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			switch sample.Metric.Name {
+			case "mcp_sequence_duration":
+				sequence, _ := sample.Tags.Get("sequence")
+				assert.Equal(t, "agent_loop", sequence)
+				sequences++
+			case "mcp_request_count":
+				requests++
+			}
+		}
+	}
+	assert.Equal(t, 1, sequences)
+	assert.Equal(t, 2, requests)
+}
+
+func TestK6ListResultSizeMetrics(t *testing.T) {
+	inputSchema, err := jsonschema.For[MyToolInput](nil)
+	require.NoError(t, err)
+	toolHandler := func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		return nil, MyToolOutput{toolName}, nil
+	}
+
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, &mcpsdk.ServerOptions{PageSize: 2})
+	for i := range 3 {
+		mcpsdk.AddTool(server, &mcpsdk.Tool{Name: fmt.Sprintf("tool%d", i), InputSchema: inputSchema}, toolHandler)
+	}
+	server.AddResource(&mcpsdk.Resource{Name: "readme", URI: "file:///readme.md"}, nil)
+	server.AddPrompt(&mcpsdk.Prompt{Name: "greet"}, nil)
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.listAllTools();
+    client.listResources();
+    client.listPrompts();`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	sizes := map[string][]float64{}
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name == "mcp_list_result_size" {
+				method, _ := sample.Tags.Get("method")
+				sizes[method] = append(sizes[method], sample.Value)
+			}
+		}
+	}
+	assert.Equal(t, map[string][]float64{
+		"tools/list":     {2, 1},
+		"resources/list": {1},
+		"prompts/list":   {1},
+	}, sizes)
+}
+
+// fakeClock only moves when advanced.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestK6MetricsClock(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+
+	inputSchema, err := jsonschema.For[MyToolInput](nil)
+	require.NoError(t, err)
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName, InputSchema: inputSchema}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		clock.advance(250 * time.Millisecond)
+		return nil, MyToolOutput{toolName}, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTestWith(t, mcp.NewWithClock(clock))
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.measureSequence("two calls", () => {
+      client.callTool({name: "%[2]s", arguments: {id: 1}});
+      client.callTool({name: "%[2]s", arguments: {id: 2}});
+    });`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	var durations, sequences []float64
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			switch sample.Metric.Name {
+			case "mcp_request_duration":
+				durations = append(durations, sample.Value)
+			case "mcp_sequence_duration":
+				sequences = append(sequences, sample.Value)
+			}
+			assert.WithinRange(t, sample.Time, start, clock.Now(), sample.Metric.Name)
+		}
+	}
+	assert.Equal(t, []float64{250, 250}, durations)
+	assert.Equal(t, []float64{500}, sequences)
+}
+
+func TestCircuitBreakerClock(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "tick"}, func(context.Context, *mcpsdk.CallToolRequest, any) (*mcpsdk.CallToolResult, any, error) {
+		clock.advance(time.Minute)
+		return &mcpsdk.CallToolResult{}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTestWith(t, mcp.NewWithClock(clock))
+
+	// The open duration only elapses on the clock of the metrics, which the
+	// other client moves.
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%[1]s",
+      stateless: true,
+      circuit_breaker: {failure_threshold: 1, open_duration: "1m"}
+    });
+    const ticker = mcp.StreamableHTTPClient({base_url: "%[1]s/ticker", stateless: true});
+    const outcome = (name) => {
+      try {
+        client.callTool({name: name});
+        return "ok";
+      } catch (e) {
+        return String(e);
+      }
+    };
+    const results = [outcome("missing"), outcome("tick")];
+    ticker.callTool({name: "tick"});
+    results.push(outcome("tick"));
+    results;`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	results := v.Export().([]any)
+	require.Len(t, results, 3)
+	assert.NotEqual(t, "ok", results[0])
+	assert.Contains(t, results[1], "circuit breaker is open")
+	assert.Equal(t, "ok", results[2])
+}
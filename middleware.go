@@ -0,0 +1,148 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	faultInjectionMiddleware   = "fault_injection"
+	latencyInjectionMiddleware = "latency_injection"
+	requestLoggingMiddleware   = "request_logging"
+)
+
+var errInjectedFault = errors.New("injected fault")
+
+// middlewares is the registry of the built-in HTTP middlewares selectable by
+// name from ClientConfig.Middlewares.
+var middlewares = map[string]func(m *MCPInstance, next http.RoundTripper, opts MiddlewareOptions) http.RoundTripper{
+	faultInjectionMiddleware: func(_ *MCPInstance, next http.RoundTripper, opts MiddlewareOptions) http.RoundTripper {
+		return &faultRoundTripper{next: next, rate: opts.FaultInjection.Rate}
+	},
+	latencyInjectionMiddleware: func(_ *MCPInstance, next http.RoundTripper, opts MiddlewareOptions) http.RoundTripper {
+		return &latencyRoundTripper{
+			next:    next,
+			rate:    opts.LatencyInjection.Rate,
+			latency: durationOrZero(opts.LatencyInjection.Latency),
+		}
+	},
+	requestLoggingMiddleware: func(m *MCPInstance, next http.RoundTripper, _ MiddlewareOptions) http.RoundTripper {
+		return &loggingRoundTripper{next: next, logger: m.logger}
+	},
+}
+
+// withMiddlewares wraps next with the named middlewares, the first one
+// outermost.
+func (m *MCPInstance) withMiddlewares(next http.RoundTripper, names []string, opts MiddlewareOptions) http.RoundTripper {
+	for i := len(names) - 1; i >= 0; i-- {
+		next = middlewares[names[i]](m, next, opts)
+	}
+	return next
+}
+
+// validateMiddlewares reports unknown middleware names and invalid options of
+// the selected middlewares.
+func validateMiddlewares(names []string, opts MiddlewareOptions) []error {
+	var errs []error
+
+	for _, name := range names {
+		if _, ok := middlewares[name]; !ok {
+			errs = append(errs, fmt.Errorf("unknown middleware %q", name))
+			continue
+		}
+
+		switch name {
+		case faultInjectionMiddleware:
+			if err := checkRate("middleware_options.fault_injection.rate", opts.FaultInjection.Rate); err != nil {
+				errs = append(errs, err)
+			}
+		case latencyInjectionMiddleware:
+			if err := checkRate("middleware_options.latency_injection.rate", opts.LatencyInjection.Rate); err != nil {
+				errs = append(errs, err)
+			}
+			if opts.LatencyInjection.Latency == "" {
+				errs = append(errs, errors.New("middleware_options.latency_injection.latency is required"))
+			} else if err := checkDuration("middleware_options.latency_injection.latency", opts.LatencyInjection.Latency); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errs
+}
+
+func checkRate(name string, rate float64) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("%s must be between 0 and 1", name)
+	}
+	return nil
+}
+
+// faultRoundTripper fails a fraction of the requests without sending them.
+type faultRoundTripper struct {
+	next http.RoundTripper
+	rate float64
+}
+
+func (t *faultRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rand.Float64() < t.rate {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, errInjectedFault
+	}
+	return t.next.RoundTrip(req)
+}
+
+// latencyRoundTripper delays a fraction of the requests before sending them.
+type latencyRoundTripper struct {
+	next    http.RoundTripper
+	rate    float64
+	latency time.Duration
+}
+
+func (t *latencyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rand.Float64() < t.rate {
+		timer := time.NewTimer(t.latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			if req.Body != nil {
+				_ = req.Body.Close()
+			}
+			return nil, req.Context().Err()
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// loggingRoundTripper logs every request along with its outcome.
+type loggingRoundTripper struct {
+	next   http.RoundTripper
+	logger logrus.FieldLogger
+}
+
+func (t *loggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	logger := t.logger.WithFields(logrus.Fields{
+		"http_method": req.Method,
+		"url":         req.URL.String(),
+		"method":      jsonRPCMethod(req),
+		"duration":    time.Since(start),
+	})
+	if err != nil {
+		logger.WithError(err).Info("MCP request failed")
+	} else {
+		logger.WithField("status", resp.StatusCode).Info("MCP request")
+	}
+
+	return resp, err
+}
@@ -0,0 +1,379 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// defaultNotificationBufferSize is the number of notifications kept per
+// client when NotificationBufferSize is not set.
+const defaultNotificationBufferSize = 100
+
+// Notification is a server notification received by a client.
+type Notification struct {
+	Method string
+	Params any
+}
+
+// notificationBuffer keeps the latest server notifications in a ring buffer,
+// each stamped with the VU iteration it arrived in.
+type notificationBuffer struct {
+	// iteration is the VU iteration of the last call, since notifications
+	// arrive off the VU goroutine and cannot read it from the VU state
+	iteration atomic.Int64
+
+	// dropped, if set, is called with the method of every notification
+	// overwritten before being drained
+	dropped func(method string)
+
+	mu      sync.Mutex
+	entries []stampedNotification
+	start   int
+	count   int
+	waiters map[*notificationWaiter]struct{}
+}
+
+// notificationWaiter collects the notifications of one method received while
+// WaitForNotification is waiting for them.
+type notificationWaiter struct {
+	method  string
+	pending []Notification
+	ready   chan struct{}
+
+	// accept, if set, makes the waiter keep only the latest notification it
+	// accepts, stamped with now, counting the ones it replaced, so that a slow
+	// consumer does not queue them up
+	accept   func(Notification) bool
+	now      func() time.Time
+	latest   *Notification
+	received time.Time
+	replaced int
+}
+
+type stampedNotification struct {
+	Notification
+	iteration int64
+}
+
+func newNotificationBuffer(size int) *notificationBuffer {
+	if size <= 0 {
+		size = defaultNotificationBufferSize
+	}
+	return &notificationBuffer{entries: make([]stampedNotification, size)}
+}
+
+// add records a notification, overwriting the oldest one once the buffer is
+// full, which is reported to dropped.
+func (b *notificationBuffer) add(method string, params any) {
+	if overwritten, ok := b.insert(method, params); ok && b.dropped != nil {
+		b.dropped(overwritten)
+	}
+}
+
+// insert does the work of add, returning the method of the notification it
+// overwrote, if any, so that it is reported without holding the lock.
+func (b *notificationBuffer) insert(method string, params any) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := stampedNotification{
+		Notification: Notification{Method: method, Params: params},
+		iteration:    b.iteration.Load(),
+	}
+	for w := range b.waiters {
+		if w.method != method {
+			continue
+		}
+		switch {
+		case w.accept == nil:
+			// Pending notifications are bounded like the buffer, for waits
+			// that run for long on chatty servers.
+			if len(w.pending) == len(b.entries) {
+				w.pending = w.pending[1:]
+			}
+			w.pending = append(w.pending, n.Notification)
+		case w.accept(n.Notification):
+			if w.latest != nil {
+				w.replaced++
+			}
+			w.latest = &n.Notification
+			w.received = w.now()
+		default:
+			continue
+		}
+		select {
+		case w.ready <- struct{}{}:
+		default:
+		}
+	}
+	if b.count == len(b.entries) {
+		overwritten := b.entries[b.start].Method
+		b.entries[b.start] = n
+		b.start = (b.start + 1) % len(b.entries)
+		return overwritten, true
+	}
+	b.entries[(b.start+b.count)%len(b.entries)] = n
+	b.count++
+	return "", false
+}
+
+// drain empties the buffer, returning the notifications received during
+// iteration in arrival order.
+func (b *notificationBuffer) drain(iteration int64) []Notification {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	notifications := make([]Notification, 0, b.count)
+	for i := range b.count {
+		if n := b.entries[(b.start+i)%len(b.entries)]; n.iteration == iteration {
+			notifications = append(notifications, n.Notification)
+		}
+	}
+	clear(b.entries)
+	b.start, b.count = 0, 0
+
+	return notifications
+}
+
+// received returns the notifications of method received during iteration
+// that are still in the buffer, in arrival order.
+func (b *notificationBuffer) received(iteration int64, method string) []Notification {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var notifications []Notification
+	for i := range b.count {
+		if n := b.entries[(b.start+i)%len(b.entries)]; n.iteration == iteration && n.Method == method {
+			notifications = append(notifications, n.Notification)
+		}
+	}
+	return notifications
+}
+
+// wait starts collecting the notifications of method as they arrive, until
+// stopWaiting is called.
+func (b *notificationBuffer) wait(method string) *notificationWaiter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	w := &notificationWaiter{method: method, ready: make(chan struct{}, 1)}
+	if b.waiters == nil {
+		b.waiters = make(map[*notificationWaiter]struct{})
+	}
+	b.waiters[w] = struct{}{}
+	return w
+}
+
+// watch works like wait, keeping only the latest notification of method that
+// accept selects, stamped with the time now tells when it arrived.
+func (b *notificationBuffer) watch(method string, accept func(Notification) bool, now func() time.Time) *notificationWaiter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	w := &notificationWaiter{method: method, ready: make(chan struct{}, 1), accept: accept, now: now}
+	if b.waiters == nil {
+		b.waiters = make(map[*notificationWaiter]struct{})
+	}
+	b.waiters[w] = struct{}{}
+	return w
+}
+
+func (b *notificationBuffer) stopWaiting(w *notificationWaiter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.waiters, w)
+}
+
+// take returns the notifications w collected since the last take.
+func (b *notificationBuffer) take(w *notificationWaiter) []Notification {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pending := w.pending
+	w.pending = nil
+	return pending
+}
+
+// takeLatest returns the latest notification w kept since the last call, and
+// when it arrived, if any.
+func (b *notificationBuffer) takeLatest(w *notificationWaiter) (*Notification, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	latest := w.latest
+	w.latest = nil
+	return latest, w.received
+}
+
+// replaced returns the number of notifications w dropped for newer ones.
+func (b *notificationBuffer) replaced(w *notificationWaiter) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return w.replaced
+}
+
+// notificationHandlers sets opts up to record every server notification into
+// b. Handlers already set on opts keep running.
+func (b *notificationBuffer) notificationHandlers(opts *mcp.ClientOptions) {
+	opts.ToolListChangedHandler = func(_ context.Context, req *mcp.ToolListChangedRequest) {
+		b.add("notifications/tools/list_changed", req.Params)
+	}
+	opts.PromptListChangedHandler = func(_ context.Context, req *mcp.PromptListChangedRequest) {
+		b.add("notifications/prompts/list_changed", req.Params)
+	}
+	opts.ResourceListChangedHandler = func(_ context.Context, req *mcp.ResourceListChangedRequest) {
+		b.add("notifications/resources/list_changed", req.Params)
+	}
+	resourceUpdated := opts.ResourceUpdatedHandler
+	opts.ResourceUpdatedHandler = func(ctx context.Context, req *mcp.ResourceUpdatedNotificationRequest) {
+		if resourceUpdated != nil {
+			resourceUpdated(ctx, req)
+		}
+		b.add("notifications/resources/updated", req.Params)
+	}
+	loggingMessage := opts.LoggingMessageHandler
+	opts.LoggingMessageHandler = func(ctx context.Context, req *mcp.LoggingMessageRequest) {
+		if loggingMessage != nil {
+			loggingMessage(ctx, req)
+		}
+		b.add("notifications/message", req.Params)
+	}
+	progress := opts.ProgressNotificationHandler
+	opts.ProgressNotificationHandler = func(ctx context.Context, req *mcp.ProgressNotificationClientRequest) {
+		if progress != nil {
+			progress(ctx, req)
+		}
+		b.add("notifications/progress", req.Params)
+	}
+}
+
+// DrainNotifications returns the server notifications received since the
+// last drain, leaving out the ones received during earlier iterations, and
+// empties the buffer.
+func (c *Client) DrainNotifications() []Notification {
+	return c.notifications.drain(c.iteration())
+}
+
+// WaitForNotificationOptions configures WaitForNotification.
+type WaitForNotificationOptions struct {
+	// TimeoutMs rejects the wait once it has passed, if set
+	TimeoutMs int
+	// Match, if set, is called with every candidate notification and selects
+	// the awaited one by returning true
+	Match sobek.Value
+}
+
+// WaitForNotification returns a promise resolved with the first notification
+// of method received during the current iteration that opts.Match accepts,
+// including one already received but not drained.
+func (c *Client) WaitForNotification(method string, opts WaitForNotificationOptions) (*sobek.Promise, error) {
+	rt := c.vu.Runtime()
+
+	var match sobek.Callable
+	if !isNullish(opts.Match) {
+		fn, ok := sobek.AssertFunction(opts.Match)
+		if !ok {
+			return nil, errors.New("match must be a function")
+		}
+		match = fn
+	}
+	// find runs on the event loop and reports the first notification accepted
+	// by match, if any.
+	find := func(notifications []Notification) (*Notification, error) {
+		for i := range notifications {
+			if match == nil {
+				return &notifications[i], nil
+			}
+			v, err := match(sobek.Undefined(), rt.ToValue(notifications[i]))
+			if err != nil {
+				return nil, err
+			}
+			if v.ToBoolean() {
+				return &notifications[i], nil
+			}
+		}
+		return nil, nil
+	}
+
+	promise, resolve, reject := rt.NewPromise()
+	start := c.metrics.Now()
+	settle := func(n *Notification, err error) {
+		if err != nil {
+			_ = reject(err)
+			return
+		}
+		c.metrics.PushNotificationWait(c.ctx, method, c.metrics.Since(start))
+		_ = resolve(*n)
+	}
+
+	// Start waiting before looking at the buffer, so that no notification
+	// slips in between.
+	w := c.notifications.wait(method)
+	if n, err := find(c.notifications.received(c.iteration(), method)); n != nil || err != nil {
+		c.notifications.stopWaiting(w)
+		settle(n, err)
+		return promise, nil
+	}
+
+	callback := c.vu.RegisterCallback()
+	go func() {
+		defer c.notifications.stopWaiting(w)
+
+		var timeout <-chan time.Time
+		if opts.TimeoutMs > 0 {
+			timer := time.NewTimer(time.Duration(opts.TimeoutMs) * time.Millisecond)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		for {
+			select {
+			case <-w.ready:
+				done := make(chan bool, 1)
+				callback(func() error {
+					n, err := find(c.notifications.take(w))
+					if n == nil && err == nil {
+						// Keep the event loop alive for the next candidates.
+						callback = c.vu.RegisterCallback()
+						done <- false
+						return nil
+					}
+					settle(n, err)
+					done <- true
+					return nil
+				})
+				select {
+				case finished := <-done:
+					if finished {
+						return
+					}
+				case <-c.ctx.Done():
+					return
+				}
+			case <-timeout:
+				callback(func() error {
+					_ = reject(fmt.Errorf("timed out after %dms waiting for %s", opts.TimeoutMs, method))
+					return nil
+				})
+				return
+			case <-c.ctx.Done():
+				callback(func() error {
+					_ = reject(c.ctx.Err())
+					return nil
+				})
+				return
+			}
+		}
+	}()
+
+	return promise, nil
+}
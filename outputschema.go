@@ -0,0 +1,63 @@
+package mcp
+
+import (
+	"encoding/json"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// outputSchemaMetaKey is the _meta key of tool results holding the $id of the
+// output schema their structured content conforms to.
+const outputSchemaMetaKey = "xk6-mcp/outputSchema"
+
+// rememberOutputSchemas records the $id of the output schema of every listed
+// tool, for the results of its calls to refer to.
+func (c *Client) rememberOutputSchemas(tools []*mcp.Tool) {
+	for _, t := range tools {
+		if t == nil {
+			continue
+		}
+		if id := schemaID(t.OutputSchema); id != "" {
+			c.outputSchemas.Store(t.Name, id)
+		} else {
+			c.outputSchemas.Delete(t.Name)
+		}
+	}
+}
+
+// annotateOutputSchema sets the $id of the output schema of the tool in the
+// _meta of its result, if the result has structured content, the schema of
+// the tool was listed and the server did not set the key itself.
+func (c *Client) annotateOutputSchema(tool string, res *mcp.CallToolResult) {
+	if res == nil || res.StructuredContent == nil {
+		return
+	}
+	id, ok := c.outputSchemas.Load(tool)
+	if !ok {
+		return
+	}
+	if res.Meta == nil {
+		res.Meta = mcp.Meta{}
+	}
+	if _, ok := res.Meta[outputSchemaMetaKey]; !ok {
+		res.Meta[outputSchemaMetaKey] = id
+	}
+}
+
+// schemaID returns the $id of a JSON schema, or "" if it has none.
+func schemaID(schema any) string {
+	if schema == nil {
+		return ""
+	}
+	raw, err := json.Marshal(schema)
+	if err != nil {
+		return ""
+	}
+	var s struct {
+		ID string `json:"$id"`
+	}
+	if json.Unmarshal(raw, &s) != nil {
+		return ""
+	}
+	return s.ID
+}
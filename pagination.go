@@ -0,0 +1,46 @@
+package mcp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ToolListStats describes how listing every tool of the server paginated.
+type ToolListStats struct {
+	TotalTools        int
+	PageCount         int
+	TotalDurationMs   float64
+	PerPageDurationMs []float64
+}
+
+// ToolListStats lists every tool page by page, like ListAllTools, and reports
+// the number of pages and the time each of them took.
+func (c *Client) ToolListStats() (*ToolListStats, error) {
+	stats := &ToolListStats{}
+
+	start := c.metrics.Now()
+	cursor := ""
+	for {
+		pageStart := c.metrics.Now()
+		result, err := c.ListTools(mcp.ListToolsParams{Meta: mcp.Meta{}, Cursor: cursor})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tools: %w", err)
+		}
+
+		stats.PageCount++
+		stats.PerPageDurationMs = append(stats.PerPageDurationMs, float64(c.metrics.Since(pageStart))/float64(time.Millisecond))
+		stats.TotalTools += len(result.Tools)
+
+		if result.NextCursor == "" {
+			break
+		}
+		cursor = result.NextCursor
+	}
+	stats.TotalDurationMs = float64(c.metrics.Since(start)) / float64(time.Millisecond)
+
+	c.metrics.PushPaginationPages(c.ctx, ListToolsMethod, stats.PageCount)
+
+	return stats, nil
+}
@@ -0,0 +1,496 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/grafana/xk6-mcp/metrics"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.k6.io/k6/js/common"
+)
+
+var (
+	errNotPooled      = errors.New("client was not acquired from a session pool")
+	errClientReleased = errors.New("client was released to its session pool")
+	errPooledClose    = errors.New("pooled clients must be released, not closed")
+	errSessionIdle    = errors.New("pooled session is not leased to any client")
+)
+
+// poolLeaveGrace is how long the idle sessions of a pool are kept once no
+// iteration is running, so that a VU moving on to its next iteration finds
+// them still open.
+const poolLeaveGrace = time.Second
+
+type (
+	// PoolConfig configures a SessionPool.
+	PoolConfig struct {
+		// Name identifies the pool shared by every VU. It defaults to the
+		// transport and server address.
+		Name      string
+		Transport string
+		Size      int
+		Client    ClientConfig
+
+		// IdleTimeout, if set, closes the sessions left idle for longer,
+		// down to MinSize sessions
+		IdleTimeout string
+		MinSize     int
+	}
+
+	// SessionPool is the per-VU handle on a pool of warm sessions shared by
+	// every VU.
+	SessionPool struct {
+		m    *MCPInstance
+		cfg  PoolConfig
+		pool *sessionPool
+		// joined is the iteration context the pool was last joined with
+		joined context.Context
+	}
+
+	// sessionPool holds up to size sessions, dialed on demand. Sessions idle
+	// for longer than idleTimeout are closed while more than minSize remain,
+	// and all of them once no iteration has been running for poolLeaveGrace.
+	sessionPool struct {
+		name        string
+		size        int
+		minSize     int
+		idleTimeout time.Duration
+		idle        chan idleSession
+
+		mu      sync.Mutex
+		created int
+		// users is the number of running iterations that joined the pool, and
+		// leaving closes the idle sessions once there has been none for
+		// poolLeaveGrace
+		users   int
+		leaving *time.Timer
+		// ctx and metrics are those of the VU that last acquired a session,
+		// which report the size of the pool as the reaper shrinks it
+		ctx     context.Context
+		metrics *metrics.K6Metrics
+	}
+
+	idleSession struct {
+		*pooledSession
+		since time.Time
+	}
+
+	// pooledSession is a session of a pool, with what it needs to be handed
+	// from client to client.
+	pooledSession struct {
+		*liveSession
+		lease  *sessionLease
+		reinit *reinitializer
+	}
+
+	// sessionLease routes the server requests and notifications of a pooled
+	// session to the client it is leased to, since the session outlives the
+	// client that dialed it.
+	sessionLease struct {
+		client atomic.Pointer[Client]
+	}
+)
+
+func newSessionPool(name string, size, minSize int, idleTimeout time.Duration) *sessionPool {
+	return &sessionPool{
+		name:        name,
+		size:        size,
+		minSize:     minSize,
+		idleTimeout: idleTimeout,
+		idle:        make(chan idleSession, size),
+	}
+}
+
+// acquire returns an idle session, dials a new one while the pool is below
+// its size, or otherwise waits for a session to be released. Idle sessions
+// whose connection ended are closed rather than handed out.
+func (p *sessionPool) acquire(ctx context.Context, dial func() (*pooledSession, error)) (*pooledSession, error) {
+	for {
+		select {
+		case session := <-p.idle:
+			if p.alive(session.pooledSession) {
+				return session.pooledSession, nil
+			}
+			continue
+		default:
+		}
+
+		p.mu.Lock()
+		if p.created < p.size {
+			p.created++
+			p.mu.Unlock()
+
+			session, err := dial()
+			if err != nil {
+				p.mu.Lock()
+				p.created--
+				p.mu.Unlock()
+				return nil, err
+			}
+			return session, nil
+		}
+		p.mu.Unlock()
+
+		select {
+		case session := <-p.idle:
+			if p.alive(session.pooledSession) {
+				return session.pooledSession, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// alive reports whether session is still connected, removing it from the
+// pool if not.
+func (p *sessionPool) alive(session *pooledSession) bool {
+	if session.connected() {
+		return true
+	}
+	p.mu.Lock()
+	p.created--
+	p.mu.Unlock()
+	_ = session.Close()
+	return false
+}
+
+// release returns session to the pool, or closes it if the idle sessions
+// were already closed as no iteration is running anymore.
+func (p *sessionPool) release(session *pooledSession) {
+	p.mu.Lock()
+	if p.users == 0 && p.leaving == nil {
+		p.created--
+		p.mu.Unlock()
+		_ = session.Close()
+		return
+	}
+	p.idle <- idleSession{pooledSession: session, since: time.Now()}
+	p.mu.Unlock()
+
+	if p.idleTimeout > 0 {
+		time.AfterFunc(p.idleTimeout, p.reap)
+	}
+}
+
+// join counts the iteration of ctx as running until ctx is done. The idle
+// sessions are closed once no iteration that joined has been running for
+// poolLeaveGrace, at the end of the test, since they would otherwise outlive
+// it. The grace spans the gap between the iterations of a VU.
+func (p *sessionPool) join(ctx context.Context) {
+	p.mu.Lock()
+	p.users++
+	if p.leaving != nil {
+		p.leaving.Stop()
+		p.leaving = nil
+	}
+	p.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.users--
+		if p.users == 0 {
+			p.leaving = time.AfterFunc(poolLeaveGrace, p.closeIdle)
+		}
+	})
+}
+
+// closeIdle closes the idle sessions of the pool, unless an iteration joined
+// it again.
+func (p *sessionPool) closeIdle() {
+	p.mu.Lock()
+	if p.users > 0 {
+		p.mu.Unlock()
+		return
+	}
+	p.leaving = nil
+	var idle []*pooledSession
+drain:
+	for {
+		select {
+		case session := <-p.idle:
+			idle = append(idle, session.pooledSession)
+			p.created--
+		default:
+			break drain
+		}
+	}
+	p.mu.Unlock()
+
+	for _, session := range idle {
+		_ = session.Close()
+	}
+}
+
+// reap closes the sessions idle for longer than the idle timeout of the pool,
+// oldest first, as long as more than minSize sessions remain.
+func (p *sessionPool) reap() {
+	p.mu.Lock()
+	var expired []*pooledSession
+	for range len(p.idle) {
+		var session idleSession
+		select {
+		case session = <-p.idle:
+		default:
+		}
+		if session.pooledSession == nil {
+			break
+		}
+		if time.Since(session.since) >= p.idleTimeout && p.created > p.minSize {
+			p.created--
+			expired = append(expired, session.pooledSession)
+		} else {
+			p.idle <- session
+		}
+	}
+	idle := len(p.idle)
+	active := p.created - idle
+	ctx, metrics := p.ctx, p.metrics
+	p.mu.Unlock()
+
+	if len(expired) == 0 {
+		return
+	}
+	for _, session := range expired {
+		_ = session.Close()
+	}
+	if metrics != nil && ctx.Err() == nil {
+		metrics.PushPoolSize(ctx, p.name, active, idle)
+	}
+}
+
+// observe makes the VU of ctx report the size of the pool when the reaper
+// shrinks it.
+func (p *sessionPool) observe(ctx context.Context, metrics *metrics.K6Metrics) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ctx, p.metrics = ctx, metrics
+}
+
+// counts returns the number of sessions checked out and sitting idle.
+func (p *sessionPool) counts() (active, idle int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	idle = len(p.idle)
+	return p.created - idle, idle
+}
+
+// pool returns the session pool registered under name, creating it if needed.
+func (r *RootModule) pool(name string, size, minSize int, idleTimeout time.Duration) *sessionPool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pools == nil {
+		r.pools = make(map[string]*sessionPool)
+	}
+	p, ok := r.pools[name]
+	if !ok {
+		p = newSessionPool(name, size, minSize, idleTimeout)
+		r.pools[name] = p
+	}
+
+	return p
+}
+
+func (m *MCPInstance) newSessionPool(c sobek.ConstructorCall, rt *sobek.Runtime) *sobek.Object {
+	var cfg PoolConfig
+	if err := rt.ExportTo(m.withNestedClientDefaults(rt, c.Argument(0)), &cfg); err != nil {
+		common.Throw(rt, fmt.Errorf("invalid pool config: %w", err))
+	}
+
+	var errs []error
+	if cfg.Size <= 0 {
+		errs = append(errs, errors.New("size must be positive"))
+	}
+	if cfg.MinSize < 0 || cfg.MinSize > cfg.Size {
+		errs = append(errs, errors.New("min_size must be between 0 and size"))
+	}
+	if err := checkDuration("idle_timeout", cfg.IdleTimeout); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.Client.StrictMode {
+		errs = append(errs, errors.New("strict_mode is not supported for pooled sessions"))
+	}
+	if err := ValidateConfig(cfg.Client, cfg.Transport); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		common.Throw(rt, fmt.Errorf("invalid pool config: %w", err))
+	}
+
+	if cfg.Name == "" {
+		cfg.Name = cfg.Transport + " " + cfg.Client.BaseURL + cfg.Client.Path
+	}
+
+	return rt.ToValue(&SessionPool{
+		m:    m,
+		cfg:  cfg,
+		pool: m.root.pool(cfg.Name, cfg.Size, cfg.MinSize, durationOrZero(cfg.IdleTimeout)),
+	}).ToObject(rt)
+}
+
+// Acquire checks a session out of the pool, waiting for one to be released if
+// the pool is exhausted. The session is returned to the pool by
+// client.release(), or automatically at the end of the iteration, when k6
+// cancels the VU context it runs with. The server requests and notifications
+// of the session go to the handlers of the client for as long as it holds the
+// session.
+func (p *SessionPool) Acquire() (*Client, error) {
+	if ctx := p.m.getContext(); p.joined != ctx {
+		p.joined = ctx
+		p.pool.join(ctx)
+	}
+
+	client, err := p.m.wrapClient(p.cfg.Client)
+	if err != nil {
+		return nil, err
+	}
+	client.handlers = client.clientOptions(p.m.vu.Runtime(), p.cfg.Client)
+
+	start := client.metrics.Now()
+	session, err := p.pool.acquire(p.m.getContext(), func() (*pooledSession, error) {
+		transport, isStateless, err := p.m.newTransport(p.cfg.Client, p.cfg.Transport, client.stats, client.scopes, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.waitConnectJitter(p.m.getContext(), durationOrZero(p.cfg.Client.ConnectJitter)); err != nil {
+			return nil, err
+		}
+		lease := &sessionLease{}
+		opts := lease.options(client)
+		opts.Stateless = isStateless
+		// Pooled sessions outlive the VU that dialed them, and are closed by
+		// the pool.
+		session, err := dial(context.Background(), p.m.sessionLogger(p.cfg.Transport).WithField("pool", p.pool.name), transport, isStateless, opts, lease.timeRootsList)
+		if err != nil {
+			return nil, err
+		}
+		client.startKeepAlive(session, durationOrZero(p.cfg.Client.KeepAlivePingInterval))
+		return &pooledSession{liveSession: session, lease: lease, reinit: newReinitializer(transport)}, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire session from pool %q: %w", p.pool.name, err)
+	}
+	client.metrics.PushPoolWait(client.ctx, p.pool.name, client.metrics.Since(start))
+	p.pool.observe(client.ctx, client.metrics)
+
+	session.lease.client.Store(client)
+	client.session = session.liveSession
+	client.reinit = session.reinit
+	var once sync.Once
+	client.release = func() {
+		once.Do(func() {
+			client.released.Store(true)
+			session.lease.client.CompareAndSwap(client, nil)
+			p.pool.release(session)
+			active, idle := p.pool.counts()
+			client.metrics.PushPoolSize(client.ctx, p.pool.name, active, idle)
+		})
+	}
+	context.AfterFunc(p.m.getContext(), client.release)
+
+	active, idle := p.pool.counts()
+	client.metrics.PushPoolSize(client.ctx, p.pool.name, active, idle)
+
+	return client, nil
+}
+
+// options returns the options of dialer, the client dialing a pooled
+// session, with every handler set calling the one of the client the session
+// is leased to instead. The clients of a pool share their config, so they all
+// have the same handlers set. Server requests received while the session is
+// idle fail, and notifications are dropped, except for server logs, which
+// still go to the log file.
+func (l *sessionLease) options(dialer *Client) *mcp.ClientOptions {
+	opts := dialer.handlers
+	leased := *opts
+	if opts.CreateMessageHandler != nil {
+		leased.CreateMessageHandler = func(ctx context.Context, req *mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+			c := l.client.Load()
+			if c == nil {
+				return nil, errSessionIdle
+			}
+			return c.handlers.CreateMessageHandler(ctx, req)
+		}
+	}
+	if opts.ElicitationHandler != nil {
+		leased.ElicitationHandler = func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			c := l.client.Load()
+			if c == nil {
+				return nil, errSessionIdle
+			}
+			return c.handlers.ElicitationHandler(ctx, req)
+		}
+	}
+	leased.ToolListChangedHandler = leasedNotification(l, opts.ToolListChangedHandler, func(h *mcp.ClientOptions) func(context.Context, *mcp.ToolListChangedRequest) {
+		return h.ToolListChangedHandler
+	})
+	leased.PromptListChangedHandler = leasedNotification(l, opts.PromptListChangedHandler, func(h *mcp.ClientOptions) func(context.Context, *mcp.PromptListChangedRequest) {
+		return h.PromptListChangedHandler
+	})
+	leased.ResourceListChangedHandler = leasedNotification(l, opts.ResourceListChangedHandler, func(h *mcp.ClientOptions) func(context.Context, *mcp.ResourceListChangedRequest) {
+		return h.ResourceListChangedHandler
+	})
+	leased.ResourceUpdatedHandler = leasedNotification(l, opts.ResourceUpdatedHandler, func(h *mcp.ClientOptions) func(context.Context, *mcp.ResourceUpdatedNotificationRequest) {
+		return h.ResourceUpdatedHandler
+	})
+	leased.LoggingMessageHandler = leasedNotification(l, opts.LoggingMessageHandler, func(h *mcp.ClientOptions) func(context.Context, *mcp.LoggingMessageRequest) {
+		return h.LoggingMessageHandler
+	})
+	if logging := leased.LoggingMessageHandler; dialer.logFile != nil {
+		leased.LoggingMessageHandler = func(ctx context.Context, req *mcp.LoggingMessageRequest) {
+			if l.client.Load() == nil {
+				dialer.logFile.write(req.Params, dialer.metrics.Now())
+				return
+			}
+			logging(ctx, req)
+		}
+	}
+	leased.ProgressNotificationHandler = leasedNotification(l, opts.ProgressNotificationHandler, func(h *mcp.ClientOptions) func(context.Context, *mcp.ProgressNotificationClientRequest) {
+		return h.ProgressNotificationHandler
+	})
+	return &leased
+}
+
+// leasedNotification returns a notification handler calling the one handler
+// picks from the options of the client l is leased to, or nil if set is nil.
+func leasedNotification[R any](l *sessionLease, set func(context.Context, R), handler func(*mcp.ClientOptions) func(context.Context, R)) func(context.Context, R) {
+	if set == nil {
+		return nil
+	}
+	return func(ctx context.Context, req R) {
+		if c := l.client.Load(); c != nil {
+			if h := handler(c.handlers); h != nil {
+				h(ctx, req)
+			}
+		}
+	}
+}
+
+// timeRootsList times the roots/list requests of the session for the client
+// it is leased to.
+func (l *sessionLease) timeRootsList(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if c := l.client.Load(); c != nil {
+			return c.timeRootsList(next)(ctx, method, req)
+		}
+		return next(ctx, method, req)
+	}
+}
+
+// Release returns a pooled client's session to its pool. The client must not
+// be used afterwards.
+func (c *Client) Release() error {
+	if c.release == nil {
+		return errNotPooled
+	}
+	c.release()
+	return nil
+}
@@ -0,0 +1,144 @@
+package mcp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ColdWarmProbeResult holds the latency of a cold and a warm call to a tool.
+type ColdWarmProbeResult struct {
+	ColdMs float64
+	WarmMs float64
+}
+
+// ColdWarmProbe calls a tool twice in a row and reports the latency of the
+// first (cold) and second (warm) call. The request metrics of each call are
+// tagged with cache_state=cold or cache_state=warm.
+func (c *Client) ColdWarmProbe(r mcp.CallToolParams) (*ColdWarmProbeResult, error) {
+	cold, err := c.timedCallTool(r, "cold")
+	if err != nil {
+		return nil, fmt.Errorf("cold call failed: %w", err)
+	}
+
+	warm, err := c.timedCallTool(r, "warm")
+	if err != nil {
+		return nil, fmt.Errorf("warm call failed: %w", err)
+	}
+
+	return &ColdWarmProbeResult{
+		ColdMs: float64(cold) / float64(time.Millisecond),
+		WarmMs: float64(warm) / float64(time.Millisecond),
+	}, nil
+}
+
+func (c *Client) timedCallTool(r mcp.CallToolParams, cacheState string) (time.Duration, error) {
+	start := c.metrics.Now()
+	_, err := c.callTool(r, map[string]string{"cache_state": cacheState})
+	return c.metrics.Since(start), err
+}
+
+// The phases of probeColdStart, which its metrics are tagged with as phase.
+const (
+	coldStartConnect = "connect"
+	coldStartList    = "list"
+	coldStartCall    = "call"
+	coldStartTotal   = "total"
+)
+
+type (
+	// ColdStartConfig configures probeColdStart: the transport and config
+	// of the client it connects.
+	ColdStartConfig struct {
+		Transport string
+		Client    ClientConfig
+	}
+
+	// ColdStartResult holds the latency of each phase of a cold start, from
+	// connecting a new client to the end of its first tool call.
+	ColdStartResult struct {
+		ConnectMs float64
+		ListMs    float64
+		CallMs    float64
+		TotalMs   float64
+	}
+)
+
+// probeColdStart connects a new client, lists the tools of the server and
+// calls the tool of r, then closes the client. Each phase is recorded in the
+// cold start metric, tagged with its phase, and the latencies are returned.
+// Connecting includes initializing the session. connect_jitter is not
+// applied, since it would skew the connect phase.
+func (m *MCPInstance) probeColdStart(config sobek.Value, r mcp.CallToolParams) (*ColdStartResult, error) {
+	rt := m.vu.Runtime()
+	var cfg ColdStartConfig
+	if err := rt.ExportTo(m.withNestedClientDefaults(rt, config), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if cfg.Transport == "" {
+		cfg.Transport = streamableHTTPTransport
+	}
+	if err := ValidateConfig(cfg.Client, cfg.Transport); err != nil {
+		return nil, err
+	}
+
+	client, err := m.wrapClient(cfg.Client)
+	if err != nil {
+		return nil, err
+	}
+	transport, isStateless, err := m.newTransport(cfg.Client, cfg.Transport, client.stats, client.scopes, nil)
+	if err != nil {
+		return nil, err
+	}
+	opts := client.clientOptions(rt, cfg.Client)
+	opts.Stateless = isStateless
+	client.reinit = newReinitializer(transport)
+	transport = client.strictTransport(transport)
+
+	start := client.metrics.Now()
+	client.session, err = dial(m.getContext(), m.sessionLogger(cfg.Transport), transport, isStateless, opts, client.timeRootsList)
+	if err != nil {
+		return nil, fmt.Errorf("connection error: %w", err)
+	}
+	defer func() { _ = client.session.Close() }()
+	if err := client.checkNegotiatedVersion(); err != nil {
+		return nil, fmt.Errorf("connection error: %w", err)
+	}
+	connected := client.metrics.Now()
+
+	if _, err := client.ListTools(mcp.ListToolsParams{}); err != nil {
+		return nil, fmt.Errorf("failed to list tools: %w", err)
+	}
+	listed := client.metrics.Now()
+
+	res, err := client.callTool(r, nil)
+	if err == nil && res.IsError {
+		err = fmt.Errorf("tool %q returned an error", r.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to call tool: %w", err)
+	}
+	called := client.metrics.Now()
+
+	phases := []struct {
+		name     string
+		duration time.Duration
+	}{
+		{coldStartConnect, connected.Sub(start)},
+		{coldStartList, listed.Sub(connected)},
+		{coldStartCall, called.Sub(listed)},
+		{coldStartTotal, called.Sub(start)},
+	}
+	for _, phase := range phases {
+		client.metrics.PushColdStart(client.ctx, phase.name, phase.duration)
+	}
+
+	return &ColdStartResult{
+		ConnectMs: float64(phases[0].duration) / float64(time.Millisecond),
+		ListMs:    float64(phases[1].duration) / float64(time.Millisecond),
+		CallMs:    float64(phases[2].duration) / float64(time.Millisecond),
+		TotalMs:   float64(phases[3].duration) / float64(time.Millisecond),
+	}, nil
+}
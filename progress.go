@@ -0,0 +1,127 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+var (
+	// progressCalls maps the progress token of every tool call in flight to
+	// the number of progress notifications received for it. Tokens are
+	// tracked across VUs, since pooled sessions deliver notifications to
+	// whichever client dialed them.
+	progressCalls     = map[string]*progressCall{}
+	progressCallsMu   sync.Mutex
+	nextProgressToken atomic.Int64
+)
+
+// progressCall counts the progress notifications of the calls in flight
+// sharing a progress token, which only happens when scripts reuse their own.
+type progressCall struct {
+	updates atomic.Int64
+	calls   int
+}
+
+// progressTokenKey returns the key of a progress token in progressCalls,
+// reporting false if it is neither a string nor a number. Numbers are keyed by
+// their JSON form, so that 7 sent as an integer matches the 7 a server sends
+// back, which is decoded as a float.
+func progressTokenKey(token any) (string, bool) {
+	switch token.(type) {
+	case string, int, int32, int64, float64:
+	default:
+		return "", false
+	}
+	b, err := json.Marshal(token)
+	return string(b), err == nil
+}
+
+type progressKey struct{}
+
+// withProgress returns ctx with a slot that trackProgressTokens adds the
+// number of progress notifications received for the tool call made with ctx
+// to.
+func withProgress(ctx context.Context) (context.Context, *atomic.Int64) {
+	updates := &atomic.Int64{}
+	return context.WithValue(ctx, progressKey{}, updates), updates
+}
+
+// trackProgressTokens is a sending middleware of every session, which counts
+// the progress notifications received for the tool calls made with a
+// withProgress context. It comes after interceptRequests, so that a progress
+// token set by an interceptor is the one tracked.
+func trackProgressTokens(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		updates, ok := ctx.Value(progressKey{}).(*atomic.Int64)
+		params, isCall := req.GetParams().(*mcp.CallToolParams)
+		if !ok || !isCall || params == nil {
+			return next(ctx, method, req)
+		}
+
+		stop := trackProgress(params)
+		res, err := next(ctx, method, req)
+		updates.Add(int64(stop()))
+		return res, err
+	}
+}
+
+// trackProgress counts the progress notifications received for the call
+// params are sent with. The progress token of params is kept when it has
+// one, so that scripts can still match the notifications they drain, and a
+// new one is given to it otherwise. The returned function stops tracking it
+// and returns the number of progress notifications received.
+func trackProgress(params *mcp.CallToolParams) func() int {
+	token := params.GetProgressToken()
+	if token == nil {
+		token = "xk6-mcp-" + strconv.FormatInt(nextProgressToken.Add(1), 10)
+		// SetProgressToken drops the token when there is no Meta to hold it.
+		params.Meta = maps.Clone(params.Meta)
+		if params.Meta == nil {
+			params.Meta = mcp.Meta{}
+		}
+		params.SetProgressToken(token)
+	}
+	key, ok := progressTokenKey(token)
+	if !ok {
+		return func() int { return 0 }
+	}
+
+	progressCallsMu.Lock()
+	call, ok := progressCalls[key]
+	if !ok {
+		call = &progressCall{}
+		progressCalls[key] = call
+	}
+	call.calls++
+	progressCallsMu.Unlock()
+
+	return func() int {
+		progressCallsMu.Lock()
+		if call.calls--; call.calls == 0 {
+			delete(progressCalls, key)
+		}
+		progressCallsMu.Unlock()
+		return int(call.updates.Load())
+	}
+}
+
+// recordProgress counts a progress notification against the call it reports
+// on, if that call is being tracked.
+func recordProgress(_ context.Context, req *mcp.ProgressNotificationClientRequest) {
+	key, ok := progressTokenKey(req.Params.ProgressToken)
+	if !ok {
+		return
+	}
+	progressCallsMu.Lock()
+	call, ok := progressCalls[key]
+	progressCallsMu.Unlock()
+	if ok {
+		call.updates.Add(1)
+	}
+}
@@ -0,0 +1,177 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type (
+	// PromptRequest is one rendering requested from getPrompts.
+	PromptRequest struct {
+		Name string
+		Args map[string]string
+	}
+
+	GetPromptsOptions struct {
+		// Concurrency is the number of prompts fetched at once. Prompts are
+		// fetched one after the other unless it is greater than 1.
+		Concurrency int
+	}
+)
+
+// hasPromptArgument reports whether prompt declares an argument named name,
+// or name is empty.
+func hasPromptArgument(prompt *mcp.Prompt, name string) bool {
+	if name == "" {
+		return true
+	}
+	return slices.ContainsFunc(prompt.Arguments, func(arg *mcp.PromptArgument) bool {
+		return arg != nil && arg.Name == name
+	})
+}
+
+// PromptMessage is a message of a prompt as returned by getPromptMessages,
+// whose content is in its JSON form, with its type, such as text or image,
+// and every field the server sent.
+type PromptMessage struct {
+	Role    mcp.Role
+	Content map[string]any
+}
+
+type GetPromptParams struct {
+	Meta      mcp.Meta
+	Name      string
+	Arguments map[string]string
+	// ResolveResources, if set, replaces the resource references in the
+	// prompt messages with the contents read from them.
+	ResolveResources bool
+}
+
+func (c *Client) GetPrompt(r GetPromptParams) (*mcp.GetPromptResult, error) {
+	res, err := call(c, GetPromptMethod, func(ctx context.Context) (*mcp.GetPromptResult, error) {
+		params := &mcp.GetPromptParams{
+			Meta:      r.Meta,
+			Name:      r.Name,
+			Arguments: r.Arguments,
+		}
+		recordParams(ctx, params)
+		return deduplicate(ctx, c, GetPromptMethod, params, func() (*mcp.GetPromptResult, error) {
+			return c.session.GetPrompt(ctx, params)
+		})
+	})
+	if err != nil || !r.ResolveResources {
+		return res, err
+	}
+
+	return c.resolvePromptResources(r.Name, res)
+}
+
+// GetPromptMessages works like GetPrompt, returning the messages of the prompt
+// with their content as sent by the server. The content objects of GetPrompt
+// leave out their type, which scripts need to check multimodal prompts.
+func (c *Client) GetPromptMessages(r GetPromptParams) ([]PromptMessage, error) {
+	res, err := c.GetPrompt(r)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]PromptMessage, len(res.Messages))
+	for i, msg := range res.Messages {
+		raw, err := json.Marshal(msg.Content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid content of message %d: %w", i, err)
+		}
+		messages[i].Role = msg.Role
+		if err := json.Unmarshal(raw, &messages[i].Content); err != nil {
+			return nil, fmt.Errorf("invalid content of message %d: %w", i, err)
+		}
+	}
+	return messages, nil
+}
+
+// resolvePromptResources reads every resource referenced by the messages of
+// res and inlines its contents as embedded resources, one message per
+// content. The reads are tagged with the prompt name so they can be told
+// apart from the ones issued by the script.
+func (c *Client) resolvePromptResources(prompt string, res *mcp.GetPromptResult) (*mcp.GetPromptResult, error) {
+	tags := map[string]string{"prompt": prompt}
+
+	messages := make([]*mcp.PromptMessage, 0, len(res.Messages))
+	for _, msg := range res.Messages {
+		uri, ok := resourceReference(msg.Content)
+		if !ok {
+			messages = append(messages, msg)
+			continue
+		}
+
+		read, err := c.readResource(mcp.ReadResourceParams{URI: uri}, tags)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve resource %q: %w", uri, err)
+		}
+		for _, contents := range read.Contents {
+			messages = append(messages, &mcp.PromptMessage{
+				Role:    msg.Role,
+				Content: &mcp.EmbeddedResource{Resource: contents},
+			})
+		}
+	}
+
+	resolved := *res
+	resolved.Messages = messages
+	return &resolved, nil
+}
+
+// resourceReference returns the URI of content if it refers to a resource
+// without carrying its contents.
+func resourceReference(content mcp.Content) (string, bool) {
+	switch content := content.(type) {
+	case *mcp.ResourceLink:
+		return content.URI, true
+	case *mcp.EmbeddedResource:
+		if r := content.Resource; r != nil && r.Text == "" && r.Blob == nil {
+			return r.URI, true
+		}
+	}
+	return "", false
+}
+
+// GetPrompts fetches every requested prompt rendering and returns the results
+// in the order of requests. Each prompts/get call is tagged with the prompt
+// name, so variants can be compared.
+func (c *Client) GetPrompts(requests []PromptRequest, opts GetPromptsOptions) ([]*mcp.GetPromptResult, error) {
+	results := make([]*mcp.GetPromptResult, len(requests))
+	errs := make([]error, len(requests))
+
+	_, _ = await(c, func() (struct{}, error) {
+		sem := make(chan struct{}, max(opts.Concurrency, 1))
+		var wg sync.WaitGroup
+		for i, r := range requests {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer func() { <-sem; wg.Done() }()
+				results[i], errs[i] = invoke(c, GetPromptMethod, "", map[string]string{"prompt": r.Name}, strictly(c, intercepting(c, func(ctx context.Context) (*mcp.GetPromptResult, error) {
+					params := &mcp.GetPromptParams{Name: r.Name, Arguments: r.Args}
+					recordParams(ctx, params)
+					return deduplicate(ctx, c, GetPromptMethod, params, func() (*mcp.GetPromptResult, error) {
+						return c.session.GetPrompt(ctx, params)
+					})
+				})))
+			}()
+		}
+		wg.Wait()
+		return struct{}{}, nil
+	})
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to get prompt %d (%q): %w", i, requests[i].Name, err)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,33 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errReadOnly = errors.New("blocked by read_only")
+
+// checkReadOnly refuses, with ReadOnly set, to call the named tool unless it
+// is annotated as read-only, recording the refusal as a failed call tagged
+// blocked=readonly without contacting the server. Tools that cannot be looked
+// up are refused too, as nothing tells they are safe to call.
+func (c *Client) checkReadOnly(name string, tags map[string]string) error {
+	if !c.readOnly {
+		return nil
+	}
+
+	var err error
+	if t, lookupErr := c.lookupTool(name); lookupErr != nil {
+		err = fmt.Errorf("tool %q cannot be checked: %w: %w", name, errReadOnly, lookupErr)
+	} else if !effectiveHints(t).readOnly {
+		err = fmt.Errorf("tool %q is not annotated as read-only: %w", name, errReadOnly)
+	} else {
+		return nil
+	}
+
+	c.metrics.PushWithTags(c.ctx, CallToolMethod, 0, err, withTags(tags, map[string]string{
+		"blocked": "readonly",
+	}))
+	c.stats.recordCall(err)
+	return err
+}
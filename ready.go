@@ -0,0 +1,76 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	defaultWaitForServerTimeout  = 30 * time.Second
+	defaultWaitForServerInterval = 500 * time.Millisecond
+)
+
+// WaitForServerOptions configures waitForServer.
+type WaitForServerOptions struct {
+	BaseURL string
+	Auth    AuthConfig
+	// Transport is sse or streamable-http, the default
+	Transport  string
+	TimeoutMs  int
+	IntervalMs int
+}
+
+// waitForServer connects to the server every opts.IntervalMs until a session
+// is initialized, and reports whether one was before opts.TimeoutMs elapsed.
+// It lets setup() wait for a server started alongside k6.
+func (m *MCPInstance) waitForServer(opts WaitForServerOptions) (bool, error) {
+	kind := opts.Transport
+	if kind == "" {
+		kind = streamableHTTPTransport
+	}
+	if kind == stdioTransport {
+		return false, errors.New("invalid options: waiting is only supported for the sse and streamable-http transports")
+	}
+	cfg := ClientConfig{BaseURL: opts.BaseURL, Auth: opts.Auth}
+	if err := ValidateConfig(cfg, kind); err != nil {
+		return false, err
+	}
+
+	timeout := defaultWaitForServerTimeout
+	if opts.TimeoutMs > 0 {
+		timeout = time.Duration(opts.TimeoutMs) * time.Millisecond
+	}
+	interval := defaultWaitForServerInterval
+	if opts.IntervalMs > 0 {
+		interval = time.Duration(opts.IntervalMs) * time.Millisecond
+	}
+
+	transport, isStateless, err := m.newTransport(cfg, kind, &clientStats{}, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	logger := m.sessionLogger(kind)
+
+	ctx, cancel := context.WithTimeout(m.getContext(), timeout)
+	defer cancel()
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		session, err := dial(ctx, logger, transport, isStateless, nil)
+		if err == nil {
+			_ = session.Close()
+			logger.WithField("attempts", attempt).WithField("duration", time.Since(start)).Debug("MCP server is ready")
+			return true, nil
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			logger.WithError(err).WithField("attempts", attempt).
+				Warnf("MCP server was not ready after %s", timeout)
+			return false, nil
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// callRecord is the line written to the RecordCalls file for a call.
+	callRecord struct {
+		Method     string  `json:"method"`
+		Params     any     `json:"params"`
+		Result     any     `json:"result"`
+		Error      *string `json:"error"`
+		DurationMs float64 `json:"durationMs"`
+		Ts         string  `json:"ts"`
+	}
+
+	// callRecorder appends call records to a file shared by every VU
+	// recording to the same path.
+	callRecorder struct {
+		path   string
+		logger logrus.FieldLogger
+
+		mu     sync.Mutex
+		w      *bufio.Writer
+		failed bool
+	}
+
+	callParamsKey struct{}
+)
+
+// callRecorder returns the recorder appending to path, opening the file the
+// first time it is asked for.
+func (r *RootModule) callRecorder(path string, logger logrus.FieldLogger) (*callRecorder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if recorder, ok := r.recorders[path]; ok {
+		return recorder, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open record_calls file: %w", err)
+	}
+	recorder := &callRecorder{
+		path:   path,
+		logger: logger.WithField("record_calls", path),
+		w:      bufio.NewWriter(file),
+	}
+	if r.recorders == nil {
+		r.recorders = make(map[string]*callRecorder)
+	}
+	r.recorders[path] = recorder
+
+	return recorder, nil
+}
+
+// flushRecordings writes out the call records buffered for every file, so
+// that they are complete once teardown returns.
+func (m *MCPInstance) flushRecordings() error {
+	m.root.mu.Lock()
+	defer m.root.mu.Unlock()
+
+	for _, recorder := range m.root.recorders {
+		if err := recorder.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *callRecorder) record(method string, params, result any, err error, duration time.Duration, start time.Time) {
+	rec := callRecord{
+		Method:     method,
+		Params:     params,
+		Result:     result,
+		DurationMs: float64(duration) / float64(time.Millisecond),
+		Ts:         start.UTC().Format(time.RFC3339Nano),
+	}
+	if err != nil {
+		msg := err.Error()
+		rec.Error = &msg
+	}
+	line, marshalErr := json.Marshal(rec)
+	if marshalErr != nil {
+		r.logger.WithError(marshalErr).Warn("Failed to encode a call record")
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.w.Write(append(line, '\n')); err != nil && !r.failed {
+		// The writer keeps failing after its first error, so it is only
+		// reported once.
+		r.failed = true
+		r.logger.WithError(err).Warn("Failed to record calls")
+	}
+}
+
+func (r *callRecorder) flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush the calls recorded to %s: %w", r.path, err)
+	}
+	return nil
+}
+
+// recordParams sets the params written to the call record of the call
+// running with ctx.
+func recordParams(ctx context.Context, params any) {
+	if slot, ok := ctx.Value(callParamsKey{}).(*any); ok {
+		*slot = params
+	}
+}
@@ -0,0 +1,204 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// InitializeMethod is the method of the handshake, sent again by Reinitialize.
+const InitializeMethod = "initialize"
+
+// initializedMethod is the notification ending the handshake.
+const initializedMethod = "notifications/initialized"
+
+// reinitializeID is the JSON-RPC ID of the requests sent by Reinitialize, which
+// bypass the SDK and so cannot collide with its IDs.
+const reinitializeID = "xk6-mcp-reinitialize"
+
+var errReinitializeUnsupported = errors.New("reinitialize is only supported by Streamable HTTP clients")
+
+// reinitializer sends the initialize handshake again over the HTTP client of
+// a Streamable HTTP transport, in the session the SDK opened. The SDK has no
+// API for it, and the handshake of other transports goes through connections
+// it does not expose.
+type reinitializer struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+// newReinitializer returns the reinitializer of sessions over transport, or
+// nil if they cannot be reinitialized.
+func newReinitializer(transport mcp.Transport) *reinitializer {
+	t, ok := transport.(*mcp.StreamableClientTransport)
+	if !ok {
+		return nil
+	}
+	return &reinitializer{endpoint: t.Endpoint, httpClient: t.HTTPClient}
+}
+
+// Reinitialize sends the initialize handshake again over the session of the
+// client, so that capabilities the server gained since are picked up, and
+// returns the new result. The request repeats the client info and
+// capabilities of the first handshake, and the session must keep its
+// protocol version.
+func (c *Client) Reinitialize() (*mcp.InitializeResult, error) {
+	if c.reinit == nil {
+		return nil, errReinitializeUnsupported
+	}
+
+	return call(c, InitializeMethod, func(ctx context.Context) (*mcp.InitializeResult, error) {
+		start := c.metrics.Now()
+		previous := c.initializeResult()
+		params := *c.session.initializeParams
+		params.ProtocolVersion = previous.ProtocolVersion
+		recordParams(ctx, &params)
+
+		res, err := c.reinit.send(ctx, c.session.ID(), &params)
+		if err != nil {
+			return nil, err
+		}
+		if res.ProtocolVersion != previous.ProtocolVersion {
+			return nil, fmt.Errorf("server cannot be reinitialized: it changed the protocol version from %s to %s",
+				previous.ProtocolVersion, res.ProtocolVersion)
+		}
+		if err := c.reinit.notifyInitialized(ctx, c.session.ID(), res.ProtocolVersion); err != nil {
+			return nil, err
+		}
+
+		c.initResult.Store(res)
+		c.metrics.PushReinitialize(c.ctx, c.metrics.Since(start))
+		return res, nil
+	})
+}
+
+// initializeResult returns the result of the last handshake of the session.
+func (c *Client) initializeResult() *mcp.InitializeResult {
+	if res := c.initResult.Load(); res != nil {
+		return res
+	}
+	return c.session.InitializeResult()
+}
+
+func (r *reinitializer) send(ctx context.Context, sessionID string, params *mcp.InitializeParams) (*mcp.InitializeResult, error) {
+	resp, err := r.post(ctx, sessionID, params.ProtocolVersion, map[string]any{
+		"jsonrpc": "2.0",
+		"id":      reinitializeID,
+		"method":  InitializeMethod,
+		"params":  params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server cannot be reinitialized over the existing transport: %s", resp.Status)
+	}
+
+	raw, err := readReinitializeResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	var msg struct {
+		Result *mcp.InitializeResult `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, fmt.Errorf("invalid initialize response: %w", err)
+	}
+	if msg.Error != nil {
+		return nil, fmt.Errorf("server cannot be reinitialized over the existing transport: %s (%d)", msg.Error.Message, msg.Error.Code)
+	}
+	if msg.Result == nil {
+		return nil, errors.New("invalid initialize response: no result")
+	}
+	return msg.Result, nil
+}
+
+// notifyInitialized ends the handshake sent again by send, as the SDK does
+// after the first one.
+func (r *reinitializer) notifyInitialized(ctx context.Context, sessionID, version string) error {
+	resp, err := r.post(ctx, sessionID, version, map[string]any{
+		"jsonrpc": "2.0",
+		"method":  initializedMethod,
+		"params":  &mcp.InitializedParams{},
+	})
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server cannot be reinitialized over the existing transport: %s sent with %s", initializedMethod, resp.Status)
+	}
+	return nil
+}
+
+// post sends the JSON-RPC message msg in the session over the HTTP client of
+// the transport.
+func (r *reinitializer) post(ctx context.Context, sessionID, version string, msg map[string]any) (*http.Response, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set("Mcp-Protocol-Version", version)
+	if sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", sessionID)
+	}
+	return r.httpClient.Do(req)
+}
+
+// readReinitializeResponse returns the response to the initialize request,
+// sent either as JSON or as an event of an SSE stream.
+func readReinitializeResponse(resp *http.Response) (json.RawMessage, error) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		return io.ReadAll(resp.Body)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 1<<20)
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) > 0 {
+			if value, ok := sseField(line, "data"); ok {
+				if len(data) > 0 {
+					data = append(data, '\n')
+				}
+				data = append(data, value...)
+			}
+			continue
+		}
+
+		var msg struct {
+			ID any `json:"id"`
+		}
+		if len(data) > 0 && json.Unmarshal(data, &msg) == nil && msg.ID == reinitializeID {
+			return data, nil
+		}
+		data = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("invalid initialize response: the stream ended without it")
+}
@@ -0,0 +1,249 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"strings"
+
+	"github.com/grafana/sobek"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+var errNoSink = errors.New("a sink is required: set discard to true")
+
+type (
+	// ReadResourceToOptions selects where ReadResourceTo sends the content of
+	// a resource. Discard drops it, only counting its bytes.
+	ReadResourceToOptions struct {
+		Discard bool
+	}
+
+	// ResourceReadSummary describes a resource read by ReadResourceTo, without
+	// its content.
+	ResourceReadSummary struct {
+		URI      string
+		Bytes    int
+		Contents []ResourceContentsSummary
+	}
+
+	// ResourceContentsSummary describes one of the contents of a resource.
+	ResourceContentsSummary struct {
+		URI      string
+		MIMEType string `js:"mime_type"`
+		Bytes    int
+	}
+)
+
+// ReadResourceTo reads the resource at uri into the sink selected by opts and
+// returns its size, so that large resources can be read without handing their
+// content to JS. Reads bypass the cache.
+func (c *Client) ReadResourceTo(uri string, opts ReadResourceToOptions) (*ResourceReadSummary, error) {
+	if !opts.Discard {
+		return nil, errNoSink
+	}
+
+	return callWithTags(c, ReadResourceMethod, map[string]string{"sink": "discard"}, func(ctx context.Context) (*ResourceReadSummary, error) {
+		params := &mcp.ReadResourceParams{URI: uri}
+		recordParams(ctx, params)
+		res, err := c.session.ReadResource(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+
+		summary := &ResourceReadSummary{URI: uri}
+		for _, contents := range res.Contents {
+			size := len(contents.Text) + len(contents.Blob)
+			summary.Bytes += size
+			summary.Contents = append(summary.Contents, ResourceContentsSummary{
+				URI:      contents.URI,
+				MIMEType: contents.MIMEType,
+				Bytes:    size,
+			})
+		}
+		return summary, nil
+	})
+}
+
+// ResourceContentsEntry is one of the contents of a resource, with its blob,
+// if any, as an ArrayBuffer.
+type ResourceContentsEntry struct {
+	URI      string
+	MIMEType string `js:"mime_type"`
+	Text     string
+	Blob     any
+}
+
+// ReadResourceAll reads the resource at uri, through the cache, and returns
+// every one of its contents, such as the files of a directory resource.
+func (c *Client) ReadResourceAll(uri string) ([]ResourceContentsEntry, error) {
+	res, err := c.readResource(mcp.ReadResourceParams{URI: uri}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ResourceContentsEntry, 0, len(res.Contents))
+	for _, contents := range res.Contents {
+		entries = append(entries, c.contentsEntry(contents))
+	}
+	return entries, nil
+}
+
+func (c *Client) contentsEntry(contents *mcp.ResourceContents) ResourceContentsEntry {
+	entry := ResourceContentsEntry{
+		URI:      contents.URI,
+		MIMEType: contents.MIMEType,
+		Text:     contents.Text,
+	}
+	if contents.Blob != nil {
+		entry.Blob = c.vu.Runtime().NewArrayBuffer(contents.Blob)
+	}
+	return entry
+}
+
+// matchesMIMEType reports whether mimeType matches pattern, which is either
+// empty, matching anything, a MIME type, or a type followed by /* matching
+// all its subtypes. Parameters such as charset are ignored.
+func matchesMIMEType(pattern, mimeType string) bool {
+	if pattern == "" {
+		return true
+	}
+	got, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(got, strings.ToLower(prefix)+"/")
+	}
+	want, _, err := mime.ParseMediaType(pattern)
+	return err == nil && want == got
+}
+
+var errPageInFlight = errors.New("the previous page is still being fetched")
+
+type (
+	// ResourcesIterator fetches the pages of resources/list one at a time,
+	// without blocking the VU.
+	ResourcesIterator struct {
+		c        *Client
+		params   mcp.ListResourcesParams
+		done     bool
+		fetching bool
+	}
+
+	// ResourcesPage is what the promises of ResourcesIterator.Next resolve
+	// to, shaped like the results of JS iterators.
+	ResourcesPage struct {
+		Value *mcp.ListResourcesResult
+		Done  bool
+	}
+)
+
+// ResourcesIterator returns an iterator over the pages of resources, starting
+// at the cursor of r, if any.
+func (c *Client) ResourcesIterator(r mcp.ListResourcesParams) *ResourcesIterator {
+	return &ResourcesIterator{c: c, params: r}
+}
+
+// Next returns a promise of the next page, which is done once the page
+// without a next cursor was returned. Pages must be awaited one at a time.
+func (it *ResourcesIterator) Next() *sobek.Promise {
+	c := it.c
+	promise, resolve, reject := c.vu.Runtime().NewPromise()
+	if it.done {
+		_ = resolve(ResourcesPage{Done: true})
+		return promise
+	}
+	if it.fetching {
+		_ = reject(errPageInFlight)
+		return promise
+	}
+
+	it.fetching = true
+	params := it.params
+	c.notifications.iteration.Store(c.iteration())
+	loop := c.eventLoop()
+	go func() {
+		res, err := awaitOnLoop(loop, func() (*mcp.ListResourcesResult, error) {
+			return invoke(c, ListResourcesMethod, "", nil, strictly(c, intercepting(c, func(ctx context.Context) (*mcp.ListResourcesResult, error) {
+				return c.listResources(ctx, &params)
+			})))
+		})
+		loop.settle(func() error {
+			it.fetching = false
+			if err != nil {
+				_ = reject(err)
+				return nil
+			}
+			it.params.Cursor = res.NextCursor
+			it.done = res.NextCursor == ""
+			_ = resolve(ResourcesPage{Value: res})
+			return nil
+		})
+	}()
+
+	return promise
+}
+
+type (
+	// FollowLinksResult is a tool result along with the resources its
+	// resource_link contents point at.
+	FollowLinksResult struct {
+		mcp.CallToolResult
+		ResolvedResources []ResolvedResource
+	}
+
+	// ResolvedResource holds the contents of the resource at URI, or the
+	// error reading it.
+	ResolvedResource struct {
+		URI      string
+		Contents []*mcp.ResourceContents
+		Error    string
+	}
+)
+
+// CallToolFollowLinks calls a tool, then reads every resource its result
+// links to, in order. Each read is a call of its own, with its own metrics. A
+// failed read is reported in its entry rather than failing the call, as the
+// tool result is still worth returning.
+func (c *Client) CallToolFollowLinks(r mcp.CallToolParams) (*FollowLinksResult, error) {
+	res, err := c.callTool(r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := []ResolvedResource{}
+	for _, content := range res.Content {
+		link, ok := content.(*mcp.ResourceLink)
+		if !ok {
+			continue
+		}
+		entry := ResolvedResource{URI: link.URI}
+		if read, err := c.readResource(mcp.ReadResourceParams{URI: link.URI}, nil); err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.Contents = read.Contents
+		}
+		resolved = append(resolved, entry)
+	}
+
+	return &FollowLinksResult{CallToolResult: *res, ResolvedResources: resolved}, nil
+}
+
+// CallToolResources calls a tool and returns the resources embedded in its
+// result, in order, as plain entries like those of ReadResourceAll. Other
+// contents, resource links included, are left out.
+func (c *Client) CallToolResources(r mcp.CallToolParams) ([]ResourceContentsEntry, error) {
+	res, err := c.callTool(r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []ResourceContentsEntry{}
+	for _, content := range res.Content {
+		if embedded, ok := content.(*mcp.EmbeddedResource); ok && embedded.Resource != nil {
+			entries = append(entries, c.contentsEntry(embedded.Resource))
+		}
+	}
+	return entries, nil
+}
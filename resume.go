@@ -0,0 +1,193 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/grafana/xk6-mcp/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// sseResumeRoundTripper keeps SSE event streams going across dropped
+// connections. The SDK ends the session as soon as its event stream ends, so
+// when the stream drops, the request is sent again with the Last-Event-ID of
+// the last event received, and the new stream is spliced into the old one.
+// Servers that do not number their events cannot be resumed from.
+type sseResumeRoundTripper struct {
+	next    http.RoundTripper
+	ctx     context.Context
+	metrics *metrics.K6Metrics
+	logger  logrus.FieldLogger
+}
+
+func (t *sseResumeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	resp.Body = &resumableStream{
+		t:      t,
+		req:    req,
+		body:   resp.Body,
+		reader: bufio.NewReader(resp.Body),
+	}
+	return resp, nil
+}
+
+// resumableStream is the body of an SSE event stream, which it reads event by
+// event so that it only ever hands complete events over before resuming.
+type resumableStream struct {
+	t   *sseResumeRoundTripper
+	req *http.Request
+
+	mu     sync.Mutex
+	body   io.ReadCloser
+	closed bool
+
+	// Only accessed by the reader.
+	reader      *bufio.Reader
+	pending     []byte
+	lastEventID string
+	// resuming is set from a resumption until the first event of the new
+	// stream, which is not resumed from again if it drops before that.
+	resuming bool
+}
+
+func (s *resumableStream) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		event, err := s.nextEvent()
+		if err != nil {
+			if s.resume(err) {
+				continue
+			}
+			return 0, err
+		}
+		s.pending = event
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// nextEvent reads the next event of the stream, with its blank line, and
+// records its ID. The endpoint event a resumed stream may start with is
+// skipped, as the SDK only expects it once.
+func (s *resumableStream) nextEvent() ([]byte, error) {
+	for {
+		var event []byte
+		var id, name []byte
+		for {
+			line, err := s.reader.ReadBytes('\n')
+			if err != nil {
+				return nil, err
+			}
+			event = append(event, line...)
+
+			line = bytes.TrimRight(line, "\r\n")
+			if len(line) == 0 {
+				break
+			}
+			if value, ok := sseField(line, "id"); ok {
+				id = value
+			}
+			if value, ok := sseField(line, "event"); ok {
+				name = value
+			}
+		}
+
+		if id != nil {
+			s.lastEventID = string(id)
+		}
+		wasResuming := s.resuming
+		s.resuming = false
+		if wasResuming && string(name) == "endpoint" {
+			continue
+		}
+		return event, nil
+	}
+}
+
+// sseField returns the value of line if it is the given field.
+func sseField(line []byte, field string) ([]byte, bool) {
+	value, ok := bytes.CutPrefix(line, []byte(field+":"))
+	if !ok {
+		return nil, false
+	}
+	return bytes.TrimPrefix(value, []byte(" ")), true
+}
+
+// resume reopens the stream after it ended with cause, unless it was closed,
+// and reports whether it did.
+func (s *resumableStream) resume(cause error) bool {
+	if s.isClosed() || s.req.Context().Err() != nil {
+		return false
+	}
+	logger := s.t.logger.WithError(cause)
+
+	if s.lastEventID == "" {
+		s.t.metrics.PushSSEGap(s.t.ctx, false)
+		logger.Warn("MCP SSE stream ended and the server does not number its events, events may have been missed")
+		return false
+	}
+
+	resp, err := s.reopen()
+	if err != nil {
+		s.t.metrics.PushSSEGap(s.t.ctx, false)
+		logger.WithField("last_event_id", s.lastEventID).WithField("resume_error", err).
+			Warn("MCP SSE stream ended and could not be resumed, events may have been missed")
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		_ = resp.Body.Close()
+		return false
+	}
+	_ = s.body.Close()
+	s.body = resp.Body
+	s.reader = bufio.NewReader(resp.Body)
+	s.resuming = true
+	s.t.metrics.PushSSEGap(s.t.ctx, true)
+
+	return true
+}
+
+// reopen requests the events that followed the last one received.
+func (s *resumableStream) reopen() (*http.Response, error) {
+	if s.resuming {
+		return nil, errors.New("the resumed stream ended before its first event")
+	}
+
+	req := s.req.Clone(s.req.Context())
+	req.Header.Set("Last-Event-ID", s.lastEventID)
+	resp, err := s.t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp, nil
+}
+
+func (s *resumableStream) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *resumableStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return s.body.Close()
+}
@@ -0,0 +1,26 @@
+package mcp
+
+import (
+	"context"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// rootsListMethod is the method of the requests servers send for the roots
+// of the client, which the SDK advertises and answers on its own.
+const rootsListMethod = "roots/list"
+
+// timeRootsList is a receiving middleware recording every roots/list request
+// of the server, and the time spent answering it, so that servers polling the
+// roots of the client show up in the metrics.
+func (c *Client) timeRootsList(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if method != rootsListMethod {
+			return next(ctx, method, req)
+		}
+		start := c.metrics.Now()
+		res, err := next(ctx, method, req)
+		c.metrics.PushRootsListServed(c.ctx, c.metrics.Since(start))
+		return res, err
+	}
+}
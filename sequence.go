@@ -0,0 +1,23 @@
+package mcp
+
+import (
+	"errors"
+
+	"github.com/grafana/sobek"
+)
+
+// MeasureSequence runs fn and records its total duration as the given
+// sequence, whether it returns or throws. The calls made by fn still record
+// their own metrics. It returns what fn returns.
+func (c *Client) MeasureSequence(sequence string, fn sobek.Value) (sobek.Value, error) {
+	callable, ok := sobek.AssertFunction(fn)
+	if !ok {
+		return nil, errors.New("measureSequence expects a function")
+	}
+
+	start := c.metrics.Now()
+	v, err := callable(sobek.Undefined())
+	c.metrics.PushSequence(c.ctx, sequence, c.metrics.Since(start))
+
+	return v, err
+}
@@ -0,0 +1,32 @@
+package mcp
+
+import (
+	"reflect"
+	"time"
+)
+
+// defaultServerDurationMetaKey is the _meta key of the processing duration
+// servers report in their results, in milliseconds.
+const defaultServerDurationMetaKey = "serverDurationMs"
+
+// pushServerDuration records the processing duration the server reported in
+// the _meta of res, if any, so that it can be told apart from the network
+// overhead included in the request duration.
+func (c *Client) pushServerDuration(method string, res any) {
+	if v := reflect.ValueOf(res); v.Kind() == reflect.Pointer && v.IsNil() {
+		return
+	}
+	r, ok := res.(interface{ GetMeta() map[string]any })
+	if !ok {
+		return
+	}
+	meta := r.GetMeta()
+	if meta == nil {
+		return
+	}
+	ms, ok := meta[c.serverDurationKey].(float64)
+	if !ok || ms < 0 {
+		return
+	}
+	c.metrics.PushServerProcessing(c.ctx, method, time.Duration(ms*float64(time.Millisecond)))
+}
@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// liveSession is an MCP session that keeps track of its connection.
+type liveSession struct {
+	*mcp.ClientSession
+	closed chan struct{}
+
+	logger  logrus.FieldLogger
+	start   time.Time
+	closing atomic.Bool
+
+	// initializeParams are the params of the initialize request the SDK
+	// sent, with the protocol version the client asked for
+	initializeParams *mcp.InitializeParams
+}
+
+// watchSession wraps session, marking it closed once its connection ends,
+// whether the server went away or the session was closed. Connections that
+// end without being closed are logged.
+func watchSession(session *mcp.ClientSession, logger logrus.FieldLogger) *liveSession {
+	s := &liveSession{
+		ClientSession: session,
+		closed:        make(chan struct{}),
+		logger:        logger,
+		start:         time.Now(),
+	}
+	go func() {
+		err := session.Wait()
+		if !s.closing.Load() {
+			logger := s.logger.WithField("duration", time.Since(s.start))
+			if err != nil {
+				logger = logger.WithError(err)
+			}
+			logger.Info("MCP session disconnected")
+		}
+		close(s.closed)
+	}()
+	return s
+}
+
+// Close closes the session and waits for its connection to end, so that it is
+// no longer reported as connected once Close returns.
+func (s *liveSession) Close() error {
+	s.closing.Store(true)
+	err := s.ClientSession.Close()
+	<-s.closed
+
+	logger := s.logger.WithField("duration", time.Since(s.start))
+	if err != nil {
+		logger = logger.WithError(err)
+	}
+	logger.Debug("MCP session closed")
+
+	return err
+}
+
+func (s *liveSession) connected() bool {
+	select {
+	case <-s.closed:
+		return false
+	default:
+		return true
+	}
+}
+
+// keepAlive pings the server every interval until the session ends, calling
+// onPing with the outcome of each ping.
+func (s *liveSession) keepAlive(interval time.Duration, onPing func(error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.closed:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				err := s.Ping(ctx, &mcp.PingParams{})
+				cancel()
+				onPing(err)
+			}
+		}
+	}()
+}
+
+// startKeepAlive pings the server over session every interval, if set,
+// recording each ping.
+func (c *Client) startKeepAlive(session *liveSession, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	session.keepAlive(interval, func(err error) {
+		c.metrics.PushKeepAlivePing(c.ctx, err)
+	})
+}
@@ -0,0 +1,219 @@
+package mcp_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k6metrics "go.k6.io/k6/metrics"
+)
+
+func TestSSENotificationLatency(t *testing.T) {
+	// The tool sends a notification, then keeps asking the client through
+	// sampling whether it arrived, and reports how long that took.
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName}, func(ctx context.Context, req *mcpsdk.CallToolRequest, _ MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		start := time.Now()
+		err := req.Session.NotifyProgress(ctx, &mcpsdk.ProgressNotificationParams{ProgressToken: "call", Progress: 1})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for time.Since(start) < 5*time.Second {
+			res, err := req.Session.CreateMessage(ctx, &mcpsdk.CreateMessageParams{
+				Messages: []*mcpsdk.SamplingMessage{{Role: "user", Content: &mcpsdk.TextContent{Text: "seen?"}}},
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			if text, ok := res.Content.(*mcpsdk.TextContent); ok && text.Text == "seen" {
+				return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{
+					&mcpsdk.TextContent{Text: strconv.FormatInt(time.Since(start).Milliseconds(), 10)},
+				}}, nil, nil
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return nil, nil, errors.New("notification was never delivered")
+	})
+	handler := mcpsdk.NewSSEHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, nil)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	// The SSE event stream stays open for as long as the client lives.
+	defer ts.CloseClientConnections()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.SSEClient({
+      base_url: "%s",
+      read_buffer_size: 512,
+      disable_compression: true,
+      on_create_message: () => ({
+        role: "assistant",
+        model: "test",
+        content: { type: "text", text: client.drainNotifications().length > 0 ? "seen" : "waiting" },
+      }),
+    });
+    client.callTool({name: "%s", arguments: {id: 1}}).content[0].text;`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	elapsed, err := strconv.Atoi(v.String())
+	require.NoError(t, err)
+	assert.Less(t, elapsed, 500, "notification took %dms to be delivered", elapsed)
+}
+
+// newDroppingSSEServer serves the legacy SSE transport by hand, dropping the
+// first event stream when it is pinged, before answering. When numbered,
+// events carry IDs and streams resume from the Last-Event-ID they are opened
+// with, which is sent to resumedFrom.
+func newDroppingSSEServer(numbered bool, resumedFrom chan<- string) *httptest.Server {
+	var (
+		events  = make(chan string, 10)
+		nextID  atomic.Int64
+		drop    = make(chan struct{})
+		dropped = make(chan struct{})
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		drop := drop
+		if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID == "" {
+			defer close(dropped)
+		} else {
+			resumedFrom <- lastEventID
+			drop = nil
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "event: endpoint\ndata: /message\n\n")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case event := <-events:
+				_, _ = fmt.Fprint(w, event)
+				w.(http.Flusher).Flush()
+			case <-drop:
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+	mux.HandleFunc("POST /message", func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		if msg.ID == nil {
+			return
+		}
+
+		result := `{}`
+		switch msg.Method {
+		case "initialize":
+			result = `{"protocolVersion":"2024-11-05","capabilities":{},"serverInfo":{"name":"test","version":"1.0.0"}}`
+		case "ping":
+			close(drop)
+			<-dropped
+		}
+		event := fmt.Sprintf("event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":%s,\"result\":%s}\n\n", msg.ID, result)
+		if numbered {
+			event = fmt.Sprintf("id: %d\n%s", nextID.Add(1), event)
+		}
+		events <- event
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestSSEStreamResumption(t *testing.T) {
+	gaps := func(tc *testCase) []string {
+		var resumed []string
+		for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+			for _, sample := range sampleContainer.GetSamples() {
+				if sample.Metric.Name == "mcp_sse_gaps" {
+					tag, _ := sample.Tags.Get("resumed")
+					resumed = append(resumed, tag)
+				}
+			}
+		}
+		return resumed
+	}
+
+	t.Run("numbered events", func(t *testing.T) {
+		resumedFrom := make(chan string, 1)
+		ts := newDroppingSSEServer(true, resumedFrom)
+		defer ts.Close()
+		defer ts.CloseClientConnections()
+
+		tc := setupTest(t)
+
+		v, err := tc.runtime.VU.Runtime().RunString(
+			fmt.Sprintf(`const client = mcp.SSEClient({base_url: "%s"});
+      client.ping();`, ts.URL),
+		)
+		require.NoError(t, err)
+		assert.True(t, v.ToBoolean())
+		assert.Equal(t, "1", <-resumedFrom)
+		assert.Equal(t, []string{"true"}, gaps(tc))
+	})
+
+	t.Run("unnumbered events", func(t *testing.T) {
+		ts := newDroppingSSEServer(false, nil)
+		defer ts.Close()
+
+		tc := setupTest(t)
+		hook := logtest.NewLocal(tc.logger)
+
+		v, err := tc.runtime.VU.Runtime().RunString(
+			fmt.Sprintf(`const client = mcp.SSEClient({base_url: "%s"});
+      client.ping();`, ts.URL),
+		)
+		require.NoError(t, err)
+		assert.False(t, v.ToBoolean())
+		assert.Equal(t, []string{"false"}, gaps(tc))
+
+		var warnings []string
+		for _, entry := range hook.AllEntries() {
+			if entry.Level == logrus.WarnLevel {
+				warnings = append(warnings, entry.Message)
+			}
+		}
+		assert.Contains(t, warnings, "MCP SSE stream ended and the server does not number its events, events may have been missed")
+	})
+}
+
+func TestSSEReinitializeUnsupported(t *testing.T) {
+	ts := newDroppingSSEServer(true, nil)
+	defer ts.Close()
+	defer ts.CloseClientConnections()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.SSEClient({base_url: "%s"});
+    client.reinitialize();`, ts.URL),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reinitialize is only supported by Streamable HTTP clients")
+}
@@ -0,0 +1,197 @@
+package mcp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/grafana/xk6-mcp/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// ClientStats are the totals accumulated by a client in the current scenario,
+// since it was created or last reset.
+type ClientStats struct {
+	Scenario string
+	Calls    int64
+	Errors   int64
+	// InjectedFaults counts the calls failed by fault_injection, which are
+	// not counted in Errors
+	InjectedFaults int64
+	Reconnections  int64
+	BytesSent      int64
+	BytesReceived  int64
+}
+
+// clientStats holds the counters behind ClientStats. They are updated from
+// concurrent calls and from the HTTP transport.
+type clientStats struct {
+	calls         atomic.Int64
+	errors        atomic.Int64
+	faults        atomic.Int64
+	streams       atomic.Int64
+	reconnections atomic.Int64
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+
+	// scenario is the scenario the counters belong to. It is only used on
+	// the VU goroutine.
+	scenario string
+}
+
+// TotalBytes are the HTTP bytes transferred by every client of every VU.
+type TotalBytes struct {
+	In  int64
+	Out int64
+}
+
+// trafficTotals holds the counters behind TotalBytes.
+type trafficTotals struct {
+	in  atomic.Int64
+	out atomic.Int64
+}
+
+// totalBytes returns the HTTP bytes sent and received so far by every client
+// of every VU, as also recorded in the total bytes metric.
+func (m *MCPInstance) totalBytes() TotalBytes {
+	return TotalBytes{
+		In:  m.root.traffic.in.Load(),
+		Out: m.root.traffic.out.Load(),
+	}
+}
+
+func (s *clientStats) recordCall(err error) {
+	s.calls.Add(1)
+	if err != nil {
+		s.errors.Add(1)
+	}
+}
+
+// recordInjectedFault records a call failed by an injected fault, without
+// counting it as an error of the server.
+func (s *clientStats) recordInjectedFault() {
+	s.calls.Add(1)
+	s.faults.Add(1)
+}
+
+// reset zeroes the counters. Streams are left alone, since they tell the
+// first event stream of the session from the reconnections.
+func (s *clientStats) reset() {
+	s.calls.Store(0)
+	s.errors.Store(0)
+	s.faults.Store(0)
+	s.reconnections.Store(0)
+	s.bytesSent.Store(0)
+	s.bytesReceived.Store(0)
+}
+
+// enterScenario resets the counters when the VU moved on to another scenario
+// since they were last looked at or updated, so that they only cover the
+// scenario running.
+func (s *clientStats) enterScenario(scenario string) {
+	if scenario != s.scenario {
+		s.reset()
+		s.scenario = scenario
+	}
+}
+
+// Stats returns the totals of calls, errors, event stream reconnections and
+// HTTP bytes transferred by the client in the current scenario. Reconnections
+// and bytes are only counted for SSE and Streamable HTTP clients.
+func (c *Client) Stats() ClientStats {
+	c.stats.enterScenario(c.scenario())
+	return ClientStats{
+		Scenario:       c.stats.scenario,
+		Calls:          c.stats.calls.Load(),
+		Errors:         c.stats.errors.Load(),
+		InjectedFaults: c.stats.faults.Load(),
+		Reconnections:  c.stats.reconnections.Load(),
+		BytesSent:      c.stats.bytesSent.Load(),
+		BytesReceived:  c.stats.bytesReceived.Load(),
+	}
+}
+
+// ResetStats zeroes the totals returned by Stats, for example at the start of
+// a phase of a scenario that must be summed up on its own.
+func (c *Client) ResetStats() {
+	c.stats.enterScenario(c.scenario())
+	c.stats.reset()
+}
+
+// statsRoundTripper counts the bytes sent and received over HTTP, and the
+// event streams opened after the first one as reconnections, which it logs.
+// Bytes are also added to the totals of the module, and recorded in the total
+// bytes metric, once per request and once per response body when it ends.
+type statsRoundTripper struct {
+	next    http.RoundTripper
+	stats   *clientStats
+	logger  logrus.FieldLogger
+	traffic *trafficTotals
+	ctx     context.Context
+	metrics *metrics.K6Metrics
+}
+
+func (t *statsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet && t.stats.streams.Add(1) > 1 {
+		t.stats.reconnections.Add(1)
+		t.logger.WithField("last_event_id", req.Header.Get("Last-Event-ID")).Debug("MCP event stream reconnecting")
+	}
+	if req.ContentLength > 0 {
+		t.stats.bytesSent.Add(req.ContentLength)
+		t.traffic.out.Add(req.ContentLength)
+		t.metrics.PushTotalBytes(t.ctx, "out", req.ContentLength)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &countingReadCloser{
+		ReadCloser: resp.Body,
+		n:          &t.stats.bytesReceived,
+		total:      &t.traffic.in,
+		done: func(read int64) {
+			t.metrics.PushTotalBytes(t.ctx, "in", read)
+		},
+	}
+
+	return resp, nil
+}
+
+// countingReadCloser counts the bytes read into n and total, and reports
+// them to done once the body is read through or closed.
+type countingReadCloser struct {
+	io.ReadCloser
+	n     *atomic.Int64
+	total *atomic.Int64
+	done  func(read int64)
+
+	read atomic.Int64
+	once sync.Once
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+	r.total.Add(int64(n))
+	r.read.Add(int64(n))
+	if err == io.EOF {
+		r.finish()
+	}
+	return n, err
+}
+
+func (r *countingReadCloser) Close() error {
+	r.finish()
+	return r.ReadCloser.Close()
+}
+
+func (r *countingReadCloser) finish() {
+	r.once.Do(func() {
+		if read := r.read.Load(); read > 0 {
+			r.done(read)
+		}
+	})
+}
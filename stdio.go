@@ -0,0 +1,205 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// notificationInitialized ends the handshake of a session.
+const notificationInitialized = "notifications/initialized"
+
+// terminateDuration is how long closing a piped connection waits for the
+// server to exit, before signalling it, as mcp.CommandTransport does.
+const terminateDuration = 5 * time.Second
+
+// commandTransport runs a stdio server like mcp.CommandTransport, but kills it
+// when its connection is closed before the handshake completed. Closing lets
+// the server exit on its own for up to 10 seconds, which a server that never
+// answered is unlikely to do, and which would hold up the failing connect.
+type commandTransport struct {
+	mcp.CommandTransport
+	// dumpFrames is the path of the file the raw bytes exchanged with the
+	// server are appended to, if set
+	dumpFrames string
+	// framing is how messages are delimited, newline by default
+	framing string
+}
+
+func (t *commandTransport) Connect(ctx context.Context) (mcp.Connection, error) {
+	var conn mcp.Connection
+	var err error
+	if t.dumpFrames != "" || t.framing == framingContentLength {
+		conn, err = t.connectPiped(ctx)
+	} else {
+		conn, err = t.CommandTransport.Connect(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &commandConn{Connection: conn, t: t}, nil
+}
+
+type commandConn struct {
+	mcp.Connection
+	t           *commandTransport
+	initialized atomic.Bool
+}
+
+func (c *commandConn) Write(ctx context.Context, msg jsonrpc.Message) error {
+	if err := c.Connection.Write(ctx, msg); err != nil {
+		return err
+	}
+	if req, ok := msg.(*jsonrpc.Request); ok && req.Method == notificationInitialized {
+		c.initialized.Store(true)
+	}
+	return nil
+}
+
+// Close kills the server if the handshake did not complete, so that closing
+// only has to reap it.
+func (c *commandConn) Close() error {
+	if !c.initialized.Load() {
+		_ = c.t.Command.Process.Kill()
+	}
+	return c.Connection.Close()
+}
+
+// connectPiped starts the command like mcp.CommandTransport, whose pipes
+// cannot be wrapped, with every frame copied to the dumpFrames file if set,
+// and messages framed as set by framing.
+func (t *commandTransport) connectPiped(ctx context.Context) (mcp.Connection, error) {
+	var file *os.File
+	if t.dumpFrames != "" {
+		var err error
+		file, err = os.OpenFile(t.dumpFrames, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open dump_frames file: %w", err)
+		}
+	}
+	closeFile := func() {
+		if file != nil {
+			_ = file.Close()
+		}
+	}
+	stdout, err := t.Command.StdoutPipe()
+	if err != nil {
+		closeFile()
+		return nil, err
+	}
+	stdin, err := t.Command.StdinPipe()
+	if err != nil {
+		closeFile()
+		return nil, err
+	}
+	if err := t.Command.Start(); err != nil {
+		closeFile()
+		return nil, err
+	}
+
+	pipe := &commandPipe{cmd: t.Command, stdout: stdout, stdin: stdin, dump: file}
+	if t.framing == framingContentLength {
+		return newContentLengthConn(pipe), nil
+	}
+	// The connection is closed by closing stdin, not stdout.
+	return (&mcp.IOTransport{Reader: io.NopCloser(pipe), Writer: pipe}).Connect(ctx)
+}
+
+// commandPipe talks to a command over its stdin and stdout. If dump is set,
+// every chunk read or written is appended to it as a header line, with the
+// time, the direction (> sent, < received) and the number of bytes, followed
+// by the bytes as is and a newline.
+type commandPipe struct {
+	cmd    *exec.Cmd
+	stdout io.Reader
+	stdin  io.WriteCloser
+
+	mu   sync.Mutex
+	dump *os.File
+}
+
+func (p *commandPipe) Read(b []byte) (int, error) {
+	n, err := p.stdout.Read(b)
+	if n > 0 {
+		p.record("<", b[:n])
+	}
+	return n, err
+}
+
+func (p *commandPipe) Write(b []byte) (int, error) {
+	n, err := p.stdin.Write(b)
+	if n > 0 {
+		p.record(">", b[:n])
+	}
+	return n, err
+}
+
+func (p *commandPipe) record(direction string, frame []byte) {
+	if p.dump == nil {
+		return
+	}
+	header := fmt.Sprintf("%s %s %d\n", time.Now().UTC().Format(time.RFC3339Nano), direction, len(frame))
+	record := make([]byte, 0, len(header)+len(frame)+1)
+	record = append(record, header...)
+	record = append(record, frame...)
+	record = append(record, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// A failing dump must not fail the session it is there to diagnose.
+	_, _ = p.dump.Write(record)
+}
+
+// Close closes stdin and waits for the command to exit, signalling it to
+// terminate and then killing it if it does not, as mcp.CommandTransport does.
+func (p *commandPipe) Close() error {
+	defer func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.dump != nil {
+			_ = p.dump.Close()
+		}
+	}()
+
+	if err := p.stdin.Close(); err != nil {
+		return fmt.Errorf("closing stdin: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- p.cmd.Wait()
+	}()
+	wait := func() (error, bool) {
+		select {
+		case err := <-exited:
+			return err, true
+		case <-time.After(terminateDuration):
+			return nil, false
+		}
+	}
+
+	if err, ok := wait(); ok {
+		return err
+	}
+	if p.cmd.Process.Signal(syscall.SIGTERM) == nil {
+		if err, ok := wait(); ok {
+			return err
+		}
+	}
+	if err := p.cmd.Process.Kill(); err != nil {
+		return err
+	}
+	if err, ok := wait(); ok {
+		return err
+	}
+	return errors.New("unresponsive subprocess")
+}
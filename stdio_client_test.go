@@ -0,0 +1,250 @@
+package mcp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k6lib "go.k6.io/k6/lib"
+)
+
+func TestStdioEnvFile(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(envFile, []byte("# server settings\nexport LOG_LEVEL=debug\n\nAPI_URL\n"), 0o600))
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`mcp.StdioClient({
+      path: "./server",
+      env_file: %q
+    });`, envFile),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 4: expected KEY=VALUE")
+}
+
+func TestStdioConnectFailureKillsServer(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "pid")
+	// The server answers the handshake with garbage, then ignores both the
+	// end of its input and SIGTERM.
+	server := filepath.Join(dir, "server")
+	script := fmt.Sprintf("#!/bin/sh\necho $$ > %s\ntrap '' TERM\necho garbage\nexec sleep 60\n", pidFile)
+	require.NoError(t, os.WriteFile(server, []byte(script), 0o700))
+
+	tc := setupTest(t)
+
+	start := time.Now()
+	_, err := tc.runtime.VU.Runtime().RunString(fmt.Sprintf(`mcp.StdioClient({path: %q});`, server))
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	raw, err := os.ReadFile(pidFile)
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	require.NoError(t, err)
+	// Signal 0 only succeeds for processes that still exist, zombies included.
+	assert.ErrorIs(t, syscall.Kill(pid, 0), syscall.ESRCH)
+}
+
+func TestStdioDumpFrames(t *testing.T) {
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "frames.log")
+	// The server answers the initialize request with a line that is not JSON.
+	server := filepath.Join(dir, "server")
+	require.NoError(t, os.WriteFile(server, []byte("#!/bin/sh\nread -r request\necho garbage\nexec sleep 60\n"), 0o700))
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(fmt.Sprintf(`mcp.StdioClient({path: %q, dump_frames: %q});`, server, dump))
+	require.Error(t, err)
+
+	raw, err := os.ReadFile(dump)
+	require.NoError(t, err)
+	lines := strings.Split(string(raw), "\n")
+	require.GreaterOrEqual(t, len(lines), 5)
+
+	sent := strings.Fields(lines[0])
+	require.Len(t, sent, 3)
+	_, err = time.Parse(time.RFC3339Nano, sent[0])
+	require.NoError(t, err)
+	assert.Equal(t, ">", sent[1])
+	assert.Equal(t, strconv.Itoa(len(lines[1])+1), sent[2])
+	assert.Contains(t, lines[1], `"method":"initialize"`)
+
+	assert.Equal(t, []string{"<", "8"}, strings.Fields(lines[3])[1:])
+	assert.Equal(t, "garbage", lines[4])
+}
+
+func TestStdioEnvPlaceholders(t *testing.T) {
+	tests := []struct {
+		name, value, want string
+	}{
+		{name: "VU", value: "${VU}", want: "7"},
+		{name: "ITER", value: "${ITER}", want: "3"},
+		{name: "SCENARIO", value: "${SCENARIO}", want: "load"},
+		{name: "MIXED", value: "vu-${VU}/iter-${ITER}", want: "vu-7/iter-3"},
+		{name: "UNKNOWN", value: "${HOST}", want: "${HOST}"},
+		{name: "ESCAPED", value: "$${VU}", want: "${VU}"},
+		{name: "DOLLARS", value: "$VU $$ {VU}", want: "$VU $$ {VU}"},
+		{name: "PLAIN", value: "no placeholders", want: "no placeholders"},
+		{name: "EMPTY", value: "", want: ""},
+	}
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "env")
+	// The server writes its environment out, then fails the handshake.
+	script := "#!/bin/sh\n"
+	env := map[string]string{}
+	for _, tt := range tests {
+		script += fmt.Sprintf("printf '%%s=%%s\\n' %s \"$%s\" >> %s\n", tt.name, tt.name, out)
+		env[tt.name] = tt.value
+	}
+	server := filepath.Join(dir, "server")
+	require.NoError(t, os.WriteFile(server, []byte(script), 0o700))
+	config, err := json.Marshal(map[string]any{"path": server, "env": env})
+	require.NoError(t, err)
+
+	tc := setupTest(t)
+	vu := tc.runtime.VU
+	vu.StateField.VUID = 7
+	vu.StateField.Iteration = 3
+	vu.CtxField = k6lib.WithScenarioState(vu.CtxField, &k6lib.ScenarioState{Name: "load"})
+
+	_, err = vu.Runtime().RunString(fmt.Sprintf(`mcp.StdioClient(%s);`, config))
+	require.Error(t, err)
+
+	raw, err := os.ReadFile(out)
+	require.NoError(t, err)
+	got := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(raw), "\n"), "\n") {
+		name, value, _ := strings.Cut(line, "=")
+		got[name] = value
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, got[tt.name], tt.name)
+	}
+}
+
+// framingServerEnv is set to the framing TestFramingServer serves.
+const framingServerEnv = "XK6_MCP_FRAMING_SERVER"
+
+func TestStdioFraming(t *testing.T) {
+	for _, framing := range []string{"newline", "content-length"} {
+		t.Run(framing, func(t *testing.T) {
+			tc := setupTest(t)
+
+			v, err := tc.runtime.VU.Runtime().RunString(fmt.Sprintf(`const client = mcp.StdioClient({
+      path: %q,
+      args: ["-test.run=^TestFramingServer$"],
+      env: { %s: %q },
+      framing: %q
+    });
+    const first = client.listTools().tools.map((t) => t.name);
+    const second = client.listTools().tools.map((t) => t.description);
+    client.close();
+    JSON.stringify({ first, second });`, os.Args[0], framingServerEnv, framing, framing))
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"first": ["echo"], "second": ["Echoes its input.\nSecond line."]}`, v.String())
+		})
+	}
+}
+
+// TestFramingServer is not a test but the server of TestStdioFraming, run in a
+// process of its own. It frames messages as framingServerEnv says, and sends
+// a notification in the same write as every tools/list response, so that the
+// client has to find the boundary between them.
+func TestFramingServer(*testing.T) {
+	framing := os.Getenv(framingServerEnv)
+	if framing == "" {
+		return
+	}
+
+	r := bufio.NewReader(os.Stdin)
+	frame := func(msg any) []byte {
+		body, _ := json.Marshal(msg)
+		if framing == "content-length" {
+			return append(fmt.Appendf(nil, "Content-Length: %d\r\n\r\n", len(body)), body...)
+		}
+		return append(body, '\n')
+	}
+	for {
+		body, err := readTestFrame(r, framing)
+		if err != nil {
+			os.Exit(0)
+		}
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				ProtocolVersion string `json:"protocolVersion"`
+			} `json:"params"`
+		}
+		if json.Unmarshal(body, &req) != nil || req.ID == nil {
+			continue
+		}
+
+		var out []byte
+		switch req.Method {
+		case "initialize":
+			out = frame(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": map[string]any{
+				"protocolVersion": req.Params.ProtocolVersion,
+				"capabilities":    map[string]any{"tools": map[string]any{}},
+				"serverInfo":      map[string]any{"name": "framing", "version": "1.0.0"},
+			}})
+		case "tools/list":
+			out = frame(map[string]any{"jsonrpc": "2.0", "method": "notifications/tools/list_changed"})
+			out = append(out, frame(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": map[string]any{
+				"tools": []any{map[string]any{
+					"name":        "echo",
+					"description": "Echoes its input.\nSecond line.",
+					"inputSchema": map[string]any{"type": "object"},
+				}},
+			}})...)
+		default:
+			out = frame(map[string]any{"jsonrpc": "2.0", "id": req.ID, "error": map[string]any{"code": -32601, "message": "method not found"}})
+		}
+		if _, err := os.Stdout.Write(out); err != nil {
+			os.Exit(1)
+		}
+	}
+}
+
+// readTestFrame reads the body of the next message framed as framing.
+func readTestFrame(r *bufio.Reader, framing string) ([]byte, error) {
+	if framing != "content-length" {
+		return r.ReadBytes('\n')
+	}
+	size := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Content-Length") {
+			size, _ = strconv.Atoi(strings.TrimSpace(value))
+		}
+	}
+	if size < 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	body := make([]byte, size)
+	_, err := io.ReadFull(r, body)
+	return bytes.TrimSpace(body), err
+}
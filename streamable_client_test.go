@@ -2,19 +2,31 @@ package mcp_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/jsonschema-go/jsonschema"
 	mcp "github.com/grafana/xk6-mcp"
 	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.k6.io/k6/js/modulestest"
@@ -26,6 +38,7 @@ type (
 	testCase struct {
 		runtime *modulestest.Runtime
 		samples chan k6metrics.SampleContainer
+		logger  *logrus.Logger
 	}
 
 	jsonRPCRequest struct {
@@ -67,11 +80,15 @@ func setupTest(t *testing.T) *testCase {
 	mod, ok := mcp.New().NewModuleInstance(vu).(*mcp.MCPInstance)
 	require.True(t, ok)
 	require.NoError(t, vu.RuntimeField.Set("mcp", mod.Exports().Named))
+	logger, ok := vu.InitEnvField.Logger.(*logrus.Logger)
+	require.True(t, ok)
 
 	rt.MoveToVUContext(state)
 
 	return &testCase{
 		runtime: rt,
+		samples: samples,
+		logger:  logger,
 	}
 }
 
@@ -159,6 +176,269 @@ func TestStreamableBearerAuth(t *testing.T) {
 	assert.Equal(t, jwtToken, observedToken)
 }
 
+func TestStreamableBasicAuth(t *testing.T) {
+	var observedUser, observedPassword string
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+		observedUser, observedPassword, _ = r.BasicAuth()
+		handler.ServeHTTP(w, r)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      auth: {
+        scheme: "basic",
+        username: "k6",
+        password: "secret"
+      }
+    });`, ts.URL),
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "k6", observedUser)
+	assert.Equal(t, "secret", observedPassword)
+}
+
+func TestStreamableAPIKeyAuth(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		auth   string
+		header string
+	}{
+		{name: "default header", auth: `{api_key: "key"}`, header: "X-API-Key"},
+		{name: "custom header", auth: `{scheme: "api_key", api_key: "key", api_key_header: "X-Custom-Key"}`, header: "X-Custom-Key"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var observedKey string
+			handler, err := streamableHandler(t)
+			require.NoError(t, err)
+
+			handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+				observedKey = r.Header.Get(tt.header)
+				handler.ServeHTTP(w, r)
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+			defer ts.Close()
+
+			tc := setupTest(t)
+
+			_, err = tc.runtime.VU.Runtime().RunString(
+				fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+          base_url: "%s",
+          auth: %s
+        });`, ts.URL, tt.auth),
+			)
+
+			assert.NoError(t, err)
+			assert.Equal(t, "key", observedKey)
+		})
+	}
+}
+
+func TestStreamableOAuth2ClientCredentials(t *testing.T) {
+	var observedToken string
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "issued", "token_type": "Bearer"}`))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		observedToken, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		handler.ServeHTTP(w, r)
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%[1]s",
+      auth: {
+        scheme: "oauth2_client_credentials",
+        client_id: "k6",
+        client_secret: "secret",
+        token_url: "%[1]s/token"
+      }
+    });`, ts.URL),
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "issued", observedToken)
+}
+
+func TestOAuth2EndpointParamsAndTokenType(t *testing.T) {
+	var observedAudience, observedAuthorization string
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		observedAudience = r.PostFormValue("audience")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "issued", "token_type": "Bearer"}`))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		observedAuthorization = r.Header.Get("Authorization")
+		handler.ServeHTTP(w, r)
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%[1]s",
+      auth: {
+        scheme: "oauth2_client_credentials",
+        client_id: "k6",
+        client_secret: "secret",
+        token_url: "%[1]s/token",
+        endpoint_params: { audience: "https://mcp.example.com" },
+        token_type: "DPoP"
+      }
+    });`, ts.URL),
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://mcp.example.com", observedAudience)
+	assert.Equal(t, "DPoP issued", observedAuthorization)
+}
+
+func TestOAuth2GrantedScopes(t *testing.T) {
+	var observedScope string
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		observedScope = r.PostFormValue("scope")
+		w.Header().Set("Content-Type", "application/json")
+		// Only part of the requested scopes are granted.
+		_, _ = w.Write([]byte(`{"access_token": "issued", "token_type": "Bearer", "scope": "tools:read"}`))
+	})
+	mux.HandleFunc("/unscoped/token", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// The requested scopes are granted as is.
+		_, _ = w.Write([]byte(`{"access_token": "issued", "token_type": "Bearer"}`))
+	})
+	mux.Handle("/", handler)
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%[1]s",
+      auth: {
+        scheme: "oauth2_client_credentials",
+        client_id: "k6",
+        client_secret: "secret",
+        token_url: "%[1]s/token",
+        scopes: ["tools:read", "tools:write"]
+      }
+    });
+    const unscoped = mcp.StreamableHTTPClient({
+      base_url: "%[1]s",
+      auth: {
+        scheme: "oauth2_client_credentials",
+        client_id: "k6",
+        client_secret: "secret",
+        token_url: "%[1]s/unscoped/token",
+        scopes: ["tools:read", "tools:write"]
+      }
+    });
+    const bearer = mcp.StreamableHTTPClient({
+      base_url: "%[1]s",
+      auth: { bearer_token: "static" }
+    });
+    const result = { granted: client.grantedScopes(), requested: unscoped.grantedScopes() };
+    try {
+      bearer.grantedScopes();
+    } catch (e) {
+      result.error = e.message;
+    }
+    JSON.stringify(result);`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "tools:read tools:write", observedScope)
+	assert.JSONEq(t, `{"granted": ["tools:read"], "requested": ["tools:read", "tools:write"], "error": "no OAuth2 token was issued to the client"}`, v.String())
+}
+
+func TestDirectAuthHeader(t *testing.T) {
+	for name, auth := range map[string]string{
+		"bearer": `{bearer_token: "static", direct_auth_header: true}`,
+		"oauth2_client_credentials": `{scheme: "oauth2_client_credentials", client_id: "k6", client_secret: "secret",
+        token_url: "%[1]s/token", direct_auth_header: true}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler, err := streamableHandler(t)
+			require.NoError(t, err)
+
+			var tokens atomic.Int32
+			var authorizations []string
+			mux := http.NewServeMux()
+			mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+				tokens.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"access_token": "issued", "token_type": "Bearer", "expires_in": 3600}`))
+			})
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				authorizations = append(authorizations, r.Header.Get("Authorization"))
+				handler.ServeHTTP(w, r)
+			})
+
+			ts := httptest.NewUnstartedServer(mux)
+			var conns atomic.Int32
+			ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					conns.Add(1)
+				}
+			}
+			ts.Start()
+			defer ts.Close()
+
+			tc := setupTest(t)
+
+			_, err = tc.runtime.VU.Runtime().RunString(
+				fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%[1]s",
+      stateless: true,
+      auth: `+auth+`
+    });
+    for (let i = 0; i < 3; i++) {
+      client.listTools();
+    }`, ts.URL),
+			)
+			require.NoError(t, err)
+
+			require.NotEmpty(t, authorizations)
+			for _, authorization := range authorizations {
+				assert.Contains(t, []string{"Bearer static", "Bearer issued"}, authorization)
+			}
+			assert.LessOrEqual(t, tokens.Load(), int32(1), "tokens must be reused until they expire")
+			assert.Equal(t, int32(1), conns.Load(), "the connection must be reused")
+		})
+	}
+}
+
 func TestListTools(t *testing.T) {
 	var listToolsCalled bool
 	handler, err := streamableHandler(t)
@@ -292,3 +572,4100 @@ func TestStatelessDisabled(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, standaloneSSEopened)
 }
+
+func TestReadResourceCache(t *testing.T) {
+	var reads int
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	server.AddResource(&mcpsdk.Resource{URI: "embedded:info", Name: "info"}, func(context.Context, *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+		reads++
+		return &mcpsdk.ReadResourceResult{
+			Contents: []*mcpsdk.ResourceContents{{URI: "embedded:info", Text: "info"}},
+		}, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      cache_reads: true,
+      cache_ttl: "1m"
+    });
+    const first = client.readResource({uri: "embedded:info"});
+    first.contents[0].text = "changed";
+    first.contents.push({uri: "embedded:other"});
+    const second = client.readResource({uri: "embedded:info"});
+    second.contents[0].text = "changed again";
+    const third = client.readResource({uri: "embedded:info"});
+    [second.contents.length, third.contents.length, third.contents[0].text].join(",");`, ts.URL),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, reads)
+	// Scripts modifying a result leave the cached one as read.
+	assert.Equal(t, "1,1,info", v.String())
+}
+
+func TestRequireCapabilities(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.requireCapabilities({tools: true});`, ts.URL),
+	)
+	assert.NoError(t, err)
+
+	_, err = tc.runtime.VU.Runtime().RunString(`client.requireCapabilities({tools: true, prompts: true});`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing required capabilities: prompts")
+}
+
+func TestCallToolBinaryArguments(t *testing.T) {
+	type imageInput struct {
+		Image string `json:"image"`
+	}
+
+	var (
+		decoded []byte
+		meta    mcpsdk.Meta
+	)
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "upload"}, func(_ context.Context, req *mcpsdk.CallToolRequest, in imageInput) (*mcpsdk.CallToolResult, any, error) {
+		var err error
+		decoded, err = base64.StdEncoding.DecodeString(in.Image)
+		meta = req.Params.Meta
+		return &mcpsdk.CallToolResult{}, nil, err
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.callTool({name: "upload", arguments: {image: new Uint8Array([1, 2, 3])}});`, ts.URL),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, decoded)
+	assert.Equal(t, []any{"/image"}, meta["xk6-mcp/base64Arguments"])
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var toolCalls int
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			jsonReq, err := parseJSONRPCBody(r)
+			require.NoError(t, err)
+
+			if jsonReq.Method == "tools/call" {
+				toolCalls++
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      circuit_breaker: {
+        failure_threshold: 2,
+        open_duration: "1m"
+      }
+    });
+    let lastError;
+    for (let i = 0; i < 3; i++) {
+      try {
+        client.callTool({name: "%s", arguments: {id: 1}});
+      } catch (e) {
+        lastError = String(e);
+      }
+    }
+    lastError;`, ts.URL, toolName+"bad"),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, toolCalls)
+	assert.Contains(t, v.String(), "circuit breaker is open")
+
+	// The rejected call is kept out of the request durations.
+	var durations, rejected int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			switch sample.Metric.Name {
+			case "mcp_request_duration":
+				if method, _ := sample.Tags.Get("method"); method == mcp.CallToolMethod {
+					durations++
+				}
+			case "mcp_circuit_rejected":
+				rejected++
+			}
+		}
+	}
+	assert.Equal(t, 2, durations)
+	assert.Equal(t, 1, rejected)
+}
+
+func TestCircuitBreakerLateOutcome(t *testing.T) {
+	failed := make(chan struct{})
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "work"}, func(_ context.Context, _ *mcpsdk.CallToolRequest, in struct {
+		Fail bool `json:"fail"`
+	}) (*mcpsdk.CallToolResult, any, error) {
+		if in.Fail {
+			close(failed)
+			return nil, nil, errors.New("boom")
+		}
+		// Succeed once the failure has opened the breaker.
+		<-failed
+		time.Sleep(100 * time.Millisecond)
+		return &mcpsdk.CallToolResult{}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      throw_on_tool_error: true,
+      circuit_breaker: {failure_threshold: 1, open_duration: "1m"}
+    });
+    client.callToolMatrix("work", {fail: [true, false]}, {concurrency: 2});
+    let error = "";
+    try {
+      client.callTool({name: "work", arguments: {fail: false}});
+    } catch (e) {
+      error = String(e);
+    }
+    error;`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.Contains(t, v.String(), "circuit breaker is open", "a call let through before the breaker opened must not close it")
+}
+
+func TestReadOnlyTools(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	noop := func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		return &mcpsdk.CallToolResult{}, nil, nil
+	}
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "read", Annotations: &mcpsdk.ToolAnnotations{ReadOnlyHint: true}}, noop)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "delete"}, noop)
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const readOnly = client.readOnlyTools().tools.map(t => t.name).join(",");
+    const destructive = client.listAllTools({annotation_filter: {destructive_hint: true}}).tools.map(t => t.name).join(",");
+    readOnly + "|" + destructive;`, ts.URL),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, "read|delete", v.String())
+}
+
+func TestAssertToolAnnotations(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	noop := func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		return &mcpsdk.CallToolResult{}, nil, nil
+	}
+	notDestructive := false
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "delete"}, noop)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "purge", Annotations: &mcpsdk.ToolAnnotations{
+		Title:           "Purge",
+		DestructiveHint: &notDestructive,
+	}}, noop)
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.assertToolAnnotations("delete", {destructive_hint: true, read_only_hint: false});`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		`client.assertToolAnnotations("purge", {title: "Purge everything", destructive_hint: true, open_world_hint: true});`,
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(),
+		`tool "purge" has unexpected annotations: title is "Purge", expected "Purge everything"; destructiveHint is false, expected true`)
+	assert.NotContains(t, err.Error(), "openWorldHint")
+
+	_, err = tc.runtime.VU.Runtime().RunString(`client.assertToolAnnotations("missing", {});`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `tool "missing" not found`)
+
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			assert.NotEqual(t, "mcp_request_duration", sample.Metric.Name, "conformance checks must not record load metrics")
+		}
+	}
+}
+
+func TestSessionPool(t *testing.T) {
+	var initializeCalls int
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			jsonReq, err := parseJSONRPCBody(r)
+			require.NoError(t, err)
+
+			if jsonReq.Method == "initialize" {
+				initializeCalls++
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const pool = new mcp.SessionPool({
+      transport: "streamable-http",
+      size: 1,
+      client: {
+        base_url: "%s",
+        stateless: true
+      }
+    });
+    const first = pool.acquire();
+    first.listTools();
+    first.release();
+    const second = pool.acquire();
+    second.listTools();`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 1, initializeCalls)
+
+	_, err = tc.runtime.VU.Runtime().RunString(`first.listTools();`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "released")
+}
+
+func TestSessionPoolHandlers(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName}, func(ctx context.Context, req *mcpsdk.CallToolRequest, _ MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		res, err := req.Session.CreateMessage(ctx, &mcpsdk.CreateMessageParams{
+			Messages: []*mcpsdk.SamplingMessage{{Role: "user", Content: &mcpsdk.TextContent{Text: "who?"}}},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{res.Content}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, nil)
+
+	var initializeCalls, deleteCalls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			if jsonReq, err := parseJSONRPCBody(r); err == nil && jsonReq.Method == "initialize" {
+				initializeCalls.Add(1)
+			}
+		case http.MethodDelete:
+			deleteCalls.Add(1)
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	defer ts.CloseClientConnections()
+
+	tc := setupTest(t)
+
+	// Two handles on the same pool stand for two VUs, each with its own
+	// handler.
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const newPool = (name) => new mcp.SessionPool({
+      name: "shared",
+      transport: "streamable-http",
+      size: 1,
+      client: {
+        base_url: "%s",
+        on_create_message: () => ({ role: "assistant", model: "test", content: { type: "text", text: name } })
+      }
+    });
+    const a = newPool("a"), b = newPool("b");
+    const call = (client) => client.callTool({name: "%s", arguments: {id: 1}}).content[0].text;
+    const first = a.acquire();
+    const fromA = call(first);
+    first.release();
+    const second = b.acquire();
+    [fromA, call(second)].join(",");`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "a,b", v.String())
+	assert.Equal(t, int32(1), initializeCalls.Load())
+
+	// Once the VU stops, its session goes back to the pool, which closes it
+	// as no VU is running anymore.
+	tc.runtime.CancelContext()
+	assert.Eventually(t, func() bool { return deleteCalls.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSessionPoolIterations(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	var initializeCalls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if jsonReq, err := parseJSONRPCBody(r); err == nil && jsonReq.Method == "initialize" {
+				initializeCalls.Add(1)
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	// k6 runs each iteration with a VU context of its own, canceled once the
+	// iteration ends.
+	iterate := func() context.CancelFunc {
+		ctx, cancel := context.WithCancel(context.Background())
+		tc.runtime.VU.CtxField = ctx
+		return cancel
+	}
+
+	end := iterate()
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const pool = new mcp.SessionPool({
+      transport: "streamable-http",
+      size: 1,
+      client: {
+        base_url: "%s",
+        stateless: true
+      }
+    });
+    let client = pool.acquire();
+    client.listTools();`, ts.URL),
+	)
+	require.NoError(t, err)
+	end()
+
+	// The session goes back to the pool at the end of the iteration, without
+	// being released, and stays open for the next one.
+	require.Eventually(t, func() bool {
+		v, err := tc.runtime.VU.Runtime().RunString(`client.isConnected()`)
+		return err == nil && !v.ToBoolean()
+	}, time.Second, 10*time.Millisecond)
+
+	end = iterate()
+	defer end()
+	_, err = tc.runtime.VU.Runtime().RunString(`client = pool.acquire(); client.listTools();`)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), initializeCalls.Load())
+}
+
+func TestCompressRequests(t *testing.T) {
+	var compressedRequests int
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			require.NoError(t, err)
+			r.Body = io.NopCloser(zr)
+			r.Header.Del("Content-Encoding")
+			r.ContentLength = -1
+			compressedRequests++
+		}
+		w.Header().Set("Accept-Encoding", "gzip")
+		handler.ServeHTTP(w, r)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      compress_requests: true
+    });
+    client.callTool({name: "%s", arguments: {id: 1}});`, ts.URL, toolName),
+	)
+
+	require.NoError(t, err)
+	assert.Greater(t, compressedRequests, 0)
+}
+
+func TestListAllPromptsHasArgument(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	prompts := map[string][]string{
+		"greet":     {"name"},
+		"summarize": {"language"},
+		"translate": {"text", "language"},
+		"joke":      nil,
+	}
+	for name, args := range prompts {
+		prompt := &mcpsdk.Prompt{Name: name}
+		for _, arg := range args {
+			prompt.Arguments = append(prompt.Arguments, &mcpsdk.PromptArgument{Name: arg})
+		}
+		server.AddPrompt(prompt, func(context.Context, *mcpsdk.GetPromptRequest) (*mcpsdk.GetPromptResult, error) {
+			return &mcpsdk.GetPromptResult{}, nil
+		})
+	}
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const names = (arg) => client.listAllPrompts({has_argument: arg}).prompts.map((p) => p.name).sort().join(",");
+    [names("language"), names("name"), names("missing"), names("")].join("|");`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "summarize,translate|greet||greet,joke,summarize,translate", v.String())
+}
+
+func TestGetPromptResolveResources(t *testing.T) {
+	var reads int
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	server.AddResource(&mcpsdk.Resource{URI: "embedded:info", Name: "info"}, func(context.Context, *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+		reads++
+		return &mcpsdk.ReadResourceResult{
+			Contents: []*mcpsdk.ResourceContents{{URI: "embedded:info", Text: "info"}},
+		}, nil
+	})
+	server.AddPrompt(&mcpsdk.Prompt{Name: "greet"}, func(context.Context, *mcpsdk.GetPromptRequest) (*mcpsdk.GetPromptResult, error) {
+		return &mcpsdk.GetPromptResult{
+			Messages: []*mcpsdk.PromptMessage{
+				{Role: "user", Content: &mcpsdk.TextContent{Text: "hello"}},
+				{Role: "user", Content: &mcpsdk.ResourceLink{URI: "embedded:info", Name: "info"}},
+			},
+		}, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const unresolved = client.getPrompt({name: "greet"});
+    if (unresolved.messages[1].content.uri !== "embedded:info") {
+      throw new Error("resource link was resolved");
+    }
+    const resolved = client.getPrompt({name: "greet", resolve_resources: true});
+    if (resolved.messages[1].content.resource.text !== "info") {
+      throw new Error("resource link was not resolved");
+    }`, ts.URL),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, reads)
+}
+
+func TestIsConnected(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, nil)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	connected, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s"
+    });
+    client.isConnected();`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.True(t, connected.ToBoolean())
+
+	for session := range server.Sessions() {
+		require.NoError(t, session.Close())
+	}
+
+	// The client learns that its session is gone from the next call.
+	_, err = tc.runtime.VU.Runtime().RunString(`client.listTools();`)
+	require.Error(t, err)
+
+	assert.Eventually(t, func() bool {
+		connected, err := tc.runtime.VU.Runtime().RunString(`client.isConnected();`)
+		return err == nil && !connected.ToBoolean()
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func TestGetPrompts(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	for _, name := range []string{"formal", "casual"} {
+		server.AddPrompt(&mcpsdk.Prompt{Name: name}, func(_ context.Context, req *mcpsdk.GetPromptRequest) (*mcpsdk.GetPromptResult, error) {
+			return &mcpsdk.GetPromptResult{
+				Description: name + " " + req.Params.Arguments["who"],
+			}, nil
+		})
+	}
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	descriptions, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.getPrompts([
+      {name: "formal", args: {who: "a"}},
+      {name: "casual", args: {who: "b"}},
+      {name: "formal", args: {who: "c"}},
+    ], {concurrency: 2}).map((p) => p.description).join(",");`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "formal a,casual b,formal c", descriptions.String())
+
+	prompts := map[string]int{}
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if method, _ := sample.Tags.Get("method"); sample.Metric.Name == "mcp_request_count" && method == mcp.GetPromptMethod {
+				prompt, _ := sample.Tags.Get("prompt")
+				prompts[prompt]++
+			}
+		}
+	}
+	assert.Equal(t, map[string]int{"formal": 2, "casual": 1}, prompts)
+
+	_, err = tc.runtime.VU.Runtime().RunString(`client.getPrompts([{name: "missing"}]);`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `failed to get prompt 0 ("missing")`)
+}
+
+func TestDeduplicate(t *testing.T) {
+	var gets atomic.Int32
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	server.AddPrompt(&mcpsdk.Prompt{Name: "greet"}, func(_ context.Context, req *mcpsdk.GetPromptRequest) (*mcpsdk.GetPromptResult, error) {
+		gets.Add(1)
+		// Long enough for the identical calls to join this one.
+		time.Sleep(200 * time.Millisecond)
+		return &mcpsdk.GetPromptResult{Description: "hello " + req.Params.Arguments["who"]}, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	descriptions, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      deduplicate: true
+    });
+    client.getPrompts([
+      {name: "greet", args: {who: "a"}},
+      {name: "greet", args: {who: "a"}},
+      {name: "greet", args: {who: "a"}},
+    ], {concurrency: 3}).map((p) => p.description).join(",");`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "hello a,hello a,hello a", descriptions.String())
+	assert.Equal(t, int32(1), gets.Load())
+
+	var requests, deduplicated, taggedRequests int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if method, _ := sample.Tags.Get("method"); method != mcp.GetPromptMethod {
+				continue
+			}
+			switch sample.Metric.Name {
+			case "mcp_request_count":
+				requests++
+				if tag, _ := sample.Tags.Get("deduplicated"); tag == "true" {
+					taggedRequests++
+				}
+			case "mcp_deduplicated":
+				deduplicated++
+			}
+		}
+	}
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, 2, deduplicated)
+	assert.Equal(t, 2, taggedRequests)
+}
+
+func TestMiddlewares(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      middlewares: ["request_logging", "latency_injection"],
+      middleware_options: {
+        latency_injection: {rate: 1, latency: "1ms"}
+      }
+    });
+    client.callTool({name: "%s", arguments: {id: 1}});`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      middlewares: ["fault_injection"],
+      middleware_options: {
+        fault_injection: {rate: 1}
+      }
+    }).callTool({name: "%s", arguments: {id: 1}});`, ts.URL, toolName),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "injected fault")
+}
+
+func TestFaultInjection(t *testing.T) {
+	var toolCalls int
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+		if jsonReq, err := parseJSONRPCBody(r); err == nil && jsonReq.Method == mcp.CallToolMethod {
+			toolCalls++
+		}
+		handler.ServeHTTP(w, r)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	// Injected faults don't open the breaker, nor count as errors.
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      fault_injection: {error_rate: 1, latency_rate: 1, latency_ms: 1},
+      circuit_breaker: {failure_threshold: 1, open_duration: "1m"}
+    });
+    const errors = [];
+    for (let i = 0; i < 2; i++) {
+      try {
+        client.callTool({name: "%s", arguments: {id: 1}});
+      } catch (e) {
+        errors.push(String(e));
+      }
+    }
+    const stats = client.stats();
+    JSON.stringify({errors, calls: stats.calls, failed: stats.errors, injected: stats.injected_faults});`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+	var result struct {
+		Errors   []string
+		Calls    int
+		Failed   int
+		Injected int
+	}
+	require.NoError(t, json.Unmarshal([]byte(v.String()), &result))
+	require.Len(t, result.Errors, 2)
+	for _, e := range result.Errors {
+		assert.Contains(t, e, "injected fault")
+	}
+	assert.Equal(t, 2, result.Calls)
+	assert.Zero(t, result.Failed)
+	assert.Equal(t, 2, result.Injected)
+	assert.Zero(t, toolCalls)
+
+	var injected int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if value, _ := sample.Tags.Get("injected"); sample.Metric.Name == "mcp_request_errors" && value == "true" {
+				injected++
+			}
+		}
+	}
+	assert.Equal(t, 2, injected)
+}
+
+func TestDrainNotifications(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	inputSchema, err := jsonschema.For[MyToolInput](nil)
+	require.NoError(t, err)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName, InputSchema: inputSchema},
+		func(ctx context.Context, req *mcpsdk.CallToolRequest, _ MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+			for i := range 3 {
+				err := req.Session.NotifyProgress(ctx, &mcpsdk.ProgressNotificationParams{
+					ProgressToken: "call",
+					Progress:      float64(i),
+				})
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			return nil, MyToolOutput{toolName}, nil
+		})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, nil)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	// The standalone event stream stays open for as long as the client lives.
+	defer ts.CloseClientConnections()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      notification_buffer_size: 2
+    });
+    client.callTool({name: "%s", arguments: {id: 1}});`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	// Notification handlers run asynchronously, so the last ones may land
+	// after the call returned.
+	var progress []float64
+	require.Eventually(t, func() bool {
+		v, err := tc.runtime.VU.Runtime().RunString(`client.drainNotifications().map((n) => n.params.progress);`)
+		require.NoError(t, err)
+		var drained []float64
+		require.NoError(t, tc.runtime.VU.Runtime().ExportTo(v, &drained))
+		progress = append(progress, drained...)
+		return len(progress) > 0 && progress[len(progress)-1] == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.IsIncreasing(t, progress, "notifications must not be drained twice")
+}
+
+func TestNotificationsDropped(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName}, func(ctx context.Context, req *mcpsdk.CallToolRequest, _ MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		for i := range 5 {
+			err := req.Session.NotifyProgress(ctx, &mcpsdk.ProgressNotificationParams{ProgressToken: "call", Progress: float64(i)})
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return &mcpsdk.CallToolResult{}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, nil)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	// The standalone event stream stays open for as long as the client lives.
+	defer ts.CloseClientConnections()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      notification_buffer_size: 2
+    });
+    client.callTool({name: "%s", arguments: {id: 1}});`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	// Five notifications go through a buffer of two, without a drain.
+	dropped := 0
+	require.Eventually(t, func() bool {
+		for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+			for _, sample := range sampleContainer.GetSamples() {
+				if method, _ := sample.Tags.Get("method"); sample.Metric.Name == "mcp_notifications_dropped" && method == "notifications/progress" {
+					dropped++
+				}
+			}
+		}
+		return dropped == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	v, err := tc.runtime.VU.Runtime().RunString(`client.drainNotifications().map((n) => n.params.progress);`)
+	require.NoError(t, err)
+	var progress []float64
+	require.NoError(t, tc.runtime.VU.Runtime().ExportTo(v, &progress))
+	assert.Equal(t, []float64{3, 4}, progress)
+}
+
+func TestTracing(t *testing.T) {
+	var traceparent string
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+		if jsonReq, err := parseJSONRPCBody(r); err == nil && jsonReq.Method == mcp.CallToolMethod {
+			traceparent = r.Header.Get("traceparent")
+		}
+		handler.ServeHTTP(w, r)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      tracing: true
+    });
+    client.callTool({name: "%s", arguments: {id: 1}});`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	parts := strings.Split(traceparent, "-")
+	require.Len(t, parts, 4, "unexpected traceparent %q", traceparent)
+
+	var traceIDs []string
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if method, _ := sample.Tags.Get("method"); sample.Metric.Name == "mcp_request_duration" && method == mcp.CallToolMethod {
+				traceIDs = append(traceIDs, sample.Metadata["trace_id"])
+			}
+		}
+	}
+	assert.Equal(t, []string{parts[1]}, traceIDs)
+}
+
+func TestTracingBaggage(t *testing.T) {
+	var baggage []string
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+		baggage = append(baggage, r.Header.Get("baggage"))
+		handler.ServeHTTP(w, r)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      tracing: true,
+      baggage: {test_run_id: "run 42", team: "mcp"}
+    });
+    client.callTool({name: "%s", arguments: {id: 1}});`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+	require.NotEmpty(t, baggage)
+	for _, b := range baggage {
+		assert.Equal(t, "team=mcp,test_run_id=run%2042", b)
+	}
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`mcp.StreamableHTTPClient({base_url: "%s", baggage: {"test run": "42"}});`, ts.URL),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "baggage requires tracing")
+	assert.Contains(t, err.Error(), `invalid baggage key "test run"`)
+}
+
+func TestCorrelationID(t *testing.T) {
+	var ids, baggage []string
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+		if jsonReq, err := parseJSONRPCBody(r); err == nil && jsonReq.Method == mcp.CallToolMethod {
+			ids = append(ids, r.Header.Get("X-Correlation-ID"))
+			baggage = append(baggage, r.Header.Get("baggage"))
+		} else {
+			assert.Empty(t, r.Header.Get("X-Correlation-ID"))
+		}
+		handler.ServeHTTP(w, r)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      tracing: true,
+      baggage: {team: "mcp"},
+      correlation_id_header: "X-Correlation-ID"
+    });
+    client.callTool({name: "%s", arguments: {id: 1}});
+    client.callTool({name: "%s", arguments: {id: 2}});`, ts.URL, toolName, toolName),
+	)
+	require.NoError(t, err)
+	require.Len(t, ids, 2)
+	assert.NotEqual(t, ids[0], ids[1])
+	for i, id := range ids {
+		assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id)
+		assert.Equal(t, "team=mcp,correlation_id="+id, baggage[i])
+	}
+
+	var metadata []string
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			_, tagged := sample.Tags.Get("correlation_id")
+			assert.False(t, tagged, "correlation_id must not be a tag")
+			if method, _ := sample.Tags.Get("method"); sample.Metric.Name == "mcp_request_duration" && method == mcp.CallToolMethod {
+				metadata = append(metadata, sample.Metadata["correlation_id"])
+			}
+		}
+	}
+	assert.Equal(t, ids, metadata)
+}
+
+func TestCompareClients(t *testing.T) {
+	newServer := func(output string) *httptest.Server {
+		server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+		mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+			return nil, MyToolOutput{output}, nil
+		})
+		return httptest.NewServer(mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+			return server
+		}, &mcpsdk.StreamableHTTPOptions{Stateless: true}))
+	}
+	old, same, changed := newServer("v1"), newServer("v1"), newServer("v2")
+	defer old.Close()
+	defer same.Close()
+	defer changed.Close()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const old = mcp.StreamableHTTPClient({base_url: "%s", stateless: true});
+    const same = mcp.StreamableHTTPClient({base_url: "%s", stateless: true});
+    const changed = mcp.StreamableHTTPClient({base_url: "%s", stateless: true});
+    const params = {name: "%s", arguments: {id: 1}};
+
+    const parity = mcp.CompareClients(old, same).compareTool(params);
+    if (!parity.equal || parity.diff.length !== 0) {
+      throw new Error("expected equal results: " + JSON.stringify(parity.diff));
+    }
+
+    const drift = mcp.CompareClients(old, changed).compareTool(params);
+    if (drift.equal) {
+      throw new Error("expected different results");
+    }
+    const paths = drift.diff.map((d) => d.path).join(",");
+    if (paths !== "/content/0/text,/structuredContent/output") {
+      throw new Error("unexpected diff: " + JSON.stringify(drift.diff));
+    }`, old.URL, same.URL, changed.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	servers := map[string]int{}
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if server, ok := sample.Tags.Get("server"); ok && sample.Metric.Name == "mcp_request_count" {
+				servers[server]++
+			}
+		}
+	}
+	assert.Equal(t, map[string]int{"a": 2, "b": 2}, servers)
+}
+
+func TestKeepAlivePing(t *testing.T) {
+	var pings atomic.Int32
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, nil)
+
+	handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+		if jsonReq, err := parseJSONRPCBody(r); err == nil && jsonReq.Method == "ping" {
+			pings.Add(1)
+		}
+		handler.ServeHTTP(w, r)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      keep_alive_ping_interval: "10ms"
+    });`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return pings.Load() >= 2 }, 5*time.Second, 5*time.Millisecond)
+
+	connected, err := tc.runtime.VU.Runtime().RunString(`client.close(); client.isConnected();`)
+	require.NoError(t, err)
+	assert.False(t, connected.ToBoolean())
+
+	afterClose := pings.Load()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, afterClose, pings.Load(), "pings continued after close")
+
+	var recorded int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if status, _ := sample.Tags.Get("status"); sample.Metric.Name == "mcp_keepalive_pings" && status == "ok" {
+				recorded++
+			}
+		}
+	}
+	assert.GreaterOrEqual(t, recorded, 2)
+}
+
+func TestClientStats(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.callTool({name: "%s", arguments: {id: 1}});
+    client.callTool({name: "%s", arguments: {id: 2}});
+    try {
+      client.callTool({name: "missing", arguments: {}});
+    } catch (e) {}
+    client.stats();`, ts.URL, toolName, toolName),
+	)
+	require.NoError(t, err)
+
+	stats := v.Export().(mcp.ClientStats)
+	assert.Equal(t, int64(3), stats.Calls)
+	assert.Equal(t, int64(1), stats.Errors)
+	assert.Zero(t, stats.Reconnections)
+	assert.Positive(t, stats.BytesSent)
+	assert.Positive(t, stats.BytesReceived)
+}
+
+func TestClientStatsReset(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+	vu := tc.runtime.VU
+	vu.CtxField = k6lib.WithScenarioState(vu.CtxField, &k6lib.ScenarioState{Name: "warmup"})
+
+	v, err := vu.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.callTool({name: "%s", arguments: {id: 1}});
+    client.callTool({name: "%s", arguments: {id: 2}});
+    client.resetStats();
+    client.callTool({name: "%s", arguments: {id: 3}});
+    client.stats();`, ts.URL, toolName, toolName, toolName),
+	)
+	require.NoError(t, err)
+	stats := v.Export().(mcp.ClientStats)
+	assert.Equal(t, "warmup", stats.Scenario)
+	assert.Equal(t, int64(1), stats.Calls)
+
+	// The VU moves on to the next scenario, keeping its client.
+	vu.CtxField = k6lib.WithScenarioState(vu.CtxField, &k6lib.ScenarioState{Name: "load"})
+
+	v, err = vu.Runtime().RunString(`const before = client.stats();
+    client.callTool({name: "` + toolName + `", arguments: {id: 4}});
+    [before, client.stats()];`)
+	require.NoError(t, err)
+	var both []mcp.ClientStats
+	require.NoError(t, vu.Runtime().ExportTo(v, &both))
+	assert.Equal(t, "load", both[0].Scenario)
+	assert.Zero(t, both[0].Calls)
+	assert.Zero(t, both[0].BytesSent)
+	assert.Equal(t, int64(1), both[1].Calls)
+}
+
+func TestAbortAll(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName}, func(ctx context.Context, req *mcpsdk.CallToolRequest, _ MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		res, err := req.Session.CreateMessage(ctx, &mcpsdk.CreateMessageParams{
+			Messages: []*mcpsdk.SamplingMessage{{Role: "user", Content: &mcpsdk.TextContent{Text: "speed?"}}},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if text, ok := res.Content.(*mcpsdk.TextContent); ok && text.Text == "slow" {
+			select {
+			case <-ctx.Done():
+			case <-time.After(2 * time.Second):
+			}
+		}
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "done"}}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, nil)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	// The standalone event stream stays open for as long as the client lives.
+	defer ts.CloseClientConnections()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`let aborted = false;
+    const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      on_create_message: () => {
+        let text = "fast";
+        if (!aborted) {
+          aborted = true;
+          text = "slow";
+          client.abortAll();
+        }
+        return { role: "assistant", model: "test", content: { type: "text", text: text } };
+      },
+    });
+    const start = Date.now();
+    let error = "";
+    try {
+      client.callTool({name: "%s", arguments: {id: 1}});
+    } catch (e) {
+      error = String(e);
+    }
+    const elapsed = Date.now() - start;
+    const after = client.callTool({name: "%s", arguments: {id: 2}}).content[0].text;
+    ({ error, elapsed, after });`, ts.URL, toolName, toolName),
+	)
+	require.NoError(t, err)
+
+	result := v.ToObject(tc.runtime.VU.Runtime())
+	assert.Contains(t, result.Get("error").String(), "call aborted")
+	assert.Less(t, result.Get("elapsed").ToInteger(), int64(1000))
+	assert.Equal(t, "done", result.Get("after").String())
+
+	var aborted int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if value, _ := sample.Tags.Get("cancelled"); sample.Metric.Name == "mcp_request_errors" && value == "abort" {
+				aborted++
+			}
+		}
+	}
+	assert.Equal(t, 1, aborted)
+}
+
+func TestRootsListServed(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName}, func(ctx context.Context, req *mcpsdk.CallToolRequest, _ MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		for range 2 {
+			if _, err := req.Session.ListRoots(ctx, nil); err != nil {
+				return nil, nil, err
+			}
+		}
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "done"}}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, nil)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	// The standalone event stream stays open for as long as the client lives.
+	defer ts.CloseClientConnections()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+    });
+    client.callTool({name: "%s", arguments: {id: 1}});`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	counts := make(map[string]int)
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if name := sample.Metric.Name; name == "mcp_roots_list_served" || name == "mcp_roots_list_duration" {
+				counts[name]++
+			}
+		}
+	}
+	assert.Equal(t, map[string]int{"mcp_roots_list_served": 2, "mcp_roots_list_duration": 2}, counts)
+}
+
+func TestRateLimitRetries(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	var limited int
+	handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+		if jsonReq, err := parseJSONRPCBody(r); err == nil && jsonReq.Method == mcp.CallToolMethod && limited < 2 {
+			limited++
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      retries: 2
+    });
+    client.callTool({name: "%s", arguments: {id: 1}});`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 2, limited)
+
+	var rateLimited int
+	durations := map[string][]float64{}
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			value, _ := sample.Tags.Get("method")
+			if value != mcp.CallToolMethod {
+				continue
+			}
+			switch sample.Metric.Name {
+			case "mcp_rate_limited":
+				rateLimited++
+			case "mcp_request_duration", "mcp_request_total_duration":
+				attempts, _ := sample.Tags.Get("attempts")
+				assert.Equal(t, "3", attempts)
+				durations[sample.Metric.Name] = append(durations[sample.Metric.Name], sample.Value)
+			}
+		}
+	}
+	assert.Equal(t, 2, rateLimited)
+	require.Len(t, durations["mcp_request_duration"], 1)
+	require.Len(t, durations["mcp_request_total_duration"], 1)
+	assert.LessOrEqual(t, durations["mcp_request_duration"][0], durations["mcp_request_total_duration"][0])
+}
+
+func TestSetDefaultArgs(t *testing.T) {
+	type options struct {
+		Verbose bool `json:"verbose,omitempty"`
+		Limit   int  `json:"limit,omitempty"`
+	}
+	type input struct {
+		SessionID string  `json:"session_id,omitempty"`
+		Id        int     `json:"id"`
+		Options   options `json:"options,omitempty"`
+	}
+
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName}, func(_ context.Context, _ *mcpsdk.CallToolRequest, in input) (*mcpsdk.CallToolResult, any, error) {
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{
+			&mcpsdk.TextContent{Text: fmt.Sprintf("%s %d %t %d", in.SessionID, in.Id, in.Options.Verbose, in.Options.Limit)},
+		}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.setDefaultArgs("%s", {session_id: "abc", options: {verbose: true, limit: 10}});
+    const first = client.callTool({name: "%s", arguments: {id: 1, options: {limit: 5}}}).content[0].text;
+    const second = client.callTool({name: "%s", arguments: {id: 2}}).content[0].text;
+    client.setDefaultArgs("%s", null);
+    const third = client.callTool({name: "%s", arguments: {id: 3}}).content[0].text;
+    [first, second, third].join(",");`, ts.URL, toolName, toolName, toolName, toolName, toolName),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "abc 1 true 5,abc 2 true 10, 3 false 0", v.String())
+}
+
+func TestReadResourceTo(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	server.AddResource(&mcpsdk.Resource{URI: "embedded:large", Name: "large"}, func(context.Context, *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+		return &mcpsdk.ReadResourceResult{
+			Contents: []*mcpsdk.ResourceContents{
+				{URI: "embedded:large", MIMEType: "text/plain", Text: strings.Repeat("a", 512*1024)},
+				{URI: "embedded:large", MIMEType: "application/octet-stream", Blob: make([]byte, 1024)},
+			},
+		}, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.readResourceTo("embedded:large", {discard: true});`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	summary := v.Export().(*mcp.ResourceReadSummary)
+	assert.Equal(t, 512*1024+1024, summary.Bytes)
+	require.Len(t, summary.Contents, 2)
+	assert.Equal(t, "application/octet-stream", summary.Contents[1].MIMEType)
+	assert.Equal(t, 1024, summary.Contents[1].Bytes)
+
+	v, err = tc.runtime.VU.Runtime().RunString(`client.readResourceTo("embedded:large", {discard: true}).contents[1].mime_type`)
+	require.NoError(t, err)
+	assert.Equal(t, "application/octet-stream", v.String())
+
+	_, err = tc.runtime.VU.Runtime().RunString(`client.readResourceTo("embedded:large", {})`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "set discard to true")
+}
+
+func TestProgressMetrics(t *testing.T) {
+	tokens := make(chan any, 4)
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		token := req.Params.GetProgressToken()
+		tokens <- token
+		if in.Id == 1 && token != nil {
+			for i := range 3 {
+				err := req.Session.NotifyProgress(ctx, &mcpsdk.ProgressNotificationParams{ProgressToken: token, Progress: float64(i + 1), Total: 3})
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			// Let the client handle the notifications before the response.
+			time.Sleep(50 * time.Millisecond)
+		}
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "done"}}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const scripted = [undefined, undefined, "mine", 7];
+    let calls = 0;
+    client.useRequestInterceptor((method, params) => {
+      const token = scripted[calls++];
+      if (token !== undefined) {
+        params._meta = Object.assign({}, params._meta, { progressToken: token });
+      }
+    });
+    client.callTool({name: "%[2]s", arguments: {id: 1}});
+    client.callTool({name: "%[2]s", arguments: {id: 2}});
+    client.callTool({name: "%[2]s", arguments: {id: 1}});
+    client.callTool({name: "%[2]s", arguments: {id: 1}});`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	// Tokens set by the script are kept, so that it can match the
+	// notifications it drains itself.
+	close(tokens)
+	var sent []any
+	for token := range tokens {
+		sent = append(sent, token)
+	}
+	require.Len(t, sent, 4)
+	assert.IsType(t, "", sent[0])
+	assert.NotEqual(t, sent[0], sent[1], "calls get tokens of their own")
+	assert.Equal(t, []any{"mine", float64(7)}, sent[2:])
+
+	var (
+		durations    []string
+		progressSeen []float64
+	)
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			switch sample.Metric.Name {
+			case "mcp_request_duration":
+				if method, _ := sample.Tags.Get("method"); method == mcp.CallToolMethod {
+					hadProgress, _ := sample.Tags.Get("had_progress")
+					durations = append(durations, hadProgress)
+				}
+			case "mcp_progress_updates":
+				progressSeen = append(progressSeen, sample.Value)
+			}
+		}
+	}
+	assert.Equal(t, []string{"true", "", "true", "true"}, durations)
+	assert.Equal(t, []float64{3, 3, 3}, progressSeen)
+}
+
+func TestMarshalOptionsEscapeHTML(t *testing.T) {
+	type input struct {
+		Text string `json:"text"`
+	}
+
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName}, func(_ context.Context, _ *mcpsdk.CallToolRequest, in input) (*mcpsdk.CallToolResult, any, error) {
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: in.Text}}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	var body string
+	handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+		raw, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if strings.Contains(string(raw), mcp.CallToolMethod) {
+			body = string(raw)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+		handler.ServeHTTP(w, r)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      marshal_options: {escape_html: false}
+    });
+    client.callTool({name: "%s", arguments: {text: "<b> & \\u003c"}}).content[0].text;`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, `<b> & \u003c`, v.String())
+	assert.Contains(t, body, `"text":"<b> & \\u003c"`)
+}
+
+func TestMarshalOptionsEmitDefaults(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName}, func(_ context.Context, _ *mcpsdk.CallToolRequest, _ map[string]any) (*mcpsdk.CallToolResult, any, error) {
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "ok"}}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	var mu sync.Mutex
+	bodies := map[string]string{}
+	handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+		raw, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var msg struct {
+			Method string `json:"method"`
+		}
+		if json.Unmarshal(raw, &msg) == nil {
+			mu.Lock()
+			bodies[r.URL.Path+" "+msg.Method] = string(raw)
+			mu.Unlock()
+		}
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+		handler.ServeHTTP(w, r)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const emitting = mcp.StreamableHTTPClient({
+      base_url: "%[1]s/emit",
+      stateless: true,
+      marshal_options: {emit_defaults: true}
+    });
+    emitting.listTools();
+    const omitting = mcp.StreamableHTTPClient({
+      base_url: "%[1]s/omit",
+      stateless: true,
+      marshal_options: {emit_defaults: false}
+    });
+    omitting.callTool({name: "%[2]s", arguments: {}});
+    const plain = mcp.StreamableHTTPClient({
+      base_url: "%[1]s/plain",
+      stateless: true
+    });
+    plain.callTool({name: "%[2]s", arguments: {}});`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	params := func(key string) map[string]json.RawMessage {
+		var msg struct {
+			Params map[string]json.RawMessage `json:"params"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(bodies[key]), &msg))
+		return msg.Params
+	}
+	emitted := params("/emit " + mcp.ListToolsMethod)
+	assert.JSONEq(t, `{}`, string(emitted["_meta"]))
+	assert.JSONEq(t, `""`, string(emitted["cursor"]))
+	assert.NotContains(t, params("/omit "+mcp.CallToolMethod), "arguments")
+	assert.JSONEq(t, `{}`, string(params("/plain " + mcp.CallToolMethod)["arguments"]))
+}
+
+func TestWaitForNotification(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName}, func(ctx context.Context, req *mcpsdk.CallToolRequest, _ MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		token := req.Params.GetProgressToken()
+		err := req.Session.NotifyProgress(ctx, &mcpsdk.ProgressNotificationParams{ProgressToken: token, Message: "started"})
+		if err != nil {
+			return nil, nil, err
+		}
+		go func() {
+			// Finish the work after the call has returned.
+			time.Sleep(50 * time.Millisecond)
+			_ = req.Session.NotifyProgress(context.Background(), &mcpsdk.ProgressNotificationParams{ProgressToken: token, Message: "finished"})
+		}()
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "accepted"}}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, nil)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	// The standalone event stream stays open for as long as the client lives.
+	defer ts.CloseClientConnections()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.RunOnEventLoop(
+		fmt.Sprintf(`var result = {};
+    const client = mcp.StreamableHTTPClient({
+      base_url: "%s"
+    });
+    client.callTool({name: "%s", arguments: {id: 1}});
+    client.waitForNotification("notifications/progress", {
+      timeout_ms: 5000,
+      match: (n) => n.params.message === "finished",
+    }).then((n) => { result.finished = n.params.message; });
+    client.waitForNotification("notifications/progress", {
+      match: (n) => n.params.message === "started",
+    }).then((n) => { result.started = n.params.message; });
+    client.waitForNotification("notifications/progress", {
+      timeout_ms: 10,
+      match: () => false,
+    }).catch((e) => { result.error = String(e); });`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	v, err := tc.runtime.VU.Runtime().RunString(`JSON.stringify(result)`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"started": "started", "finished": "finished", "error": "timed out after 10ms waiting for notifications/progress"}`, v.String())
+
+	var waits int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name == "mcp_notification_wait" {
+				waits++
+			}
+		}
+	}
+	assert.Equal(t, 2, waits)
+}
+
+func TestTLSSessionResumption(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	// Every request opens a new connection, and so a new TLS handshake.
+	ts := httptest.NewUnstartedServer(handler)
+	ts.Config.SetKeepAlivesEnabled(false)
+	ts.StartTLS()
+	defer ts.Close()
+
+	tc := setupTest(t)
+	tc.runtime.VU.State().TLSConfig.InsecureSkipVerify = true
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      tls_session_cache: true
+    });
+    client.callTool({name: "%s", arguments: {id: 1}});`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	handshakes := map[string]int{}
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name == "mcp_tls_resumed" {
+				resumed, _ := sample.Tags.Get("resumed")
+				handshakes[resumed]++
+			}
+		}
+	}
+	assert.Equal(t, 1, handshakes["false"])
+	assert.Positive(t, handshakes["true"])
+}
+
+func TestThrowOnToolError(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		return &mcpsdk.CallToolResult{
+			IsError: true,
+			Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "quota exceeded"}},
+		}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const lenient = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const strict = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      throw_on_tool_error: true
+    });
+    const result = { returned: lenient.callTool({name: "%s", arguments: {id: 1}}).is_error };
+    try {
+      strict.callTool({name: "%s", arguments: {id: 1}});
+    } catch (e) {
+      result.message = e.message;
+      result.text = e.value.content[0].text;
+    }
+    JSON.stringify(result);`, ts.URL, ts.URL, toolName, toolName),
+	)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"returned": true, "message": "tool \"myTool\" failed: quota exceeded", "text": "quota exceeded"}`, v.String())
+}
+
+func TestEmitChecks(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "ok"}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "done"}}}, nil, nil
+	})
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "flagged"}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		return &mcpsdk.CallToolResult{
+			IsError: true,
+			Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "quota exceeded"}},
+		}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      emit_checks: true,
+      metric_tags: ["method"]
+    });
+    client.listTools();
+    client.callTool({name: "ok", arguments: {id: 1}});
+    client.callTool({name: "flagged", arguments: {id: 1}});
+    try {
+      client.callTool({name: "missing", arguments: {id: 1}});
+    } catch (e) {}`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	checks := make(map[string]float64)
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name == k6metrics.ChecksName {
+				check, _ := sample.Tags.Get("check")
+				checks[check] += sample.Value
+			}
+		}
+	}
+	assert.Equal(t, map[string]float64{
+		"tools/list":         1,
+		"tools/call ok":      1,
+		"tools/call flagged": 0,
+		"tools/call missing": 0,
+	}, checks)
+}
+
+func TestReadOnly(t *testing.T) {
+	var wiped atomic.Int32
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "lookup", Annotations: &mcpsdk.ToolAnnotations{ReadOnlyHint: true}}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "found"}}}, nil, nil
+	})
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "wipe"}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		wiped.Add(1)
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "wiped"}}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      read_only: true
+    });
+    const result = { found: client.callTool({name: "lookup", arguments: {id: 1}}).content[0].text };
+    try {
+      client.callTool({name: "wipe", arguments: {id: 1}});
+    } catch (e) {
+      result.wipe = e.message;
+    }
+    try {
+      client.callTool({name: "missing", arguments: {id: 1}});
+    } catch (e) {
+      result.missing = e.message;
+    }
+    JSON.stringify(result);`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	var result map[string]string
+	require.NoError(t, json.Unmarshal([]byte(v.String()), &result))
+	assert.Equal(t, "found", result["found"])
+	assert.Equal(t, `tool "wipe" is not annotated as read-only: blocked by read_only`, result["wipe"])
+	assert.Contains(t, result["missing"], `tool "missing" cannot be checked: blocked by read_only`)
+	assert.Zero(t, wiped.Load())
+
+	var blocked int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if value, _ := sample.Tags.Get("blocked"); sample.Metric.Name == "mcp_request_errors" && value == "readonly" {
+				blocked++
+			}
+		}
+	}
+	assert.Equal(t, 2, blocked)
+}
+
+func TestCatalog(t *testing.T) {
+	newServer := func(full bool) *httptest.Server {
+		server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, &mcpsdk.ServerOptions{PageSize: 1})
+		for _, name := range []string{"alpha", "beta"} {
+			mcpsdk.AddTool(server, &mcpsdk.Tool{Name: name}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+				return &mcpsdk.CallToolResult{}, nil, nil
+			})
+		}
+		if full {
+			readResource := func(_ context.Context, req *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+				return &mcpsdk.ReadResourceResult{Contents: []*mcpsdk.ResourceContents{{URI: req.Params.URI, Text: "data"}}}, nil
+			}
+			server.AddResource(&mcpsdk.Resource{URI: "file:///a.txt", Name: "a"}, readResource)
+			server.AddResource(&mcpsdk.Resource{URI: "file:///b.txt", Name: "b"}, readResource)
+			server.AddResourceTemplate(&mcpsdk.ResourceTemplate{URITemplate: "file:///{name}", Name: "files"}, readResource)
+			server.AddPrompt(&mcpsdk.Prompt{Name: "greet"}, func(context.Context, *mcpsdk.GetPromptRequest) (*mcpsdk.GetPromptResult, error) {
+				return &mcpsdk.GetPromptResult{}, nil
+			})
+		} else {
+			server.AddReceivingMiddleware(func(next mcpsdk.MethodHandler) mcpsdk.MethodHandler {
+				return func(ctx context.Context, method string, req mcpsdk.Request) (mcpsdk.Result, error) {
+					if method == mcp.ListResourcesMethod || method == mcp.ListPromptsMethod {
+						return nil, errors.New("unavailable")
+					}
+					return next(ctx, method, req)
+				}
+			})
+		}
+		return httptest.NewServer(mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+			return server
+		}, &mcpsdk.StreamableHTTPOptions{Stateless: true}))
+	}
+
+	t.Run("complete", func(t *testing.T) {
+		ts := newServer(true)
+		defer ts.Close()
+
+		tc := setupTest(t)
+
+		v, err := tc.runtime.VU.Runtime().RunString(
+			fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const catalog = client.catalog();
+    JSON.stringify({
+      tools: catalog.tools.map((t) => t.name),
+      resources: catalog.resources.map((r) => r.uri),
+      templates: catalog.resource_templates.map((t) => t.uri_template),
+      prompts: catalog.prompts.map((p) => p.name),
+    });`, ts.URL),
+		)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+      "tools": ["alpha", "beta"],
+      "resources": ["file:///a.txt", "file:///b.txt"],
+      "templates": ["file:///{name}"],
+      "prompts": ["greet"]
+    }`, v.String())
+
+		pages := make(map[string]int)
+		for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+			for _, sample := range sampleContainer.GetSamples() {
+				if sample.Metric.Name == "mcp_request_count" {
+					method, _ := sample.Tags.Get("method")
+					pages[method]++
+				}
+			}
+		}
+		assert.Equal(t, map[string]int{
+			mcp.ListToolsMethod:             2,
+			mcp.ListResourcesMethod:         2,
+			mcp.ListResourceTemplatesMethod: 1,
+			mcp.ListPromptsMethod:           1,
+		}, pages)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		ts := newServer(false)
+		defer ts.Close()
+
+		tc := setupTest(t)
+
+		_, err := tc.runtime.VU.Runtime().RunString(
+			fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.catalog();`, ts.URL),
+		)
+		require.Error(t, err)
+		assert.NotContains(t, err.Error(), "failed to list tools")
+		assert.Contains(t, err.Error(), "failed to list resources")
+		assert.Contains(t, err.Error(), "failed to list prompts")
+	})
+}
+
+func TestLatencyGuard(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "slow"}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		time.Sleep(20 * time.Millisecond)
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "done"}}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	t.Run("iteration", func(t *testing.T) {
+		tc := setupTest(t)
+
+		v, err := tc.runtime.VU.Runtime().RunString(
+			fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.setLatencyGuard("slow", { p95_ms: 5, window_size: 3 });
+    const results = [];
+    for (let i = 0; i < 4; i++) {
+      try {
+        results.push(client.callTool({name: "slow", arguments: {id: i}}).content[0].text);
+      } catch (e) {
+        results.push(e.message);
+      }
+    }
+    JSON.stringify(results);`, ts.URL),
+		)
+		require.NoError(t, err)
+
+		var results []string
+		require.NoError(t, json.Unmarshal([]byte(v.String()), &results))
+		require.Len(t, results, 4)
+		assert.Equal(t, []string{"done", "done"}, results[:2])
+		assert.Contains(t, results[2], `latency guard of tool "slow" breached: the p95 of the last 3 calls is`)
+		assert.Contains(t, results[3], "breached")
+
+		var p95s []float64
+		for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+			for _, sample := range sampleContainer.GetSamples() {
+				if tool, _ := sample.Tags.Get("tool"); sample.Metric.Name == "mcp_latency_guard_p95" && tool == "slow" {
+					p95s = append(p95s, sample.Value)
+				}
+			}
+		}
+		require.Len(t, p95s, 4)
+		for _, p95 := range p95s {
+			assert.GreaterOrEqual(t, p95, 20.0)
+		}
+	})
+
+	t.Run("test", func(t *testing.T) {
+		tc := setupTest(t)
+
+		_, err := tc.runtime.VU.Runtime().RunString(
+			fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.setLatencyGuard("slow", { p95_ms: 5, window_size: 1, abort: "test" });
+    try {
+      client.callTool({name: "slow", arguments: {id: 1}});
+    } catch (e) {}
+    "survived";`, ts.URL),
+		)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `test aborted: latency guard of tool "slow" breached`)
+	})
+}
+
+func TestMethodTimeouts(t *testing.T) {
+	slow := func(ctx context.Context, _ *mcpsdk.CallToolRequest, _ MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "done"}}}, nil, nil
+	}
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "impatient"}, slow)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "patient"}, slow)
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      timeout: "5s",
+      method_timeouts: {"tools/call": "20ms", "patient": "2s"}
+    });
+    let error = "";
+    try {
+      client.callTool({name: "impatient", arguments: {id: 1}});
+    } catch (e) {
+      error = String(e);
+    }
+    [error, client.callTool({name: "patient", arguments: {id: 1}}).content[0].text];`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	results := v.Export().([]any)
+	assert.Contains(t, results[0], "context deadline exceeded")
+	assert.Equal(t, "done", results[1])
+
+	var timedOut int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if value, _ := sample.Tags.Get("cancelled"); sample.Metric.Name == "mcp_request_errors" && value == "timeout" {
+				timedOut++
+			}
+		}
+	}
+	assert.Equal(t, 1, timedOut)
+}
+
+func TestSessionLifecycleLogs(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+	tc.logger.SetLevel(logrus.DebugLevel)
+	hook := logtest.NewLocal(tc.logger)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.close();`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	var messages []string
+	for _, entry := range hook.AllEntries() {
+		if entry.Data["transport"] == "streamable-http" {
+			messages = append(messages, entry.Message)
+			assert.Contains(t, entry.Data, "duration")
+		}
+	}
+	assert.Equal(t, []string{"MCP session connected", "MCP session closed"}, messages)
+}
+
+func TestFuzzTool(t *testing.T) {
+	schema := &jsonschema.Schema{
+		Type:     "object",
+		Required: []string{"id", "mode"},
+		Properties: map[string]*jsonschema.Schema{
+			"id":    {Type: "integer", Minimum: jsonschema.Ptr(1.0), Maximum: jsonschema.Ptr(10.0)},
+			"mode":  {Type: "string", Enum: []any{"read", "write"}},
+			"ratio": {Type: "number", ExclusiveMinimum: jsonschema.Ptr(0.0), Maximum: jsonschema.Ptr(1.0)},
+			"label": {Type: "string", MinLength: jsonschema.Ptr(2), MaxLength: jsonschema.Ptr(5)},
+			"tags":  {Type: "array", Items: &jsonschema.Schema{Type: "boolean"}, MaxItems: jsonschema.Ptr(2)},
+		},
+		AdditionalProperties: &jsonschema.Schema{Not: &jsonschema.Schema{}},
+	}
+	resolved, err := schema.Resolve(nil)
+	require.NoError(t, err)
+
+	var invalid atomic.Int32
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	server.AddTool(&mcpsdk.Tool{Name: toolName, InputSchema: schema}, func(_ context.Context, req *mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		var args map[string]any
+		if err := json.Unmarshal(req.Params.Arguments, &args); err != nil || resolved.Validate(args) != nil {
+			invalid.Add(1)
+		}
+		if args["mode"] == "write" {
+			return &mcpsdk.CallToolResult{
+				IsError: true,
+				Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "read only"}},
+			}, nil
+		}
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "ok"}}}, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const first = client.fuzzTool("%s", {count: 20, seed: 42});
+    const second = client.fuzzTool("%s", {count: 20, seed: 42});
+    JSON.stringify([first, second]);`, ts.URL, toolName, toolName),
+	)
+	require.NoError(t, err)
+	assert.Zero(t, invalid.Load())
+
+	type summary struct {
+		Seed       uint64
+		Calls      int
+		Succeeded  int
+		ToolErrors int `json:"tool_errors"`
+		Failed     int
+		Failures   []struct {
+			Arguments map[string]any
+			Error     string
+		}
+	}
+	var summaries [2]summary
+	require.NoError(t, json.Unmarshal([]byte(v.String()), &summaries))
+	// The same seed generates the same arguments.
+	assert.Equal(t, summaries[0], summaries[1])
+
+	first := summaries[0]
+	assert.EqualValues(t, 42, first.Seed)
+	assert.Equal(t, 20, first.Calls)
+	assert.Zero(t, first.Failed)
+	assert.Positive(t, first.Succeeded)
+	assert.Positive(t, first.ToolErrors)
+	assert.Equal(t, 20, first.Succeeded+first.ToolErrors)
+	require.Len(t, first.Failures, first.ToolErrors)
+	assert.Equal(t, "write", first.Failures[0].Arguments["mode"])
+	assert.Equal(t, `tool "myTool" failed: read only`, first.Failures[0].Error)
+
+	fuzzed := 0
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name != "mcp_request_duration" {
+				continue
+			}
+			if fuzz, _ := sample.Tags.Get("fuzz"); fuzz == "true" {
+				method, _ := sample.Tags.Get("method")
+				assert.Equal(t, mcp.CallToolMethod, method)
+				fuzzed++
+			}
+		}
+	}
+	assert.Equal(t, 40, fuzzed)
+}
+
+func TestRecordCalls(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+	path := filepath.Join(t.TempDir(), "calls.jsonl")
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const config = {base_url: "%s", stateless: true, record_calls: %q};
+    const first = mcp.StreamableHTTPClient(config);
+    const second = mcp.StreamableHTTPClient(config);
+    first.callTool({name: "%s", arguments: {id: 1}});
+    try {
+      second.readResource({uri: "file:///missing"});
+    } catch (e) {}
+    mcp.flushRecordings();`, ts.URL, path, toolName),
+	)
+	require.NoError(t, err)
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	require.Len(t, lines, 2)
+
+	type record struct {
+		Method     string
+		Params     map[string]any
+		Result     map[string]any
+		Error      *string
+		DurationMs float64
+		Ts         time.Time
+	}
+	var call, read record
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &call))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &read))
+
+	assert.Equal(t, mcp.CallToolMethod, call.Method)
+	assert.Equal(t, toolName, call.Params["name"])
+	assert.Equal(t, map[string]any{"output": toolName}, call.Result["structuredContent"])
+	assert.Nil(t, call.Error)
+	assert.Positive(t, call.DurationMs)
+	assert.WithinDuration(t, time.Now(), call.Ts, time.Minute)
+
+	assert.Equal(t, mcp.ReadResourceMethod, read.Method)
+	assert.Equal(t, "file:///missing", read.Params["uri"])
+	assert.Nil(t, read.Result)
+	require.NotNil(t, read.Error)
+	assert.Contains(t, *read.Error, "not found")
+}
+
+func TestWaitForServer(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	// The server only becomes ready after rejecting a few requests.
+	var rejected atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rejected.Add(1) <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`JSON.stringify([
+      mcp.waitForServer({base_url: "%s", interval_ms: 10, timeout_ms: 5000}),
+      mcp.waitForServer({base_url: "%s", interval_ms: 10, timeout_ms: 100}),
+    ]);`, ts.URL, down.URL),
+	)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[true, false]`, v.String())
+	assert.Greater(t, rejected.Load(), int32(3))
+}
+
+func TestListAllResourcesByMIMEType(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	for _, res := range []*mcpsdk.Resource{
+		{URI: "embedded:readme", Name: "readme", MIMEType: "text/plain; charset=utf-8"},
+		{URI: "embedded:logo", Name: "logo", MIMEType: "image/png"},
+		{URI: "embedded:photo", Name: "photo", MIMEType: "IMAGE/JPEG"},
+		{URI: "embedded:unknown", Name: "unknown"},
+	} {
+		server.AddResource(res, func(context.Context, *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+			return &mcpsdk.ReadResourceResult{}, nil
+		})
+	}
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const uris = (mimeType) => client.listAllResources({mime_type: mimeType}).resources.map((r) => r.uri).sort();
+    JSON.stringify({all: uris(""), images: uris("image/*"), text: uris("text/plain"), jpeg: uris("image/jpeg")});`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+    "all": ["embedded:logo", "embedded:photo", "embedded:readme", "embedded:unknown"],
+    "images": ["embedded:logo", "embedded:photo"],
+    "text": ["embedded:readme"],
+    "jpeg": ["embedded:photo"]
+  }`, v.String())
+}
+
+func TestReinitialize(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		return nil, MyToolOutput{toolName}, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, nil)
+
+	var mu sync.Mutex
+	var handshake []string
+	handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			raw, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			var msg struct {
+				Method string          `json:"method"`
+				Params json.RawMessage `json:"params"`
+			}
+			if json.Unmarshal(raw, &msg) == nil && (msg.Method == "initialize" || msg.Method == "notifications/initialized") {
+				mu.Lock()
+				handshake = append(handshake, msg.Method+" "+string(msg.Params))
+				mu.Unlock()
+			}
+			r.Body = io.NopCloser(bytes.NewReader(raw))
+		}
+		handler.ServeHTTP(w, r)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+	defer ts.Close()
+	// The standalone event stream stays open for as long as the client lives.
+	defer ts.CloseClientConnections()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({base_url: "%s"});`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	_, err = tc.runtime.VU.Runtime().RunString(`client.requireCapabilities({prompts: true});`)
+	require.Error(t, err)
+
+	// The server gains the prompts capability once it has a prompt.
+	server.AddPrompt(&mcpsdk.Prompt{Name: "greet"}, func(context.Context, *mcpsdk.GetPromptRequest) (*mcpsdk.GetPromptResult, error) {
+		return &mcpsdk.GetPromptResult{}, nil
+	})
+
+	v, err := tc.runtime.VU.Runtime().RunString(`
+    const result = client.reinitialize();
+    client.requireCapabilities({tools: true, prompts: true});
+    client.callTool({name: "myTool", arguments: {id: 1}});
+    result.server_info.name;`)
+	require.NoError(t, err)
+	assert.Equal(t, "test", v.String())
+
+	var reinitializations int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name == "mcp_reinitialize_duration" {
+				reinitializations++
+			}
+		}
+	}
+	assert.Equal(t, 1, reinitializations)
+
+	// The handshake is sent again as the SDK first sent it.
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, handshake, 4)
+	assert.Equal(t, handshake[0], handshake[2])
+	assert.Equal(t, handshake[1], handshake[3])
+	assert.True(t, strings.HasPrefix(handshake[3], "notifications/initialized "))
+}
+
+func TestCallToolTags(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.callTool({name: "%s", arguments: {id: 1}}, {tags: {row: "42"}});
+    client.callTool({name: "%s", arguments: {id: 2}});`, ts.URL, toolName, toolName),
+	)
+	require.NoError(t, err)
+
+	var rows []string
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name != "mcp_request_duration" {
+				continue
+			}
+			if method, _ := sample.Tags.Get("method"); method == mcp.CallToolMethod {
+				row, _ := sample.Tags.Get("row")
+				rows = append(rows, row)
+			}
+		}
+	}
+	assert.Equal(t, []string{"42", ""}, rows)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`client.callTool({name: "%s", arguments: {id: 1}}, {tags: {method: "other"}});`, toolName),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `the "method" tag is reserved`)
+}
+
+func TestResourcesIterator(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, &mcpsdk.ServerOptions{PageSize: 2})
+	for i := range 5 {
+		name := fmt.Sprintf("res%d", i)
+		server.AddResource(&mcpsdk.Resource{URI: "embedded:" + name, Name: name}, func(context.Context, *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+			return &mcpsdk.ReadResourceResult{}, nil
+		})
+	}
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.RunOnEventLoop(
+		fmt.Sprintf(`var pages = [];
+    var overlapping = "";
+    var intercepted = 0;
+    const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.useRequestInterceptor(() => { intercepted++; });
+    (async () => {
+      const it = client.resourcesIterator();
+      const first = it.next();
+      it.next().catch((e) => { overlapping = String(e); });
+      for (let page = await first; !page.done; page = await it.next()) {
+        pages.push(page.value.resources.map((r) => r.name));
+      }
+    })();`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	pages, err := tc.runtime.RunOnEventLoop(`JSON.stringify(pages)`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[["res0", "res1"], ["res2", "res3"], ["res4"]]`, pages.String())
+	overlapping, err := tc.runtime.RunOnEventLoop(`overlapping`)
+	require.NoError(t, err)
+	assert.Contains(t, overlapping.String(), "the previous page is still being fetched")
+	// The pages go through the interceptors while the VU runs other code.
+	intercepted, err := tc.runtime.RunOnEventLoop(`intercepted`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), intercepted.ToInteger())
+
+	var lists int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if method, _ := sample.Tags.Get("method"); sample.Metric.Name == "mcp_request_count" && method == mcp.ListResourcesMethod {
+				lists++
+			}
+		}
+	}
+	assert.Equal(t, 3, lists)
+}
+
+func TestOutputSchemaReference(t *testing.T) {
+	type weather struct {
+		Celsius float64 `json:"celsius"`
+	}
+	outputSchema, err := jsonschema.For[weather](nil)
+	require.NoError(t, err)
+	outputSchema.ID = "https://example.com/schemas/weather.json"
+
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "weather", OutputSchema: outputSchema},
+		func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, weather, error) {
+			return nil, weather{Celsius: 21}, nil
+		})
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "echo"}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		return &mcpsdk.CallToolResult{}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const schema = (res) => String((res.getMeta() || {})["xk6-mcp/outputSchema"]);
+    const unlisted = schema(client.callTool({name: "weather", arguments: {id: 1}}));
+    client.listAllTools();
+    const listed = client.callTool({name: "weather", arguments: {id: 1}});
+    [unlisted, schema(listed), listed.structured_content.celsius, schema(client.callTool({name: "echo", arguments: {id: 1}}))].join("|");`, ts.URL),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, "undefined|https://example.com/schemas/weather.json|21|undefined", v.String())
+}
+
+func TestSetDefaults(t *testing.T) {
+	var observed []string
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	handlerFunc := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			observed = append(observed, r.Header.Get("Authorization")+"|"+r.Header.Get("X-API-Key"))
+		}
+		handler.ServeHTTP(w, r)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handlerFunc))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`mcp.setDefaults({
+      base_url: "%s",
+      auth: {bearer_token: "default"}
+    });
+    const withDefaults = mcp.StreamableHTTPClient({});
+    withDefaults.listTools();
+    const withAPIKey = mcp.StreamableHTTPClient({auth: {api_key: "key"}});
+    withAPIKey.listTools();`, ts.URL),
+	)
+	require.NoError(t, err)
+	require.NotEmpty(t, observed)
+	assert.Equal(t, "Bearer default|", observed[0])
+	// auth is replaced as a whole rather than merged with the defaults.
+	assert.Equal(t, "|key", observed[len(observed)-1])
+
+	_, err = tc.runtime.VU.Runtime().RunString(`mcp.setDefaults([{base_url: "http://localhost"}]);`)
+	assert.ErrorContains(t, err, "invalid defaults")
+
+	_, err = tc.runtime.VU.Runtime().RunString(`mcp.setDefaults(null); mcp.StreamableHTTPClient({});`)
+	assert.ErrorContains(t, err, "base_url")
+}
+
+func TestCallToolFollowLinks(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	server.AddResource(&mcpsdk.Resource{URI: "embedded:report", Name: "report"}, func(context.Context, *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+		return &mcpsdk.ReadResourceResult{
+			Contents: []*mcpsdk.ResourceContents{{URI: "embedded:report", Text: "report"}},
+		}, nil
+	})
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "export"}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{
+			&mcpsdk.TextContent{Text: "exported"},
+			&mcpsdk.ResourceLink{URI: "embedded:report", Name: "report"},
+			&mcpsdk.ResourceLink{URI: "embedded:missing", Name: "missing"},
+		}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const result = client.callToolFollowLinks({name: "export", arguments: {id: 1}});
+    const [report, missing] = result.resolved_resources;
+    [result.content.length, report.uri, report.contents[0].text, missing.uri, missing.contents.length === 0, missing.error !== ""].join("|");`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "3|embedded:report|report|embedded:missing|true|true", v.String())
+
+	var reads, failedReads int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if method, _ := sample.Tags.Get("method"); method == mcp.ReadResourceMethod {
+				switch sample.Metric.Name {
+				case "mcp_request_count":
+					reads++
+				case "mcp_request_errors":
+					failedReads++
+				}
+			}
+		}
+	}
+	assert.Equal(t, 2, reads)
+	assert.Equal(t, 1, failedReads)
+}
+
+func TestReadResourceAll(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	server.AddResource(&mcpsdk.Resource{URI: "file:///docs/", Name: "docs"}, func(context.Context, *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+		return &mcpsdk.ReadResourceResult{
+			Contents: []*mcpsdk.ResourceContents{
+				{URI: "file:///docs/README.md", MIMEType: "text/markdown", Text: "# Docs"},
+				{URI: "file:///docs/notes.txt", MIMEType: "text/plain", Text: "notes"},
+				{URI: "file:///docs/logo.png", MIMEType: "image/png", Blob: []byte{0x89, 'P', 'N', 'G'}},
+			},
+		}, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const read = client.readResource({uri: "file:///docs/"});
+    const all = client.readResourceAll("file:///docs/");
+    JSON.stringify({
+      read: read.contents.map((c) => c.uri),
+      all: all.map((c) => [c.uri, c.mime_type, c.text, c.blob === null ? null : Array.from(new Uint8Array(c.blob))]),
+    });`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+    "read": ["file:///docs/README.md", "file:///docs/notes.txt", "file:///docs/logo.png"],
+    "all": [
+      ["file:///docs/README.md", "text/markdown", "# Docs", null],
+      ["file:///docs/notes.txt", "text/plain", "notes", null],
+      ["file:///docs/logo.png", "image/png", "", [137, 80, 78, 71]]
+    ]
+  }`, v.String())
+}
+
+func TestCallToolResources(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "export"}, func(context.Context, *mcpsdk.CallToolRequest, any) (*mcpsdk.CallToolResult, any, error) {
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{
+			&mcpsdk.TextContent{Text: "exported 2 files"},
+			&mcpsdk.EmbeddedResource{Resource: &mcpsdk.ResourceContents{URI: "file:///export/report.csv", MIMEType: "text/csv", Text: "a,b"}},
+			&mcpsdk.ResourceLink{URI: "file:///export/", Name: "export"},
+			&mcpsdk.EmbeddedResource{Resource: &mcpsdk.ResourceContents{URI: "file:///export/chart.png", MIMEType: "image/png", Blob: []byte{0x89, 'P', 'N', 'G'}}},
+		}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const result = client.callTool({name: "export"});
+    const resources = client.callToolResources({name: "export"});
+    JSON.stringify({
+      types: JSON.parse(JSON.stringify(result.content)).map((c) => c.type),
+      embedded: JSON.parse(JSON.stringify(result.content[1])),
+      resources: resources.map((r) => [r.uri, r.mime_type, r.text, r.blob === null ? null : Array.from(new Uint8Array(r.blob))]),
+    });`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+    "types": ["text", "resource", "resource_link", "resource"],
+    "embedded": {"type": "resource", "resource": {"uri": "file:///export/report.csv", "mimeType": "text/csv", "text": "a,b"}},
+    "resources": [
+      ["file:///export/report.csv", "text/csv", "a,b", null],
+      ["file:///export/chart.png", "image/png", "", [137, 80, 78, 71]]
+    ]
+  }`, v.String())
+}
+
+func TestRequestInterceptor(t *testing.T) {
+	type signedInput struct {
+		ID   int    `json:"id"`
+		Note string `json:"note,omitempty"`
+	}
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "echo"}, func(_ context.Context, req *mcpsdk.CallToolRequest, in signedInput) (*mcpsdk.CallToolResult, any, error) {
+		signature, _ := req.Params.Meta["signature"].(string)
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{
+			&mcpsdk.TextContent{Text: fmt.Sprintf("%d %s %s", in.ID, in.Note, signature)},
+		}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const methods = [];
+    client.useRequestInterceptor((method, params) => {
+      methods.push(method);
+      if (method !== "tools/call") {
+        return;
+      }
+      params.arguments.note = "intercepted";
+      return Object.assign({}, params, {_meta: {signature: "sig-" + params.arguments.id}});
+    });
+    const signed = client.callTool({name: "echo", arguments: {id: 7}}).content[0].text;
+    client.listTools();
+    client.useRequestInterceptor(null);
+    const unsigned = client.callTool({name: "echo", arguments: {id: 8}}).content[0].text;
+    [signed, unsigned.trim(), methods.join(",")].join("|");`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "7 intercepted sig-7|8|tools/call,tools/list", v.String())
+
+	_, err = tc.runtime.VU.Runtime().RunString(`
+    client.useRequestInterceptor(() => { throw new Error("cannot sign"); });
+    client.callTool({name: "echo", arguments: {id: 9}});`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "request interceptor: Error: cannot sign")
+}
+
+func TestResponseInterceptor(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "weather"}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		return &mcpsdk.CallToolResult{
+			Content:           []mcpsdk.Content{&mcpsdk.TextContent{Text: "ok"}},
+			StructuredContent: map[string]any{"status": "ok", "data": map[string]any{"celsius": 21}},
+		}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const seen = [];
+    client.useResponseInterceptor((method, result, error) => {
+      seen.push(method + ":" + (error === null ? "ok" : "error"));
+      if (error !== null) {
+        throw new Error("normalized: " + error);
+      }
+      if (method === "tools/call") {
+        return Object.assign({}, result, {structuredContent: result.structuredContent.data});
+      }
+    });
+    const weather = client.callTool({name: "weather", arguments: {id: 1}});
+    const tools = client.listTools().tools.length;
+    let failure = "";
+    try {
+      client.callTool({name: "missing", arguments: {id: 1}});
+    } catch (e) {
+      failure = String(e);
+    }
+    [weather.structured_content.celsius, tools, seen.join(","), failure].join("|");`, ts.URL),
+	)
+	require.NoError(t, err)
+	parts := strings.Split(v.String(), "|")
+	require.Len(t, parts, 4)
+	assert.Equal(t, []string{"21", "1", "tools/call:ok,tools/list:ok,tools/call:error"}, parts[:3])
+	assert.Contains(t, parts[3], "response interceptor: Error: normalized:")
+	assert.Contains(t, parts[3], "missing")
+}
+
+func TestConnectionGroup(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(handler)
+	var conns atomic.Int32
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const group = new mcp.ConnectionGroup({
+      transport: "streamable-http",
+      client: {
+        base_url: "%s",
+        stateless: true
+      }
+    });
+    const clients = [group.client(), group.client(), group.client()];
+    clients.forEach((client, i) => {
+      for (let n = 0; n <= i; n++) {
+        client.callTool({name: "%s", arguments: {id: n}});
+      }
+    });
+    [clients.map((client) => client.stats().calls).join(","), group.openConnections()].join("|");`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "1,2,3|1", v.String())
+	assert.Equal(t, int32(1), conns.Load())
+
+	var open []float64
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if group, _ := sample.Tags.Get("group"); sample.Metric.Name == "mcp_group_connections" {
+				assert.Equal(t, "streamable-http "+ts.URL, group)
+				open = append(open, sample.Value)
+			}
+		}
+	}
+	assert.Equal(t, []float64{1}, open)
+
+	_, err = tc.runtime.VU.Runtime().RunString(`new mcp.ConnectionGroup({transport: "stdio", client: {path: "./server"}});`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "stdio sessions cannot share a connection")
+}
+
+func TestConnectionGroupAuth(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	var requests, authorized atomic.Int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("Authorization") == "Bearer fromsetup" {
+			authorized.Add(1)
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	var conns atomic.Int32
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	// The token comes in as setup data would, once the VU runs.
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const group = new mcp.ConnectionGroup({
+      transport: "streamable-http",
+      client: {
+        base_url: "%s",
+        stateless: true
+      }
+    });
+    const data = JSON.parse('{"token": "fromsetup"}');
+    for (let i = 0; i < 3; i++) {
+      group.client({bearer_token: data.token}).callTool({name: "%s", arguments: {id: i}});
+    }
+    group.openConnections();`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), v.ToInteger())
+	assert.Equal(t, int32(1), conns.Load())
+
+	assert.Positive(t, requests.Load())
+	assert.Equal(t, requests.Load(), authorized.Load())
+
+	_, err = tc.runtime.VU.Runtime().RunString(`group.client({scheme: "basic"});`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid auth config: auth.username is required for the basic scheme")
+}
+
+func TestStrictMode(t *testing.T) {
+	fakeServer := func(version string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			var req struct {
+				ID     int            `json:"id"`
+				Method string         `json:"method"`
+				Params map[string]any `json:"params"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			respond := func(result string) string {
+				return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":%s}`, req.ID, result)
+			}
+
+			switch req.Method {
+			case "initialize":
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = fmt.Fprint(w, respond(fmt.Sprintf(
+					`{"protocolVersion":%q,"capabilities":{"tools":{"listChanged":false}},"serverInfo":{"name":"fake","version":"1.0.0"}}`, version)))
+			case "tools/list":
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = fmt.Fprint(w, respond(`{"tools":[{"name":"echo","inputSchema":{"type":"object"},"category":"misc"}],"nextCursorHint":""}`))
+			case "tools/call":
+				w.Header().Set("Content-Type", "text/event-stream")
+				if args, _ := req.Params["arguments"].(map[string]any); args["warn"] == true {
+					_, _ = fmt.Fprint(w, "event: message\ndata: "+
+						`{"jsonrpc":"2.0","method":"notifications/message","params":{"level":"warning","data":"slow down"}}`+"\n\n")
+				}
+				_, _ = fmt.Fprint(w, "event: message\ndata: "+respond(`{"content":[{"type":"text","text":"ok"}],"isError":false}`)+"\n\n")
+			default:
+				w.WriteHeader(http.StatusAccepted)
+			}
+		}))
+	}
+	current := fakeServer("2025-06-18")
+	defer current.Close()
+	deprecated := fakeServer("2024-11-05")
+	defer deprecated.Close()
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+	conforming := httptest.NewServer(handler)
+	defer conforming.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const lenient = mcp.StreamableHTTPClient({base_url: "%[1]s"});
+    const strict = mcp.StreamableHTTPClient({base_url: "%[1]s", strict_mode: true});
+    const outdated = mcp.StreamableHTTPClient({base_url: "%[2]s", strict_mode: true});
+    const sdk = mcp.StreamableHTTPClient({base_url: "%[3]s", stateless: true, strict_mode: true});
+    const outcome = (fn) => {
+      try {
+        fn();
+        return "ok";
+      } catch (e) {
+        return String(e);
+      }
+    };
+    [
+      outcome(() => lenient.listTools()),
+      outcome(() => lenient.callTool({name: "echo", arguments: {warn: true}})),
+      outcome(() => strict.listTools()),
+      outcome(() => strict.callTool({name: "echo", arguments: {warn: true}})),
+      outcome(() => strict.callTool({name: "echo"})),
+      outcome(() => outdated.callTool({name: "echo"})),
+      outcome(() => outdated.callTool({name: "echo"})),
+      outcome(() => sdk.listTools() && sdk.callTool({name: "%[4]s", arguments: {id: 1}})),
+      // Concurrent calls only fail for the violations of their own requests.
+      strict.callToolMatrix("echo", {warn: [true, false]}, {concurrency: 2}).map((r) => r.key + "=" + (r.error || "ok")).join(","),
+    ].join("|");`, current.URL, deprecated.URL, conforming.URL, toolName),
+	)
+	require.NoError(t, err)
+	outcomes := strings.Split(v.String(), "|")
+	require.Len(t, outcomes, 9)
+	assert.Equal(t, []string{"ok", "ok"}, outcomes[:2])
+	assert.Contains(t, outcomes[2], "strict mode: the tools/list result has unknown fields tools[0].category")
+	assert.NotContains(t, outcomes[2], "nextCursorHint")
+	assert.Contains(t, outcomes[3], "strict mode: the server logged at level warning: slow down")
+	assert.Equal(t, "ok", outcomes[4])
+	assert.Contains(t, outcomes[5], "strict mode: the server negotiated the deprecated protocol version 2024-11-05")
+	assert.Equal(t, "ok", outcomes[6], "the deprecated version must only be reported once")
+	assert.Equal(t, "ok", outcomes[7], "results of the SDK server must not be flagged")
+	assert.Equal(t, "warn=true=strict mode: the server logged at level warning: slow down,warn=false=ok", outcomes[8])
+
+	var violations []string
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if kind, ok := sample.Tags.Get("strict_violation"); ok && sample.Metric.Name == "mcp_request_duration" {
+				violations = append(violations, kind)
+			}
+		}
+	}
+	assert.Equal(t, []string{"unknown_field", "log_warning", "deprecated", "log_warning"}, violations)
+}
+
+func TestToolCacheFile(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+	var lists atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			jsonReq, err := parseJSONRPCBody(r)
+			require.NoError(t, err)
+			if jsonReq.Method == "tools/list" {
+				lists.Add(1)
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "tools.json")
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`mcp.StreamableHTTPClient({base_url: "%s", stateless: true}).dumpToolCache(%q);`, ts.URL, path),
+	)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), lists.Load())
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"name": "`+toolName+`"`)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      tool_cache_file: %q
+    });
+    const tool = client.getTool("%s");
+    const summary = client.fuzzTool("%[3]s", {count: 1, seed: 1});
+    [tool.name, tool.input_schema.required.join(","), summary.calls].join("|");`, ts.URL, path, toolName),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, toolName+"|id|1", v.String())
+	assert.Equal(t, int32(1), lists.Load(), "cached tools must be served without tools/list")
+
+	_, err = tc.runtime.VU.Runtime().RunString(`client.getTool("missing");`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `tool "missing" not found`)
+	assert.Equal(t, int32(2), lists.Load())
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`mcp.StreamableHTTPClient({base_url: "%s", tool_cache_file: "missing.json"});`, ts.URL),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to load tool_cache_file")
+}
+
+func TestServerProcessingDuration(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "timed"}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		return &mcpsdk.CallToolResult{
+			Meta:    mcpsdk.Meta{"serverDurationMs": 12.5, "computeMs": 4},
+			Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "ok"}},
+		}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({base_url: "%[1]s", stateless: true});
+    const custom = mcp.StreamableHTTPClient({base_url: "%[1]s", stateless: true, server_duration_meta_key: "computeMs"});
+    client.callTool({name: "timed", arguments: {id: 1}});
+    client.listTools();
+    custom.callTool({name: "timed", arguments: {id: 1}});`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	var durations []float64
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name == "mcp_server_processing_duration" {
+				method, _ := sample.Tags.Get("method")
+				assert.Equal(t, "tools/call", method)
+				durations = append(durations, sample.Value)
+			}
+		}
+	}
+	assert.Equal(t, []float64{12.5, 4}, durations)
+}
+
+func TestConnectJitter(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`for (let i = 0; i < 5; i++) {
+      mcp.StreamableHTTPClient({base_url: "%s", stateless: true, connect_jitter: "20ms"}).listTools();
+    }`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	var delays []float64
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name == "mcp_connect_jitter" {
+				delays = append(delays, sample.Value)
+			}
+		}
+	}
+	require.Len(t, delays, 5)
+	for _, delay := range delays {
+		assert.GreaterOrEqual(t, delay, 0.0)
+		assert.LessOrEqual(t, delay, 20.0)
+	}
+}
+
+func TestHTTPStatusError(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			jsonReq, err := parseJSONRPCBody(r)
+			require.NoError(t, err)
+			if jsonReq.Method == "tools/call" {
+				http.Error(w, "overloaded", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.listTools();
+    let failure;
+    try {
+      client.callTool({name: "%s", arguments: {id: 1}});
+    } catch (e) {
+      failure = [e.value.status_code, e.message].join("|");
+    }
+    failure;`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+	parts := strings.SplitN(v.String(), "|", 2)
+	require.Len(t, parts, 2)
+	assert.Equal(t, "503", parts[0])
+	assert.Contains(t, parts[1], "Service Unavailable (HTTP status 503)")
+
+	statuses := map[string]string{}
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name == "mcp_request_duration" {
+				method, _ := sample.Tags.Get("method")
+				statuses[method], _ = sample.Tags.Get("http_status")
+			}
+		}
+	}
+	assert.Equal(t, map[string]string{"tools/list": "", "tools/call": "503"}, statuses)
+}
+
+func TestToolTimeoutHint(t *testing.T) {
+	slow := func(ctx context.Context, _ *mcpsdk.CallToolRequest, _ MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "done"}}}, nil, nil
+	}
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "report", Meta: mcpsdk.Meta{"timeoutMs": 2000}}, slow)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "overridden", Meta: mcpsdk.Meta{"timeoutMs": 2000}}, slow)
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      timeout: "20ms",
+      method_timeouts: {"overridden": "20ms"}
+    });
+    const bounded = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      method_timeouts: {"tools/call": "20ms"}
+    });
+    const outcome = (client, name) => {
+      try {
+        return client.callTool({name: name, arguments: {id: 1}}).content[0].text;
+      } catch (e) {
+        return String(e);
+      }
+    };
+    const unlisted = outcome(client, "report");
+    client.listTools();
+    bounded.listTools();
+    [unlisted, outcome(client, "report"), outcome(client, "overridden"), outcome(bounded, "report")];`, ts.URL, ts.URL),
+	)
+	require.NoError(t, err)
+
+	results := v.Export().([]any)
+	assert.Contains(t, results[0], "context deadline exceeded", "hints are only known once the tool is listed")
+	assert.Equal(t, "done", results[1], "hints take precedence over the client timeout")
+	assert.Contains(t, results[2], "context deadline exceeded", "tool entries take precedence over hints")
+	assert.Contains(t, results[3], "context deadline exceeded", "method entries take precedence over hints")
+}
+
+func TestEndpointCircuitBreaker(t *testing.T) {
+	var toolCalls atomic.Int32
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			jsonReq, err := parseJSONRPCBody(r)
+			require.NoError(t, err)
+			if jsonReq.Method == "tools/call" {
+				toolCalls.Add(1)
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const config = {
+      base_url: "%s",
+      stateless: true,
+      circuit_breaker: {failure_threshold: 2, open_duration: "1m", scope: "endpoint"}
+    };
+    const first = mcp.StreamableHTTPClient(config);
+    const second = mcp.StreamableHTTPClient(config);
+    const errors = [];
+    for (const client of [first, first, second]) {
+      try {
+        client.callTool({name: "%s", arguments: {id: 1}});
+      } catch (e) {
+        errors.push(String(e));
+      }
+    }
+    errors[2];`, ts.URL, toolName+"bad"),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), toolCalls.Load(), "the breaker opened by the first client must stop the second")
+	assert.Contains(t, v.String(), "circuit breaker is open")
+
+	var endpoints []string
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name == "mcp_circuit_state" {
+				endpoint, _ := sample.Tags.Get("endpoint")
+				endpoints = append(endpoints, endpoint)
+				assert.Equal(t, 2.0, sample.Value)
+			}
+		}
+	}
+	assert.Equal(t, []string{ts.URL}, endpoints)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		`mcp.StdioClient({path: "./server", circuit_breaker: {failure_threshold: 1, open_duration: "1s", scope: "endpoint"}});`,
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "circuit_breaker.scope endpoint is not supported for stdio clients")
+}
+
+func TestReadResourceVerified(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	hashes := map[string]mcpsdk.Meta{
+		"data:ok":   {"hash": "sha256:2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824"},
+		"data:bad":  {"hash": "0000"},
+		"data:none": nil,
+	}
+	for uri, meta := range hashes {
+		server.AddResource(&mcpsdk.Resource{URI: uri, Name: uri}, func(context.Context, *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+			return &mcpsdk.ReadResourceResult{
+				Meta:     mcpsdk.Meta{"digest": "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"},
+				Contents: []*mcpsdk.ResourceContents{{URI: uri, Text: "hello", Meta: meta}},
+			}, nil
+		})
+	}
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({base_url: "%s", stateless: true});
+    const outcomes = [
+      client.readResourceVerified("data:ok").integrity,
+      client.readResourceVerified("data:none").integrity,
+      client.readResourceVerified("data:none", {meta_key: "digest", algorithm: "sha1"}).integrity,
+    ];
+    const bad = client.readResourceVerified("data:bad");
+    if (bad.integrity !== "mismatch" || bad.mismatches.join() !== "data:bad" || bad.contents[0].text !== "hello") {
+      throw new Error("unexpected mismatch result: " + JSON.stringify(bad));
+    }
+    if (outcomes.join() !== "ok,missing,ok") {
+      throw new Error("unexpected outcomes: " + outcomes.join());
+    }`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	_, err = tc.runtime.VU.Runtime().RunString(`client.readResourceVerified("data:ok", {algorithm: "crc32"});`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported hash algorithm "crc32"`)
+
+	var integrity []string
+	var mismatches int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			switch sample.Metric.Name {
+			case "mcp_request_count":
+				if tag, ok := sample.Tags.Get("integrity"); ok {
+					integrity = append(integrity, tag)
+				}
+			case "mcp_integrity_mismatches":
+				method, _ := sample.Tags.Get("method")
+				assert.Equal(t, "resources/read", method)
+				mismatches++
+			}
+		}
+	}
+	assert.Equal(t, []string{"ok", "missing", "ok", "mismatch"}, integrity)
+	assert.Equal(t, 1, mismatches)
+}
+
+func TestMetricTags(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      metric_tags: ["method", "row"]
+    });
+    client.callTool({name: "%s", arguments: {id: 1}}, {tags: {row: "42", dataset: "users"}});`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	var calls int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if !strings.HasPrefix(sample.Metric.Name, "mcp_") {
+				continue
+			}
+			// group is a tag of the VU, which is always kept.
+			for name := range sample.Tags.Map() {
+				assert.Contains(t, []string{"method", "row", "group"}, name, sample.Metric.Name)
+			}
+			if method, _ := sample.Tags.Get("method"); sample.Metric.Name == "mcp_request_duration" && method == mcp.CallToolMethod {
+				row, _ := sample.Tags.Get("row")
+				assert.Equal(t, "42", row)
+				calls++
+			}
+		}
+	}
+	assert.Equal(t, 1, calls)
+}
+
+func TestSessionPoolIdleTimeout(t *testing.T) {
+	var initializeCalls atomic.Int32
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			jsonReq, err := parseJSONRPCBody(r)
+			require.NoError(t, err)
+			if jsonReq.Method == "initialize" {
+				initializeCalls.Add(1)
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const pool = new mcp.SessionPool({
+      transport: "streamable-http",
+      size: 3,
+      min_size: 1,
+      idle_timeout: "50ms",
+      client: {
+        base_url: "%s",
+        stateless: true
+      }
+    });
+    const clients = [pool.acquire(), pool.acquire(), pool.acquire()];
+    clients.forEach((client) => client.release());`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), initializeCalls.Load())
+
+	var sizes []float64
+	require.Eventually(t, func() bool {
+		for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+			for _, sample := range sampleContainer.GetSamples() {
+				if sample.Metric.Name == "mcp_pool_size" {
+					sizes = append(sizes, sample.Value)
+				}
+			}
+		}
+		return len(sizes) > 0 && sizes[len(sizes)-1] == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 3.0, slices.Max(sizes))
+
+	_, err = tc.runtime.VU.Runtime().RunString(`pool.acquire(); pool.acquire();`)
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), initializeCalls.Load())
+
+	_, err = tc.runtime.VU.Runtime().RunString(`new mcp.SessionPool({transport: "streamable-http", size: 1, min_size: 2, client: {base_url: "http://localhost"}});`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "min_size must be between 0 and size")
+}
+
+func TestGetPromptMessages(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	server.AddPrompt(&mcpsdk.Prompt{Name: "describe"}, func(context.Context, *mcpsdk.GetPromptRequest) (*mcpsdk.GetPromptResult, error) {
+		return &mcpsdk.GetPromptResult{
+			Messages: []*mcpsdk.PromptMessage{
+				{Role: "user", Content: &mcpsdk.TextContent{Text: "describe this image"}},
+				{Role: "user", Content: &mcpsdk.ImageContent{Data: []byte{0x89, 'P', 'N', 'G'}, MIMEType: "image/png"}},
+				{Role: "assistant", Content: &mcpsdk.ResourceLink{URI: "embedded:info", Name: "info"}},
+			},
+		}, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    const messages = client.getPromptMessages({name: "describe"});
+    const types = messages.map((msg) => msg.role + ":" + msg.content.type).join();
+    if (types !== "user:text,user:image,assistant:resource_link") {
+      throw new Error("unexpected messages: " + types);
+    }
+    if (messages[0].content.text !== "describe this image") {
+      throw new Error("unexpected text: " + messages[0].content.text);
+    }
+    if (messages[1].content.mimeType !== "image/png" || messages[1].content.data !== "iVBORw==") {
+      throw new Error("unexpected image: " + JSON.stringify(messages[1].content));
+    }
+    if (messages[2].content.uri !== "embedded:info") {
+      throw new Error("unexpected resource link: " + JSON.stringify(messages[2].content));
+    }`, ts.URL),
+	)
+	require.NoError(t, err)
+}
+
+func TestProbeColdStart(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const res = mcp.probeColdStart({client: {base_url: "%[1]s", stateless: true}}, {name: "%[2]s", arguments: {id: 1}});
+    if (!(res.connect_ms > 0 && res.list_ms > 0 && res.call_ms > 0)) {
+      throw new Error("unexpected phases: " + JSON.stringify(res));
+    }
+    if (Math.abs(res.total_ms - res.connect_ms - res.list_ms - res.call_ms) > 0.001) {
+      throw new Error("total is not the sum of the phases: " + JSON.stringify(res));
+    }`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+
+	var phases []string
+	var calls int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			switch sample.Metric.Name {
+			case "mcp_cold_start_duration":
+				phase, _ := sample.Tags.Get("phase")
+				phases = append(phases, phase)
+			case "mcp_request_count":
+				calls++
+			}
+		}
+	}
+	assert.Equal(t, []string{"connect", "list", "call", "total"}, phases)
+	assert.Equal(t, 2, calls)
+
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`mcp.probeColdStart({client: {base_url: "%s", stateless: true}}, {name: "missing"});`, ts.URL),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to call tool")
+}
+
+func TestReadResourceConditional(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	server.AddResource(&mcpsdk.Resource{URI: "embedded:info", Name: "info"}, func(context.Context, *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+		return &mcpsdk.ReadResourceResult{
+			Contents: []*mcpsdk.ResourceContents{{URI: "embedded:info", Text: "info"}},
+		}, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true, JSONResponse: true})
+
+	var conditions []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			jsonReq, err := parseJSONRPCBody(r)
+			require.NoError(t, err)
+			if jsonReq.Method == "resources/read" {
+				conditions = append(conditions, r.Header.Get("If-None-Match"))
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", `"v1"`)
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({base_url: "%s", stateless: true});
+    const first = client.readResourceConditional("embedded:info", "");
+    if (first.not_modified || first.etag !== '"v1"' || first.contents[0].text !== "info") {
+      throw new Error("unexpected first read: " + JSON.stringify(first));
+    }
+    const second = client.readResourceConditional("embedded:info", first.etag);
+    if (!second.not_modified || second.etag !== '"v1"' || second.contents.length !== 0) {
+      throw new Error("unexpected second read: " + JSON.stringify(second));
+    }
+    const stale = client.readResourceConditional("embedded:info", '"v0"');
+    if (stale.not_modified || stale.contents[0].text !== "info") {
+      throw new Error("unexpected stale read: " + JSON.stringify(stale));
+    }`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", `"v1"`, `"v0"`}, conditions)
+
+	var outcomes []string
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name == "mcp_request_count" {
+				if conditional, ok := sample.Tags.Get("conditional"); ok {
+					outcomes = append(outcomes, conditional)
+				}
+			}
+		}
+	}
+	assert.Equal(t, []string{"miss", "hit", "miss"}, outcomes)
+}
+
+func TestTotalBytes(t *testing.T) {
+	handler, err := streamableHandler(t)
+	require.NoError(t, err)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	totals, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const a = mcp.StreamableHTTPClient({base_url: "%[1]s", stateless: true});
+    const b = mcp.StreamableHTTPClient({base_url: "%[1]s", stateless: true});
+    a.callTool({name: "%[2]s", arguments: {id: 1}});
+    b.listTools();
+    const total = mcp.totalBytes();
+    const stats = [a.stats(), b.stats()];
+    if (total.in !== stats[0].bytes_received + stats[1].bytes_received) {
+      throw new Error("unexpected bytes in: " + JSON.stringify({total, stats}));
+    }
+    if (total.out !== stats[0].bytes_sent + stats[1].bytes_sent) {
+      throw new Error("unexpected bytes out: " + JSON.stringify({total, stats}));
+    }
+    total;`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+	total, ok := totals.Export().(mcp.TotalBytes)
+	require.True(t, ok)
+	assert.Positive(t, total.In)
+	assert.Positive(t, total.Out)
+
+	recorded := map[string]int64{}
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if sample.Metric.Name == "mcp_total_bytes" {
+				direction, _ := sample.Tags.Get("direction")
+				recorded[direction] += int64(sample.Value)
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{"in": total.In, "out": total.Out}, recorded)
+}
+
+func TestProtocolVersionFallback(t *testing.T) {
+	inputSchema, err := jsonschema.For[MyToolInput](nil)
+	require.NoError(t, err)
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: toolName, InputSchema: inputSchema}, func(context.Context, *mcpsdk.CallToolRequest, MyToolInput) (*mcpsdk.CallToolResult, any, error) {
+		return nil, MyToolOutput{toolName}, nil
+	})
+	// The server only supports 2025-03-26, which it answers with whatever
+	// version the client asks for.
+	server.AddReceivingMiddleware(func(next mcpsdk.MethodHandler) mcpsdk.MethodHandler {
+		return func(ctx context.Context, method string, req mcpsdk.Request) (mcpsdk.Result, error) {
+			res, err := next(ctx, method, req)
+			if init, ok := res.(*mcpsdk.InitializeResult); ok {
+				init.ProtocolVersion = "2025-03-26"
+			}
+			return res, err
+		}
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+	hook := logtest.NewLocal(tc.logger)
+
+	version, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({base_url: "%s", stateless: true});
+    client.callTool({name: "%s", arguments: {id: 1}});
+    client.protocolVersion();`, ts.URL, toolName),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-03-26", version.String())
+
+	var downgrades int
+	for _, entry := range hook.AllEntries() {
+		if entry.Message == "MCP server negotiated an older protocol version" {
+			assert.Equal(t, "2025-03-26", entry.Data["protocol_version"])
+			assert.NotEmpty(t, entry.Data["requested_version"])
+			downgrades++
+		}
+	}
+	assert.Equal(t, 1, downgrades)
+
+	// Strict clients refuse the downgrade when connecting, once.
+	_, err = tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`mcp.StreamableHTTPClient({base_url: "%s", stateless: true, strict_mode: true});`, ts.URL),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection error: strict mode: the server negotiated the protocol version 2025-03-26, older than the requested")
+}
+
+func TestWatchResource(t *testing.T) {
+	const uri = "test://counter"
+
+	t.Run("subscribe", func(t *testing.T) {
+		var version atomic.Int64
+		subscribed := make(chan struct{})
+		server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, &mcpsdk.ServerOptions{
+			SubscribeHandler: func(context.Context, *mcpsdk.SubscribeRequest) error {
+				close(subscribed)
+				return nil
+			},
+			UnsubscribeHandler: func(context.Context, *mcpsdk.UnsubscribeRequest) error {
+				return nil
+			},
+		})
+		server.AddResource(&mcpsdk.Resource{URI: uri, Name: "counter"}, func(context.Context, *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+			return &mcpsdk.ReadResourceResult{Contents: []*mcpsdk.ResourceContents{{URI: uri, Text: fmt.Sprint(version.Load())}}}, nil
+		})
+		mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "touch"}, func(ctx context.Context, _ *mcpsdk.CallToolRequest, _ any) (*mcpsdk.CallToolResult, any, error) {
+			select {
+			case <-subscribed:
+			case <-time.After(5 * time.Second):
+				return nil, nil, errors.New("the resource was not subscribed to")
+			}
+			version.Add(1)
+			return &mcpsdk.CallToolResult{}, nil, server.ResourceUpdated(ctx, &mcpsdk.ResourceUpdatedNotificationParams{URI: uri})
+		})
+		handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+			return server
+		}, nil)
+
+		ts := httptest.NewServer(handler)
+		defer ts.Close()
+		// The standalone event stream stays open for as long as the client lives.
+		defer ts.CloseClientConnections()
+
+		tc := setupTest(t)
+
+		// The first change triggers two more while onChange is busy, which
+		// must reach it as one.
+		_, err := tc.runtime.RunOnEventLoop(
+			fmt.Sprintf(`var result = { versions: [] };
+    const client = mcp.StreamableHTTPClient({
+      base_url: "%s"
+    });
+    client.watchResource("%s", (res) => {
+      const version = res.contents[0].text;
+      result.versions.push(version);
+      if (version === "1") {
+        client.drainNotifications();
+        client.callTool({name: "touch"});
+        client.callTool({name: "touch"});
+        let updates = 0;
+        while (updates < 2) {
+          updates += client.drainNotifications().filter((n) => n.method === "notifications/resources/updated").length;
+        }
+      }
+      return version !== "3";
+    }, { timeout_ms: 5000 }).then((r) => { result.watch = r; });
+    client.callTool({name: "touch"});`, ts.URL, uri),
+		)
+		require.NoError(t, err)
+
+		v, err := tc.runtime.VU.Runtime().RunString(`JSON.stringify(result)`)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"versions": ["1", "3"], "watch": {"mode": "subscribe", "changes": 2, "coalesced": 1}}`, v.String())
+		assert.Equal(t, 2, countWatchLatencySamples(tc.samples, "subscribe"))
+	})
+
+	t.Run("poll", func(t *testing.T) {
+		var reads atomic.Int64
+		server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+		// The resource changes every third read.
+		server.AddResource(&mcpsdk.Resource{URI: uri, Name: "counter"}, func(context.Context, *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+			return &mcpsdk.ReadResourceResult{Contents: []*mcpsdk.ResourceContents{{URI: uri, Text: fmt.Sprint(reads.Add(1) / 3)}}}, nil
+		})
+		handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+			return server
+		}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+		ts := httptest.NewServer(handler)
+		defer ts.Close()
+
+		tc := setupTest(t)
+
+		_, err := tc.runtime.RunOnEventLoop(
+			fmt.Sprintf(`var result = { versions: [] };
+    const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    client.watchResource("%s", (res) => {
+      result.versions.push(res.contents[0].text);
+    }, { max_changes: 2, min_interval_ms: 5, max_interval_ms: 20 }).then((r) => { result.watch = r; });`, ts.URL, uri),
+		)
+		require.NoError(t, err)
+
+		v, err := tc.runtime.VU.Runtime().RunString(`JSON.stringify(result)`)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"versions": ["1", "2"], "watch": {"mode": "poll", "changes": 2, "coalesced": 0}}`, v.String())
+		assert.Equal(t, 2, countWatchLatencySamples(tc.samples, "poll"))
+	})
+
+	t.Run("handlers", func(t *testing.T) {
+		server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+		// Every read asks the client to sample the content of the resource.
+		server.AddResource(&mcpsdk.Resource{URI: uri, Name: "counter"}, func(ctx context.Context, req *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+			res, err := req.Session.CreateMessage(ctx, &mcpsdk.CreateMessageParams{
+				Messages: []*mcpsdk.SamplingMessage{{Role: "user", Content: &mcpsdk.TextContent{Text: "count"}}},
+			})
+			if err != nil {
+				return nil, err
+			}
+			text, _ := res.Content.(*mcpsdk.TextContent)
+			return &mcpsdk.ReadResourceResult{Contents: []*mcpsdk.ResourceContents{{URI: uri, Text: text.Text}}}, nil
+		})
+		handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+			return server
+		}, nil)
+
+		ts := httptest.NewServer(handler)
+		defer ts.Close()
+		// The standalone event stream stays open for as long as the client lives.
+		defer ts.CloseClientConnections()
+
+		tc := setupTest(t)
+
+		_, err := tc.runtime.RunOnEventLoop(
+			fmt.Sprintf(`var result = { versions: [] };
+    let sampled = 0;
+    const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      on_create_message: () => ({ role: "assistant", model: "test", content: { type: "text", text: String(++sampled) } })
+    });
+    client.watchResource("%s", (res) => {
+      result.versions.push(res.contents[0].text);
+    }, { max_changes: 2, min_interval_ms: 5, max_interval_ms: 20 }).then((r) => { result.watch = r; });`, ts.URL, uri),
+		)
+		require.NoError(t, err)
+
+		v, err := tc.runtime.VU.Runtime().RunString(`JSON.stringify(result)`)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"versions": ["2", "3"], "watch": {"mode": "poll", "changes": 2, "coalesced": 0}}`, v.String())
+	})
+}
+
+func countWatchLatencySamples(samples chan k6metrics.SampleContainer, mode string) int {
+	var n int
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if got, _ := sample.Tags.Get("mode"); sample.Metric.Name == "mcp_watch_latency" && got == mode {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestLogFile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			ID     int            `json:"id"`
+			Method string         `json:"method"`
+			Params map[string]any `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		respond := func(result string) string {
+			return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":%s}`, req.ID, result)
+		}
+
+		switch req.Method {
+		case "initialize":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprint(w, respond(`{"protocolVersion":"2025-06-18","capabilities":{"logging":{}},"serverInfo":{"name":"fake","version":"1.0.0"}}`))
+		case "tools/call":
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprintf(w, "event: message\ndata: "+
+				`{"jsonrpc":"2.0","method":"notifications/message","params":{"level":"info","logger":"tools","data":{"tool":%q}}}`+"\n\n", req.Params["name"])
+			_, _ = fmt.Fprint(w, "event: message\ndata: "+respond(`{"content":[{"type":"text","text":"ok"}],"isError":false}`)+"\n\n")
+		default:
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer ts.Close()
+
+	tc := setupTest(t)
+	path := filepath.Join(t.TempDir(), "logs.jsonl")
+
+	_, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const config = {base_url: "%s", stateless: true, log_file: %q};
+    const first = mcp.StreamableHTTPClient(config);
+    const second = mcp.StreamableHTTPClient(config);
+    first.callTool({name: "first"});
+    second.callTool({name: "second"});`, ts.URL, path),
+	)
+	require.NoError(t, err)
+
+	// Notifications are handled off the call, which may return first.
+	var lines []string
+	require.Eventually(t, func() bool {
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		lines = strings.Split(strings.TrimSpace(string(raw)), "\n")
+		return len(lines) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	slices.Sort(lines)
+
+	type record struct {
+		Level  string
+		Logger string
+		Data   map[string]any
+		Ts     time.Time
+	}
+	for i, tool := range []string{"first", "second"} {
+		var rec record
+		require.NoError(t, json.Unmarshal([]byte(lines[i]), &rec))
+		assert.Equal(t, "info", rec.Level)
+		assert.Equal(t, "tools", rec.Logger)
+		assert.Equal(t, map[string]any{"tool": tool}, rec.Data)
+		assert.WithinDuration(t, time.Now(), rec.Ts, time.Minute)
+	}
+}
+
+func TestBenchmarkReadsByType(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	for _, r := range []struct {
+		uri, mimeType string
+		delay         time.Duration
+	}{
+		{"file:///a.txt", "text/plain", 0},
+		{"file:///b.txt", "text/plain", 0},
+		{"file:///c.png", "image/png", 5 * time.Millisecond},
+	} {
+		server.AddResource(&mcpsdk.Resource{URI: r.uri, Name: r.uri, MIMEType: r.mimeType}, func(context.Context, *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+			time.Sleep(r.delay)
+			return &mcpsdk.ReadResourceResult{Contents: []*mcpsdk.ResourceContents{{URI: r.uri, MIMEType: r.mimeType, Text: "content"}}}, nil
+		})
+	}
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true
+    });
+    JSON.stringify(client.benchmarkReadsByType({duration: "100ms"}));`, ts.URL),
+	)
+	require.NoError(t, err)
+
+	var summaries []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(v.String()), &summaries))
+	require.Len(t, summaries, 2)
+
+	reads := map[string]int{}
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if mimeType, ok := sample.Tags.Get("mime_type"); ok && sample.Metric.Name == "mcp_request_count" {
+				reads[mimeType]++
+			}
+		}
+	}
+
+	image, text := summaries[0], summaries[1]
+	assert.Equal(t, "image/png", image["mime_type"])
+	assert.Equal(t, "text/plain", text["mime_type"])
+	assert.EqualValues(t, 1, image["resources"])
+	assert.EqualValues(t, 2, text["resources"])
+	assert.Positive(t, image["reads"])
+	// Types are read in turn, so they get the same number of reads.
+	assert.Equal(t, image["reads"], text["reads"])
+	assert.EqualValues(t, reads["image/png"], image["reads"])
+	assert.EqualValues(t, reads["text/plain"], text["reads"])
+	assert.EqualValues(t, 0, image["errors"])
+	assert.GreaterOrEqual(t, image["min_ms"], 5.0)
+	assert.GreaterOrEqual(t, image["p95_ms"], image["min_ms"])
+	assert.GreaterOrEqual(t, image["max_ms"], image["p95_ms"])
+	assert.Greater(t, text["reads_per_second"], image["reads_per_second"])
+}
+
+func TestCallToolMatrix(t *testing.T) {
+	type greetInput struct {
+		Lang   string `json:"lang"`
+		Formal bool   `json:"formal"`
+	}
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "greet"}, func(_ context.Context, _ *mcpsdk.CallToolRequest, in greetInput) (*mcpsdk.CallToolResult, any, error) {
+		if in.Lang == "xx" {
+			return nil, nil, errors.New("unknown language")
+		}
+		return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{
+			&mcpsdk.TextContent{Text: fmt.Sprintf("%s/%t", in.Lang, in.Formal)},
+		}}, nil, nil
+	})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server {
+		return server
+	}, &mcpsdk.StreamableHTTPOptions{Stateless: true})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tc := setupTest(t)
+
+	v, err := tc.runtime.VU.Runtime().RunString(
+		fmt.Sprintf(`const client = mcp.StreamableHTTPClient({
+      base_url: "%s",
+      stateless: true,
+      throw_on_tool_error: true
+    });
+    const results = client.callToolMatrix("greet", {lang: ["en", "fr", "xx"], formal: [true, false]}, {concurrency: 3});
+    JSON.stringify(results.map((r) => [r.key, r.arguments.lang, r.result ? r.result.content[0].text : r.error]));`, ts.URL),
+	)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+    ["formal=true,lang=en", "en", "en/true"],
+    ["formal=true,lang=fr", "fr", "fr/true"],
+    ["formal=true,lang=xx", "xx", "tool \"greet\" failed: unknown language"],
+    ["formal=false,lang=en", "en", "en/false"],
+    ["formal=false,lang=fr", "fr", "fr/false"],
+    ["formal=false,lang=xx", "xx", "tool \"greet\" failed: unknown language"]
+  ]`, v.String())
+
+	tagged := map[string]int{}
+	for _, sampleContainer := range k6metrics.GetBufferedSamples(tc.samples) {
+		for _, sample := range sampleContainer.GetSamples() {
+			if matrix, ok := sample.Tags.Get("matrix"); ok && sample.Metric.Name == "mcp_request_count" {
+				tagged[matrix]++
+			}
+		}
+	}
+	assert.Len(t, tagged, 6)
+	assert.Equal(t, 1, tagged["formal=false,lang=xx"])
+}
@@ -0,0 +1,446 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// The kinds of strict mode violations, which calls failing for them are
+// tagged with as strict_violation.
+const (
+	strictUnknownField = "unknown_field"
+	strictDeprecated   = "deprecated"
+	strictLogWarning   = "log_warning"
+	strictDowngrade    = "version_downgrade"
+)
+
+// deprecatedProtocolVersion is the protocol version superseded by 2025-03-26,
+// which deprecated its HTTP+SSE transport.
+const deprecatedProtocolVersion = "2024-11-05"
+
+// strictResults returns the result types whose fields are checked in strict
+// mode, by method.
+var strictResults = map[string]func() any{
+	InitializeMethod:           func() any { return &mcp.InitializeResult{} },
+	ListToolsMethod:            func() any { return &mcp.ListToolsResult{} },
+	CallToolMethod:             func() any { return &mcp.CallToolResult{} },
+	ListResourcesMethod:        func() any { return &mcp.ListResourcesResult{} },
+	"resources/templates/list": func() any { return &mcp.ListResourceTemplatesResult{} },
+	ReadResourceMethod:         func() any { return &mcp.ReadResourceResult{} },
+	ListPromptsMethod:          func() any { return &mcp.ListPromptsResult{} },
+	GetPromptMethod:            func() any { return &mcp.GetPromptResult{} },
+	"completion/complete":      func() any { return &mcp.CompleteResult{} },
+}
+
+// strictLevels are the logging levels of notifications/message that are
+// violations in strict mode.
+var strictLevels = []mcp.LoggingLevel{"warning", "error", "critical", "alert", "emergency"}
+
+// strictViolation fails a call in strict mode.
+type strictViolation struct {
+	kind    string
+	message string
+}
+
+func (v *strictViolation) Error() string {
+	return "strict mode: " + v.message
+}
+
+// strictViolations collects the violations to be reported by one call, or by
+// the next call for those of the session.
+type strictViolations struct {
+	mu   sync.Mutex
+	list []*strictViolation
+}
+
+type strictCallKey struct{}
+
+// strictChecker looks for strict mode violations in the messages exchanged
+// with the server. Violations caused by the request of a call, or by its
+// response or the messages sent along with it, are kept for that call. The
+// others, such as those of the initialize handshake or of notifications sent
+// on their own, are kept for the next call to start.
+type strictChecker struct {
+	mu sync.Mutex
+	// methods holds the methods of the requests awaiting a response, by ID
+	methods map[any]string
+	// calls holds the violations of the calls awaiting a response, by the ID
+	// of their request
+	calls   map[any]*strictViolations
+	session strictViolations
+}
+
+func newStrictChecker() *strictChecker {
+	return &strictChecker{
+		methods: make(map[any]string),
+		calls:   make(map[any]*strictViolations),
+	}
+}
+
+// strictly returns fn, failing with the strict mode violations found in the
+// messages of its requests, and with those of the session found before it
+// started.
+func strictly[T any](c *Client, fn func(context.Context) (T, error)) func(context.Context) (T, error) {
+	if c.strict == nil {
+		return fn
+	}
+	return func(ctx context.Context) (T, error) {
+		call := &strictViolations{list: c.strict.session.takeAll()}
+		res, err := fn(context.WithValue(ctx, strictCallKey{}, call))
+		c.strict.forget(call)
+		if v := call.take(); v != nil {
+			setResultTag(ctx, "strict_violation", v.kind)
+			var zero T
+			return zero, v
+		}
+		return res, err
+	}
+}
+
+// checkNegotiatedVersion fails in strict mode when the server negotiated an
+// older protocol version than the client requested, rather than letting the
+// client fall back to it. The deprecated version is reported by the first
+// call instead.
+func (c *Client) checkNegotiatedVersion() error {
+	if c.strict == nil {
+		return nil
+	}
+	requested, negotiated := c.session.initializeParams.ProtocolVersion, c.session.InitializeResult().ProtocolVersion
+	if negotiated == deprecatedProtocolVersion {
+		c.strict.session.add(strictDeprecated, fmt.Sprintf("the server negotiated the deprecated protocol version %s", negotiated))
+		return nil
+	}
+	if !downgraded(requested, negotiated) {
+		return nil
+	}
+	return &strictViolation{
+		kind:    strictDowngrade,
+		message: fmt.Sprintf("the server negotiated the protocol version %s, older than the requested %s", negotiated, requested),
+	}
+}
+
+func (v *strictViolations) add(kind, message string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.list = append(v.list, &strictViolation{kind: kind, message: message})
+}
+
+// takeAll returns the violations collected so far, and forgets them.
+func (v *strictViolations) takeAll() []*strictViolation {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	list := v.list
+	v.list = nil
+	return list
+}
+
+// take returns the violations collected so far as one, of the kind of the
+// first, or nil if there are none.
+func (v *strictViolations) take() *strictViolation {
+	list := v.takeAll()
+	if len(list) == 0 {
+		return nil
+	}
+	messages := make([]string, len(list))
+	for i, violation := range list {
+		messages[i] = violation.message
+	}
+	return &strictViolation{kind: list[0].kind, message: strings.Join(messages, "; ")}
+}
+
+// sent records the method of a request sent with ctx and, if ctx is the one
+// of a call, that the violations its response causes belong to that call.
+func (s *strictChecker) sent(ctx context.Context, msg jsonrpc.Message) {
+	req, ok := msg.(*jsonrpc.Request)
+	if !ok || !req.ID.IsValid() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methods[req.ID.Raw()] = req.Method
+	if call, ok := ctx.Value(strictCallKey{}).(*strictViolations); ok {
+		s.calls[req.ID.Raw()] = call
+	}
+}
+
+// received checks a message from the server. related is the ID of the
+// request the message was sent in response to, as when notifications are
+// streamed in the response to a request, or nil.
+func (s *strictChecker) received(msg jsonrpc.Message, related any) {
+	switch msg := msg.(type) {
+	case *jsonrpc.Response:
+		s.mu.Lock()
+		method, call := s.methods[msg.ID.Raw()], s.calls[msg.ID.Raw()]
+		delete(s.methods, msg.ID.Raw())
+		delete(s.calls, msg.ID.Raw())
+		s.mu.Unlock()
+
+		newResult, ok := strictResults[method]
+		if !ok || msg.Error != nil {
+			return
+		}
+		if fields := unknownFields(msg.Result, newResult()); len(fields) > 0 {
+			s.violations(call).add(strictUnknownField, fmt.Sprintf("the %s result has unknown fields %s", method, strings.Join(fields, ", ")))
+		}
+	case *jsonrpc.Request:
+		if msg.Method != "notifications/message" {
+			return
+		}
+		var params mcp.LoggingMessageParams
+		if err := json.Unmarshal(msg.Params, &params); err == nil && slices.Contains(strictLevels, params.Level) {
+			var call *strictViolations
+			if related != nil {
+				s.mu.Lock()
+				call = s.calls[related]
+				s.mu.Unlock()
+			}
+			s.violations(call).add(strictLogWarning, fmt.Sprintf("the server logged at level %s: %v", params.Level, params.Data))
+		}
+	}
+}
+
+// forget drops the requests of call left without a response, such as those
+// cancelled.
+func (s *strictChecker) forget(call *strictViolations) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, pending := range s.calls {
+		if pending == call {
+			delete(s.calls, id)
+			delete(s.methods, id)
+		}
+	}
+}
+
+// violations returns call, or the violations of the session if it is nil,
+// for messages that no call is waiting for.
+func (s *strictChecker) violations(call *strictViolations) *strictViolations {
+	if call == nil {
+		return &s.session
+	}
+	return call
+}
+
+// unknownFields returns the paths of the fields of raw that are lost when
+// decoding it into result, leaving out those holding empty values, which
+// encoding result back omits.
+func unknownFields(raw json.RawMessage, result any) []string {
+	if err := json.Unmarshal(raw, result); err != nil {
+		return nil
+	}
+	known, err := json.Marshal(result)
+	if err != nil {
+		return nil
+	}
+	var got, want any
+	if json.Unmarshal(raw, &got) != nil || json.Unmarshal(known, &want) != nil {
+		return nil
+	}
+
+	var fields []string
+	var walk func(path string, got, want any)
+	walk = func(path string, got, want any) {
+		switch got := got.(type) {
+		case map[string]any:
+			want, _ := want.(map[string]any)
+			for k, v := range got {
+				if w, ok := want[k]; ok {
+					walk(path+"."+k, v, w)
+				} else if !isEmptyJSON(v) {
+					fields = append(fields, strings.TrimPrefix(path+"."+k, "."))
+				}
+			}
+		case []any:
+			want, _ := want.([]any)
+			for i, v := range got {
+				if i < len(want) {
+					walk(fmt.Sprintf("%s[%d]", path, i), v, want[i])
+				}
+			}
+		}
+	}
+	walk("", got, want)
+	sort.Strings(fields)
+	return fields
+}
+
+func isEmptyJSON(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		return v == ""
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
+
+// strictTransport checks the messages of connections whose transport cannot
+// be wrapped at the HTTP level.
+type strictTransport struct {
+	mcp.Transport
+	strict *strictChecker
+}
+
+func (t *strictTransport) Connect(ctx context.Context) (mcp.Connection, error) {
+	conn, err := t.Transport.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &strictConn{Connection: conn, strict: t.strict}, nil
+}
+
+type strictConn struct {
+	mcp.Connection
+	strict *strictChecker
+}
+
+func (c *strictConn) Read(ctx context.Context) (jsonrpc.Message, error) {
+	msg, err := c.Connection.Read(ctx)
+	if err == nil {
+		c.strict.received(msg, nil)
+	}
+	return msg, err
+}
+
+func (c *strictConn) Write(ctx context.Context, msg jsonrpc.Message) error {
+	c.strict.sent(ctx, msg)
+	return c.Connection.Write(ctx, msg)
+}
+
+// strictTransport returns transport with its messages checked for strict
+// mode violations. The connections of Streamable HTTP transports cannot be
+// wrapped without breaking them, so HTTP messages are checked as they go
+// over the wire instead.
+func (c *Client) strictTransport(transport mcp.Transport) mcp.Transport {
+	if c.strict == nil {
+		return transport
+	}
+	switch t := transport.(type) {
+	case *mcp.StreamableClientTransport:
+		t.HTTPClient.Transport = &strictRoundTripper{next: t.HTTPClient.Transport, strict: c.strict}
+		return t
+	case *mcp.SSEClientTransport:
+		t.HTTPClient.Transport = &strictRoundTripper{next: t.HTTPClient.Transport, strict: c.strict}
+		return t
+	default:
+		return &strictTransport{Transport: transport, strict: c.strict}
+	}
+}
+
+// strictRoundTripper checks the messages of request bodies, and of response
+// bodies as they are read, whether plain JSON or SSE event streams.
+type strictRoundTripper struct {
+	next   http.RoundTripper
+	strict *strictChecker
+}
+
+func (t *strictRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// related is the ID of the request posted, whose response body holds the
+	// messages sent in response to it.
+	var related any
+	if req.Body != nil && req.Method == http.MethodPost {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if msg, err := jsonrpc.DecodeMessage(body); err == nil {
+			t.strict.sent(req.Context(), msg)
+			if r, ok := msg.(*jsonrpc.Request); ok && r.ID.IsValid() {
+				related = r.ID.Raw()
+			}
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		resp.Body = &strictBody{ReadCloser: resp.Body, strict: t.strict, related: related}
+	case "text/event-stream":
+		resp.Body = &strictBody{ReadCloser: resp.Body, strict: t.strict, related: related, events: true}
+	}
+	return resp, nil
+}
+
+// strictBody checks the messages of a response body before handing them
+// over, the whole body once read for JSON, or every event for event streams.
+type strictBody struct {
+	io.ReadCloser
+	strict  *strictChecker
+	related any
+	events  bool
+
+	buf  []byte
+	data []byte
+}
+
+func (b *strictBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf = append(b.buf, p[:n]...)
+	if b.events {
+		b.scanEvents()
+	} else if err == io.EOF {
+		b.check(b.buf)
+	}
+	return n, err
+}
+
+// scanEvents checks the data of the complete events buffered so far.
+func (b *strictBody) scanEvents() {
+	for {
+		end := bytes.IndexByte(b.buf, '\n')
+		if end < 0 {
+			return
+		}
+		line := bytes.TrimRight(b.buf[:end], "\r")
+		b.buf = b.buf[end+1:]
+
+		if len(line) == 0 {
+			b.check(b.data)
+			b.data = nil
+			continue
+		}
+		if value, ok := sseField(line, "data"); ok {
+			if len(b.data) > 0 {
+				b.data = append(b.data, '\n')
+			}
+			b.data = append(b.data, value...)
+		}
+	}
+}
+
+func (b *strictBody) check(data []byte) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return
+	}
+	// Events that are not messages, such as the endpoint of SSE sessions,
+	// are left alone.
+	if msg, err := jsonrpc.DecodeMessage(data); err == nil {
+		b.strict.received(msg, b.related)
+	}
+}
@@ -0,0 +1,84 @@
+package mcp
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// toolTimeoutMetaKey is the _meta key of tool definitions holding the
+// timeout calls of the tool need, in milliseconds.
+const toolTimeoutMetaKey = "timeoutMs"
+
+// callTimeouts bounds the duration of calls. Entries for a tool name take
+// precedence over entries for a method, then over the timeout hint of the
+// tool, which takes precedence over the client default.
+type callTimeouts struct {
+	fallback time.Duration
+	byName   map[string]time.Duration
+}
+
+func newCallTimeouts(cfg ClientConfig) callTimeouts {
+	t := callTimeouts{fallback: durationOrZero(cfg.Timeout)}
+	if len(cfg.MethodTimeouts) > 0 {
+		t.byName = make(map[string]time.Duration, len(cfg.MethodTimeouts))
+		for key, value := range cfg.MethodTimeouts {
+			t.byName[key] = durationOrZero(value)
+		}
+	}
+	return t
+}
+
+// get returns the timeout of a call of method, made for the named tool if
+// name is set, or zero when the call is not bounded. hint is the timeout the
+// tool declares, if positive.
+func (t callTimeouts) get(method, name string, hint time.Duration) time.Duration {
+	if name != "" {
+		if d, ok := t.byName[name]; ok {
+			return d
+		}
+	}
+	if d, ok := t.byName[method]; ok {
+		return d
+	}
+	if hint > 0 {
+		return hint
+	}
+	return t.fallback
+}
+
+// toolTimeoutHint returns the timeout the named tool declares in the _meta of
+// its definition, if the tool was listed or loaded from a ToolCacheFile, or
+// zero.
+func (c *Client) toolTimeoutHint(name string) time.Duration {
+	if name == "" {
+		return 0
+	}
+	t, ok := c.tools.get(name)
+	if !ok {
+		return 0
+	}
+	ms, ok := t.Meta[toolTimeoutMetaKey].(float64)
+	if !ok || ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// checkMethodTimeouts reports every entry of timeouts that is not a valid
+// duration.
+func checkMethodTimeouts(timeouts map[string]string) []error {
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(timeouts)) {
+		value := timeouts[key]
+		if value == "" {
+			errs = append(errs, fmt.Errorf("method_timeouts.%s must not be empty", key))
+			continue
+		}
+		if err := checkDuration("method_timeouts."+key, value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
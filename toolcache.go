@@ -0,0 +1,100 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// toolCache holds the definitions of the tools of the server, by name, as
+// last listed or loaded from a ToolCacheFile.
+type toolCache struct {
+	mu    sync.RWMutex
+	tools map[string]*mcp.Tool
+}
+
+func (tc *toolCache) get(name string) (*mcp.Tool, bool) {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+	t, ok := tc.tools[name]
+	return t, ok
+}
+
+func (tc *toolCache) remember(tools []*mcp.Tool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.tools == nil {
+		tc.tools = make(map[string]*mcp.Tool, len(tools))
+	}
+	for _, t := range tools {
+		if t != nil {
+			tc.tools[t.Name] = t
+		}
+	}
+}
+
+// toolCacheFile is the content of a ToolCacheFile, which has the shape of a
+// tools/list result so that a captured one can be used as is.
+type toolCacheFile struct {
+	Tools []*mcp.Tool `json:"tools"`
+}
+
+// loadToolCache seeds the tool cache of c from the file at path, written by
+// DumpToolCache.
+func (c *Client) loadToolCache(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to load tool_cache_file: %w", err)
+	}
+	var file toolCacheFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return fmt.Errorf("invalid tool_cache_file %s: %w", path, err)
+	}
+	c.rememberTools(file.Tools)
+	return nil
+}
+
+// rememberTools caches the definitions of tools, as listed or loaded.
+func (c *Client) rememberTools(tools []*mcp.Tool) {
+	c.tools.remember(tools)
+	c.rememberOutputSchemas(tools)
+}
+
+// GetTool returns the definition of the named tool, from the tool cache if it
+// is there, or else by listing every tool.
+func (c *Client) GetTool(name string) (*mcp.Tool, error) {
+	if t, ok := c.tools.get(name); ok {
+		return t, nil
+	}
+	if _, err := c.ListAllTools(ListAllToolsParams{}); err != nil {
+		return nil, err
+	}
+	if t, ok := c.tools.get(name); ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("tool %q not found", name)
+}
+
+// DumpToolCache lists every tool of the server and writes their definitions
+// to the file at path, for later clients to load as their ToolCacheFile.
+func (c *Client) DumpToolCache(path string) error {
+	all, err := c.ListAllTools(ListAllToolsParams{})
+	if err != nil {
+		return err
+	}
+	file := toolCacheFile{Tools: make([]*mcp.Tool, len(all.Tools))}
+	for i := range all.Tools {
+		file.Tools[i] = &all.Tools[i]
+	}
+	raw, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(raw, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to dump the tool cache: %w", err)
+	}
+	return nil
+}
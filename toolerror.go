@@ -0,0 +1,39 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ToolError is thrown by callTool, when ThrowOnToolError is set, for results
+// flagged with isError. Scripts reach it as the value of the thrown error.
+type ToolError struct {
+	Tool              string
+	Content           []mcp.Content
+	StructuredContent any
+}
+
+func newToolError(tool string, res *mcp.CallToolResult) *ToolError {
+	return &ToolError{
+		Tool:              tool,
+		Content:           res.Content,
+		StructuredContent: res.StructuredContent,
+	}
+}
+
+// Error reports the text content of the result, which is where tools
+// describe what went wrong.
+func (e *ToolError) Error() string {
+	var texts []string
+	for _, content := range e.Content {
+		if text, ok := content.(*mcp.TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	if len(texts) == 0 {
+		return fmt.Sprintf("tool %q failed", e.Tool)
+	}
+	return fmt.Sprintf("tool %q failed: %s", e.Tool, strings.Join(texts, "\n"))
+}
@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// traceIDMetadata is the sample metadata key k6 outputs read trace IDs from
+// to link metrics to traces.
+const traceIDMetadata = "trace_id"
+
+type traceContextKey struct{}
+
+// traceContext identifies the span of a single MCP call.
+type traceContext struct {
+	traceID string
+	spanID  string
+}
+
+func newTraceContext() traceContext {
+	var b [24]byte
+	_, _ = rand.Read(b[:])
+	return traceContext{
+		traceID: hex.EncodeToString(b[:16]),
+		spanID:  hex.EncodeToString(b[16:]),
+	}
+}
+
+// traceparent renders tc as a sampled W3C traceparent header value.
+func (tc traceContext) traceparent() string {
+	return "00-" + tc.traceID + "-" + tc.spanID + "-01"
+}
+
+func withTraceContext(ctx context.Context, tc traceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// tracingRoundTripper propagates the trace context of the call a request
+// belongs to through the traceparent header, and the baggage of the client,
+// if any, through the baggage header of every request. The correlation ID of
+// the call, if any, is added to the baggage, for the spans of the call to
+// carry it.
+type tracingRoundTripper struct {
+	next    http.RoundTripper
+	baggage string
+}
+
+func (t *tracingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	tc, ok := req.Context().Value(traceContextKey{}).(traceContext)
+	baggage := t.baggage
+	if id, found := correlationID(req.Context()); found {
+		baggage = strings.TrimPrefix(baggage+","+correlationIDMetadata+"="+id, ",")
+	}
+	if !ok && baggage == "" {
+		return t.next.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	if ok {
+		req.Header.Set("traceparent", tc.traceparent())
+	}
+	if baggage != "" {
+		req.Header.Set("baggage", baggage)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// encodeBaggage renders members as a W3C baggage header value, sorted by key
+// so that every request carries the same header.
+func encodeBaggage(members map[string]string) string {
+	keys := make([]string, 0, len(members))
+	for k := range members {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	entries := make([]string, len(keys))
+	for i, k := range keys {
+		entries[i] = k + "=" + url.PathEscape(members[k])
+	}
+	return strings.Join(entries, ",")
+}
+
+// checkBaggage reports the baggage keys that are not valid HTTP tokens, as
+// the W3C baggage format requires.
+func checkBaggage(members map[string]string) []error {
+	var errs []error
+	for k := range members {
+		if k == "" || strings.ContainsFunc(k, func(r rune) bool { return !isTokenChar(r) }) {
+			errs = append(errs, fmt.Errorf("invalid baggage key %q", k))
+		}
+	}
+	return errs
+}
+
+func isTokenChar(r rune) bool {
+	return (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+		strings.ContainsRune("!#$%&'*+-.^_`|~", r))
+}
@@ -0,0 +1,320 @@
+package mcp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/xk6-mcp/metrics"
+)
+
+// gzipRoundTripper compresses request bodies once the server has advertised
+// gzip support through an Accept-Encoding response header. Requests sent
+// before that are left untouched.
+type gzipRoundTripper struct {
+	next      http.RoundTripper
+	ctx       context.Context
+	metrics   *metrics.K6Metrics
+	supported atomic.Bool
+}
+
+func (t *gzipRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.supported.Load() && req.Body != nil && req.Header.Get("Content-Encoding") == "" {
+		raw, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(raw); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		compressed := buf.Bytes()
+
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(compressed))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(compressed)), nil
+		}
+		req.ContentLength = int64(len(compressed))
+		req.Header.Set("Content-Encoding", "gzip")
+
+		t.metrics.PushCompression(t.ctx, len(raw), len(compressed))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && acceptsGzip(resp.Header.Get("Accept-Encoding")) {
+		t.supported.Store(true)
+	}
+
+	return resp, err
+}
+
+func acceptsGzip(header string) bool {
+	for _, encoding := range strings.Split(header, ",") {
+		name, _, _ := strings.Cut(encoding, ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			return true
+		}
+	}
+	return false
+}
+
+// streamMethod tags the timings of requests that carry no JSON-RPC message,
+// such as the GET opening an SSE event stream.
+const streamMethod = "stream"
+
+// tlsRoundTripper records, for every new TLS connection, whether it resumed
+// an earlier session.
+type tlsRoundTripper struct {
+	next    http.RoundTripper
+	ctx     context.Context
+	metrics *metrics.K6Metrics
+}
+
+func (t *tlsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				t.metrics.PushTLSResumed(t.ctx, state.DidResume)
+			}
+		},
+	}
+	return t.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// timingRoundTripper traces every request and records its DNS, TCP connect,
+// TLS and time-to-first-byte phases, tagged by the JSON-RPC method it sends.
+type timingRoundTripper struct {
+	next    http.RoundTripper
+	ctx     context.Context
+	metrics *metrics.K6Metrics
+}
+
+func (t *timingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := jsonRPCMethod(req)
+
+	var (
+		mu                                      sync.Mutex
+		timings                                 metrics.HTTPTimings
+		dnsStart, connectStart, tlsStart, wrote time.Time
+	)
+	since := func(start time.Time) time.Duration {
+		if start.IsZero() {
+			return 0
+		}
+		return time.Since(start)
+	}
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			timings.DNS = since(dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			mu.Lock()
+			defer mu.Unlock()
+			connectStart = time.Now()
+		},
+		ConnectDone: func(string, string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			timings.TCPConnect = since(connectStart)
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			timings.TLS = since(tlsStart)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			wrote = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			timings.TTFB = since(wrote)
+		},
+	}
+
+	resp, err := t.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+
+	mu.Lock()
+	defer mu.Unlock()
+	t.metrics.PushTimings(t.ctx, method, timings)
+
+	return resp, err
+}
+
+// jsonRPCMethod returns the method of the JSON-RPC message in the body of
+// req, or streamMethod when there is none. The body itself is left unread.
+func jsonRPCMethod(req *http.Request) string {
+	if req.Body == nil || req.GetBody == nil {
+		return streamMethod
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return streamMethod
+	}
+	defer func() { _ = body.Close() }()
+
+	var msg struct {
+		Method string `json:"method"`
+	}
+	if err := json.NewDecoder(body).Decode(&msg); err != nil || msg.Method == "" {
+		return streamMethod
+	}
+	return msg.Method
+}
+
+// defaultRetryAfter is how long to wait before retrying a rate-limited request
+// whose response has no valid Retry-After header.
+const defaultRetryAfter = time.Second
+
+// rateLimitRoundTripper counts the requests rejected with HTTP 429, and
+// retries them up to retries times once their Retry-After delay has passed.
+type rateLimitRoundTripper struct {
+	next    http.RoundTripper
+	ctx     context.Context
+	metrics *metrics.K6Metrics
+	retries int
+}
+
+func (t *rateLimitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts, _ := req.Context().Value(requestAttemptsKey{}).(*requestAttempts)
+	for attempt := 0; ; attempt++ {
+		if attempts != nil {
+			attempts.start(t.metrics.Now())
+		}
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+		t.metrics.PushRateLimited(t.ctx, jsonRPCMethod(req))
+
+		if attempt >= t.retries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		delay := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+
+		if req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+type requestAttemptsKey struct{}
+
+// requestAttempts counts the attempts made at the HTTP requests of a call,
+// retries included, and when the last one started.
+type requestAttempts struct {
+	mu    sync.Mutex
+	count int
+	last  time.Time
+}
+
+// withRequestAttempts returns ctx with a count of the attempts made at the
+// HTTP requests of the call running with it.
+func withRequestAttempts(ctx context.Context) (context.Context, *requestAttempts) {
+	attempts := &requestAttempts{}
+	return context.WithValue(ctx, requestAttemptsKey{}, attempts), attempts
+}
+
+func (a *requestAttempts) start(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.count++
+	a.last = now
+}
+
+// get returns the number of attempts made and when the last one started.
+func (a *requestAttempts) get() (int, time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.count, a.last
+}
+
+// retryAfter parses a Retry-After header, given either as a number of seconds
+// or as an HTTP date, into the delay to wait from now.
+func retryAfter(header string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header)); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return defaultRetryAfter
+}
+
+// unescapeHTMLRoundTripper undoes the HTML escaping that encoding/json applies
+// to strings in request bodies, for servers that compare the raw JSON.
+type unescapeHTMLRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (t *unescapeHTMLRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Header.Get("Content-Encoding") != "" {
+		return t.next.RoundTrip(req)
+	}
+
+	raw, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	body, err := unescapeHTML(raw)
+	if err != nil {
+		// Leave what isn't JSON to the server to reject.
+		body = raw
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+
+	return t.next.RoundTrip(req)
+}
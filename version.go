@@ -0,0 +1,32 @@
+package mcp
+
+import (
+	"context"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// recordInitializeParams is a sending middleware recording the params of the
+// initialize request into initialize.
+func recordInitializeParams(initialize **mcp.InitializeParams) mcp.Middleware {
+	return func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			if params, ok := req.GetParams().(*mcp.InitializeParams); ok {
+				*initialize = params
+			}
+			return next(ctx, method, req)
+		}
+	}
+}
+
+// downgraded reports whether the server negotiated an older protocol version
+// than the client requested. Versions are dates, which compare as strings.
+func downgraded(requested, negotiated string) bool {
+	return requested != "" && negotiated < requested
+}
+
+// ProtocolVersion returns the protocol version negotiated with the server,
+// which may be older than the one the client requested.
+func (c *Client) ProtocolVersion() string {
+	return c.initializeResult().ProtocolVersion
+}
@@ -0,0 +1,274 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultWatchMinInterval = 100 * time.Millisecond
+	defaultWatchMaxInterval = 5 * time.Second
+
+	watchSubscribe = "subscribe"
+	watchPoll      = "poll"
+)
+
+type (
+	// WatchResourceOptions configures WatchResource. When the resource is
+	// polled, the interval starts at MinIntervalMs, 100 by default, doubles
+	// every time the resource is found unchanged up to MaxIntervalMs, 5000 by
+	// default, and drops back to MinIntervalMs once it changed.
+	WatchResourceOptions struct {
+		// TimeoutMs ends the watch once it has passed, if set
+		TimeoutMs int
+		// MaxChanges ends the watch once onChange was called that many
+		// times, if set
+		MaxChanges    int
+		MinIntervalMs int
+		MaxIntervalMs int
+	}
+
+	// WatchResult is what the promise of WatchResource resolves to: how the
+	// resource was watched, subscribe or poll, the number of versions handed
+	// to onChange, and the number of update notifications coalesced with a
+	// later one while onChange was busy.
+	WatchResult struct {
+		Mode      string
+		Changes   int
+		Coalesced int
+	}
+)
+
+// WatchResource watches the resource at uri, subscribing to it when the
+// server supports subscriptions and polling it otherwise, and calls onChange
+// with every new version of it. The watch ends when onChange returns false,
+// or as set by opts, and the returned promise resolves to how it went.
+//
+// The resource is not read again while onChange runs: the update
+// notifications received in the meantime are coalesced, and polling waits, so
+// that a slow onChange is only handed the latest version. The time from a
+// change being notified, or the poll that found it being sent, until onChange
+// is called is added to the mcp_watch_latency trend.
+func (c *Client) WatchResource(uri string, onChange sobek.Value, opts WatchResourceOptions) (*sobek.Promise, error) {
+	fn, ok := sobek.AssertFunction(onChange)
+	if !ok {
+		return nil, errors.New("onChange must be a function")
+	}
+
+	w := &resourceWatch{
+		c:           c,
+		uri:         uri,
+		onChange:    fn,
+		maxChanges:  opts.MaxChanges,
+		minInterval: defaultWatchMinInterval,
+		maxInterval: defaultWatchMaxInterval,
+		result:      WatchResult{Mode: watchPoll},
+	}
+	if opts.MinIntervalMs > 0 {
+		w.minInterval = time.Duration(opts.MinIntervalMs) * time.Millisecond
+	}
+	if opts.MaxIntervalMs > 0 {
+		w.maxInterval = time.Duration(opts.MaxIntervalMs) * time.Millisecond
+	}
+	w.maxInterval = max(w.maxInterval, w.minInterval)
+	if init := c.initializeResult(); init != nil && init.Capabilities != nil &&
+		init.Capabilities.Resources != nil && init.Capabilities.Resources.Subscribe {
+		w.result.Mode = watchSubscribe
+	}
+
+	promise, resolve, reject := c.vu.Runtime().NewPromise()
+	c.notifications.iteration.Store(c.iteration())
+	w.loop = c.eventLoop()
+	go func() {
+		var timeout <-chan time.Time
+		if opts.TimeoutMs > 0 {
+			timer := time.NewTimer(time.Duration(opts.TimeoutMs) * time.Millisecond)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		var err error
+		if w.result.Mode == watchSubscribe {
+			err = w.subscribe(timeout)
+		} else {
+			err = w.poll(timeout)
+		}
+		w.loop.settle(func() error {
+			if err != nil {
+				_ = reject(err)
+				return nil
+			}
+			_ = resolve(w.result)
+			return nil
+		})
+	}()
+
+	return promise, nil
+}
+
+// resourceWatch is the state of a WatchResource call, kept by the goroutine
+// running it.
+type resourceWatch struct {
+	c          *Client
+	uri        string
+	onChange   sobek.Callable
+	maxChanges int
+
+	minInterval time.Duration
+	maxInterval time.Duration
+
+	// loop keeps the event loop alive until the watch is over
+	loop   *eventLoop
+	result WatchResult
+}
+
+// subscribe watches the resource through resources/subscribe, reading it
+// again on every update notification.
+func (w *resourceWatch) subscribe(timeout <-chan time.Time) error {
+	c := w.c
+	// Start watching before subscribing, so that no update slips in between.
+	updates := c.notifications.watch("notifications/resources/updated", func(n Notification) bool {
+		params, ok := n.Params.(*mcp.ResourceUpdatedNotificationParams)
+		return ok && params.URI == w.uri
+	}, c.metrics.Now)
+	defer func() {
+		c.notifications.stopWaiting(updates)
+		w.result.Coalesced = c.notifications.replaced(updates)
+	}()
+
+	if _, err := watchCall(w, SubscribeMethod, nil, func(ctx context.Context) (struct{}, error) {
+		params := &mcp.SubscribeParams{URI: w.uri}
+		recordParams(ctx, params)
+		return struct{}{}, c.session.Subscribe(ctx, params)
+	}); err != nil {
+		return err
+	}
+	defer func() {
+		if c.ctx.Err() == nil && !w.loop.abandoned {
+			_, _ = watchCall(w, UnsubscribeMethod, nil, func(ctx context.Context) (struct{}, error) {
+				params := &mcp.UnsubscribeParams{URI: w.uri}
+				recordParams(ctx, params)
+				return struct{}{}, c.session.Unsubscribe(ctx, params)
+			})
+		}
+	}()
+
+	for {
+		select {
+		case <-updates.ready:
+			n, received := c.notifications.takeLatest(updates)
+			if n == nil {
+				continue
+			}
+			res, err := w.read()
+			if err != nil {
+				return err
+			}
+			if done, err := w.deliver(res, received); done || err != nil {
+				return err
+			}
+		case <-timeout:
+			return nil
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		}
+	}
+}
+
+// poll watches the resource by reading it at an interval that grows while it
+// stays unchanged.
+func (w *resourceWatch) poll(timeout <-chan time.Time) error {
+	c := w.c
+	res, err := w.read()
+	if err != nil {
+		return err
+	}
+	last, err := json.Marshal(res.Contents)
+	if err != nil {
+		return err
+	}
+
+	interval := w.minInterval
+	for {
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-timeout:
+			timer.Stop()
+			return nil
+		case <-c.ctx.Done():
+			timer.Stop()
+			return c.ctx.Err()
+		}
+
+		sent := c.metrics.Now()
+		res, err := w.read()
+		if err != nil {
+			return err
+		}
+		contents, err := json.Marshal(res.Contents)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(contents, last) {
+			interval = min(interval*2, w.maxInterval)
+			continue
+		}
+		last, interval = contents, w.minInterval
+
+		if done, err := w.deliver(res, sent); done || err != nil {
+			return err
+		}
+	}
+}
+
+// read reads the resource, bypassing the cache, with the request metrics
+// tagged with the watch mode.
+func (w *resourceWatch) read() (*mcp.ReadResourceResult, error) {
+	c := w.c
+	return watchCall(w, ReadResourceMethod, map[string]string{"watch": w.result.Mode}, func(ctx context.Context) (*mcp.ReadResourceResult, error) {
+		params := &mcp.ReadResourceParams{URI: w.uri}
+		recordParams(ctx, params)
+		return c.session.ReadResource(ctx, params)
+	})
+}
+
+// watchCall makes a call of the watch through the call pipeline, as callNamed
+// does on the VU, answering the server requests it triggers meanwhile.
+func watchCall[T any](w *resourceWatch, method string, tags map[string]string, fn func(context.Context) (T, error)) (T, error) {
+	c := w.c
+	return awaitOnLoop(w.loop, func() (T, error) {
+		return invoke(c, method, "", tags, strictly(c, intercepting(c, fn)))
+	})
+}
+
+// deliver calls onChange with res on the event loop and waits for it to
+// return, reporting whether the watch is over.
+func (w *resourceWatch) deliver(res *mcp.ReadResourceResult, noticed time.Time) (bool, error) {
+	c := w.c
+	var (
+		stop bool
+		err  error
+	)
+	if !w.loop.run(func() {
+		c.metrics.PushWatchLatency(c.ctx, w.result.Mode, c.metrics.Since(noticed))
+		rt := c.vu.Runtime()
+		var v sobek.Value
+		v, err = w.onChange(sobek.Undefined(), rt.ToValue(res))
+		stop = err == nil && v.StrictEquals(rt.ToValue(false))
+	}) {
+		return true, c.ctx.Err()
+	}
+
+	w.result.Changes++
+	if err != nil {
+		return true, err
+	}
+	return stop || (w.maxChanges > 0 && w.result.Changes >= w.maxChanges), nil
+}